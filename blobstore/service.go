@@ -0,0 +1,149 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gregorybednov/lbc/yggdrasil"
+)
+
+// DefaultPort — TCP-порт обмена вложениями в сети Yggdrasil. Адреса пиров
+// известны без портов, поэтому порт у всех нод сети одинаковый.
+const DefaultPort = 8100
+
+// Handler отдаёт пирам вложения из локального хранилища: GET /blobs/<hash>.
+// Чужие вложения не запрашиваются, чтобы запросы не ходили по кругу.
+func Handler(store *Store) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /blobs/{hash}", func(w http.ResponseWriter, r *http.Request) {
+		f, size, err := store.Open(r.PathValue("hash"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		defer f.Close()
+		serveBlob(w, f, size)
+	})
+	return mux
+}
+
+func serveBlob(w http.ResponseWriter, r io.Reader, size int64) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	io.Copy(w, r)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// Fetcher отдаёт вложение из локального хранилища, а если его там нет —
+// получает у пиров через Yggdrasil, сверяет с хешем и сохраняет.
+type Fetcher struct {
+	store  *Store
+	port   int
+	client *http.Client
+}
+
+// NewFetcher создаёт Fetcher над store; port — порт обмена у пиров.
+func NewFetcher(store *Store, port int) *Fetcher {
+	if port <= 0 {
+		port = DefaultPort
+	}
+	return &Fetcher{
+		store: store,
+		port:  port,
+		client: &http.Client{
+			Timeout: 5 * time.Minute,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					n, err := yggdrasil.WaitNetwork(ctx)
+					if err != nil {
+						return nil, err
+					}
+					return n.Stack.DialContext(ctx, "tcp", addr)
+				},
+			},
+		},
+	}
+}
+
+// Put сохраняет вложение в локальное хранилище.
+func (f *Fetcher) Put(r io.Reader) (string, error) { return f.store.Put(r, "") }
+
+// MaxSize — предел размера одного вложения.
+func (f *Fetcher) MaxSize() int64 { return f.store.MaxSize() }
+
+// Get возвращает вложение и его размер.
+func (f *Fetcher) Get(ctx context.Context, hash string) (io.ReadCloser, int64, error) {
+	file, size, err := f.store.Open(hash)
+	if !errors.Is(err, ErrNotFound) {
+		return file, size, err
+	}
+	n, err := yggdrasil.WaitNetwork(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, peer := range n.Peers {
+		if err := f.fetch(ctx, peer, hash); err != nil {
+			continue
+		}
+		return f.store.Open(hash)
+	}
+	return nil, 0, ErrNotFound
+}
+
+func (f *Fetcher) fetch(ctx context.Context, peer net.IP, hash string) error {
+	url := "http://" + net.JoinHostPort(peer.String(), strconv.Itoa(f.port)) + "/blobs/" + hash
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", peer, resp.Status)
+	}
+	// Put сверяет содержимое с хешем: подменённое пиром не сохранится.
+	_, err = f.store.Put(resp.Body, hash)
+	return err
+}
+
+// Serve раздаёт вложения store пирам на порту port сети Yggdrasil, пока
+// не отменён ctx.
+func Serve(ctx context.Context, store *Store, port int) error {
+	if port <= 0 {
+		port = DefaultPort
+	}
+	n, err := yggdrasil.WaitNetwork(ctx)
+	if err != nil {
+		return err
+	}
+	ln, err := n.Stack.ListenTCP(&net.TCPAddr{Port: port})
+	if err != nil {
+		return fmt.Errorf("blobs: listen on yggdrasil port %d: %w", port, err)
+	}
+	srv := &http.Server{Handler: Handler(store), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
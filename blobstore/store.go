@@ -0,0 +1,191 @@
+// Package blobstore — локальное хранилище вложений по хешу содержимого.
+// Обещание ссылается на вложения (договоры, фото выполненной работы) хешами
+// sha256:<hex> в поле attachments; сами файлы в цепочку не попадают, а лежат
+// у нод, которым они нужны, и передаются между ними через Yggdrasil
+// (см. Serve). Содержимое проверяется по хешу при каждой отдаче, поэтому
+// ни повреждённый файл на диске, ни чужая нода не могут подменить вложение.
+package blobstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HashPrefix — префикс хеша вложения.
+const HashPrefix = "sha256:"
+
+// DefaultMaxSize — предел размера одного вложения по умолчанию.
+const DefaultMaxSize = 32 << 20
+
+// ErrNotFound — вложения нет в хранилище.
+var ErrNotFound = errors.New("blob not found")
+
+// ErrTooLarge — вложение больше предела размера хранилища.
+var ErrTooLarge = errors.New("blob too large")
+
+// ErrQuota — вложение не помещается в квоту хранилища.
+var ErrQuota = errors.New("blob store quota exceeded")
+
+// ParseHash проверяет хеш вида sha256:<64 hex в нижнем регистре> и
+// возвращает его hex-часть.
+func ParseHash(h string) (string, error) {
+	hexPart, ok := strings.CutPrefix(h, HashPrefix)
+	if !ok {
+		return "", fmt.Errorf("attachment %q: want %s<hex>", h, HashPrefix)
+	}
+	if b, err := hex.DecodeString(hexPart); err != nil || len(b) != sha256.Size || hexPart != strings.ToLower(hexPart) {
+		return "", fmt.Errorf("attachment %q: want %d lowercase hex bytes", h, sha256.Size)
+	}
+	return hexPart, nil
+}
+
+// Store — каталог с файлами <первые 2 hex>/<hex>. Запись атомарна (через
+// временный файл и rename), поэтому нода и `lbc blob add` могут работать с
+// одним каталогом одновременно.
+type Store struct {
+	dir     string
+	maxSize int64
+	quota   int64 // байт на всё хранилище; 0 — без ограничения
+}
+
+// Open открывает (и при необходимости создаёт) хранилище в dir; maxSize <= 0
+// — DefaultMaxSize, quota <= 0 — без ограничения общего объёма.
+func Open(dir string, maxSize, quota int64) (*Store, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	if quota < 0 {
+		quota = 0
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "tmp"), 0o700); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, maxSize: maxSize, quota: quota}, nil
+}
+
+// MaxSize — предел размера одного вложения.
+func (s *Store) MaxSize() int64 { return s.maxSize }
+
+func (s *Store) path(hexPart string) string {
+	return filepath.Join(s.dir, hexPart[:2], hexPart)
+}
+
+// Put сохраняет содержимое r и возвращает его хеш. Если want не пуст,
+// содержимое с другим хешем отвергается и не сохраняется.
+func (s *Store) Put(r io.Reader, want string) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Join(s.dir, "tmp"), "blob-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), io.LimitReader(r, s.maxSize+1))
+	if err != nil {
+		return "", err
+	}
+	if n > s.maxSize {
+		return "", fmt.Errorf("%w: exceeds %d bytes", ErrTooLarge, s.maxSize)
+	}
+	hexPart := hex.EncodeToString(h.Sum(nil))
+	got := HashPrefix + hexPart
+	if want != "" && got != want {
+		return "", fmt.Errorf("content hash mismatch: want %s, got %s", want, got)
+	}
+	if s.quota > 0 && !s.Has(got) {
+		used, err := s.size()
+		if err != nil {
+			return "", err
+		}
+		if used+n > s.quota {
+			return "", fmt.Errorf("%w: %d of %d bytes used", ErrQuota, used, s.quota)
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path(hexPart)), 0o700); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), s.path(hexPart)); err != nil {
+		return "", err
+	}
+	return got, nil
+}
+
+// Open возвращает вложение, предварительно сверив содержимое с хешем.
+// Файл, который хешу не соответствует, удаляется: его можно заново получить
+// у других нод.
+func (s *Store) Open(hash string) (*os.File, int64, error) {
+	hexPart, err := ParseHash(hash)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := os.Open(s.path(hexPart))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, ErrNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err == nil && hex.EncodeToString(h.Sum(nil)) != hexPart {
+		f.Close()
+		os.Remove(s.path(hexPart))
+		return nil, 0, ErrNotFound
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, n, nil
+}
+
+// Has сообщает, есть ли вложение в хранилище (без проверки содержимого).
+func (s *Store) Has(hash string) bool {
+	hexPart, err := ParseHash(hash)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(s.path(hexPart))
+	return err == nil
+}
+
+// size — суммарный объём сохранённых вложений (без временных файлов).
+// Считается обходом каталога: хранилище могут пополнять и другие процессы.
+func (s *Store) size() (int64, error) {
+	var total int64
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path == filepath.Join(s.dir, "tmp") {
+			return filepath.SkipDir
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	return total, err
+}
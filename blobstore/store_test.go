@@ -0,0 +1,43 @@
+package blobstore
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPutLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		quota   int64
+		stored  []string // кладутся до проверяемого
+		body    string
+		wantErr error
+	}{
+		{"fits", 0, nil, "1234567890", nil},
+		{"over max size", 0, nil, "12345678901", ErrTooLarge},
+		{"within quota", 20, []string{"aaaaaaaaaa"}, "1234567890", nil},
+		{"over quota", 20, []string{"aaaaaaaaaa", "bbbbb"}, "1234567890", ErrQuota},
+		{"already stored", 20, []string{"aaaaaaaaaa", "1234567890"}, "1234567890", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Open(t.TempDir(), 10, tt.quota)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, b := range tt.stored {
+				if _, err := s.Put(strings.NewReader(b), ""); err != nil {
+					t.Fatal(err)
+				}
+			}
+			hash, err := s.Put(strings.NewReader(tt.body), "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Put: %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !s.Has(hash) {
+				t.Errorf("%s not stored", hash)
+			}
+		})
+	}
+}
@@ -1,22 +1,35 @@
 package blockchain
 
 import (
-	"crypto/ed25519"
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
+	"github.com/gregorybednov/lbc/canonical"
+	"github.com/gregorybednov/lbc/codec"
+	"github.com/gregorybednov/lbc/kv"
+	"github.com/gregorybednov/lbc/pow"
 	types "github.com/gregorybednov/lbc_sdk"
 
-	"github.com/dgraph-io/badger"
 	abci "github.com/tendermint/tendermint/abci/types"
 )
 
 type PromiseApp struct {
-	db           *badger.DB
-	currentBatch *badger.Txn
+	db               kv.Store
+	chainID          string
+	currentBatch     kv.Txn // буфер записей текущего блока, см. kv.NewWriteBuffer
+	checkState       kv.Txn // см. checkTxState
+	checkRates       rateCounts
+	blockRates       rateCounts // см. chargeRate
+	validatorUpdates []validatorRecord
+
+	height    int64    // высота текущего блока (из BeginBlock)
+	blockTime int64    // время текущего блока, unix (из BeginBlock)
+	pending   []Record // записи текущего блока для BlockEvent
+	completed []string // обязательства, выполненные в текущем блоке
+	events    eventHub
 }
 
 type compoundTxRaw struct {
@@ -24,13 +37,23 @@ type compoundTxRaw struct {
 	Signature string          `json:"signature"`
 }
 
-type compoundBody struct {
-	Promise    *types.PromiseTxBody    `json:"promise"`
-	Commitment *types.CommitmentTxBody `json:"commitment"`
-}
-
-func NewPromiseApp(db *badger.DB) *PromiseApp {
-	return &PromiseApp{db: db}
+func NewPromiseApp(db kv.Store) *PromiseApp {
+	app := &PromiseApp{db: db}
+	// chain_id нужен для проверки подписей; он сохраняется в InitChain.
+	_ = kv.View(db, func(r kv.Reader) error {
+		v, err := r.Get([]byte(chainIDKey))
+		app.chainID = string(v)
+		return err
+	})
+	// Высота нужна CheckTx для квот (см. chargeRate) ещё до первого блока.
+	_ = kv.View(db, func(r kv.Reader) error {
+		v, err := r.Get([]byte(heightKey))
+		if err == nil {
+			app.height, err = strconv.ParseInt(string(v), 10, 64)
+		}
+		return err
+	})
+	return app
 }
 
 func hasPrefix(id, pref string) bool { return strings.HasPrefix(id, pref+":") }
@@ -45,47 +68,31 @@ func requireIDPrefix(id, pref string) error {
 	return nil
 }
 
-func verifyAndExtractBody(db *badger.DB, tx []byte) (map[string]interface{}, error) {
-	var outer struct {
-		Body      types.CommiterTxBody `json:"body"`
-		Signature string               `json:"signature"`
-	}
-
-	if err := json.Unmarshal(tx, &outer); err != nil {
+func verifyAndExtractBody(chainID string, tx []byte) (map[string]interface{}, error) {
+	var outerRaw compoundTxRaw
+	if err := json.Unmarshal(tx, &outerRaw); err != nil {
 		return nil, errors.New("invalid JSON wrapper")
 	}
-
-	msg, err := json.Marshal(outer.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	sig, err := base64.StdEncoding.DecodeString(outer.Signature)
-	if err != nil {
-		return nil, errors.New("invalid signature base64")
-	}
-	if len(sig) != ed25519.SignatureSize {
-		return nil, fmt.Errorf("invalid signature length: got %d, want %d", len(sig), ed25519.SignatureSize)
+	var body types.CommiterTxBody
+	if err := json.Unmarshal(outerRaw.Body, &body); err != nil {
+		return nil, errors.New("invalid JSON wrapper")
 	}
 
-	pubkeyB64 := strings.TrimSpace(outer.Body.CommiterPubKey)
+	pubkeyB64 := strings.TrimSpace(body.CommiterPubKey)
 	if pubkeyB64 == "" {
 		return nil, errors.New("missing commiter pubkey")
 	}
-	pubkey, err := base64.StdEncoding.DecodeString(pubkeyB64)
+	pubkey, err := decodeEd25519PubKey(pubkeyB64)
 	if err != nil {
-		return nil, errors.New("invalid pubkey base64")
-	}
-	if len(pubkey) != ed25519.PublicKeySize {
-		return nil, fmt.Errorf("invalid pubkey length: got %d, want %d", len(pubkey), ed25519.PublicKeySize)
+		return nil, err
 	}
 
-	if !ed25519.Verify(pubkey, msg, sig) {
-		return nil, errors.New("signature verification failed")
+	if err := canonical.Verify(pubkey, outerRaw.Signature, chainID, "commiter", outerRaw.Body); err != nil {
+		return nil, err
 	}
 
 	var result map[string]interface{}
-	if err := json.Unmarshal(msg, &result); err != nil {
+	if err := json.Unmarshal(outerRaw.Body, &result); err != nil {
 		return nil, err
 	}
 
@@ -93,11 +100,36 @@ func verifyAndExtractBody(db *badger.DB, tx []byte) (map[string]interface{}, err
 }
 
 func (app *PromiseApp) CheckTx(req abci.RequestCheckTx) abci.ResponseCheckTx {
-	compound, err := verifyCompoundTx(app.db, req.Tx)
+	tx, err := decodeTx(req.Tx)
+	if err != nil {
+		return abci.ResponseCheckTx{Code: 1, Log: err.Error()}
+	}
+	// Подписи не зависят от состояния: при перепроверке после блока их
+	// проверять заново незачем.
+	if req.Type != abci.CheckTxType_Recheck {
+		if _, err := verifyCosignatures(app.chainID, tx); err != nil {
+			return abci.ResponseCheckTx{Code: 1, Log: err.Error()}
+		}
+	}
+	var priority int64
+	if pow.Required(bodyType(tx)) {
+		if priority, err = checkPow(app.chainID, tx); err != nil {
+			return abci.ResponseCheckTx{Code: 9, Log: err.Error()}
+		}
+	}
+	st := app.checkTxState()
+	if isValidatorTxType(bodyType(tx)) {
+		return app.checkValidatorTx(st, tx)
+	}
+	if bodyType(tx) == fulfillmentTxType {
+		return app.checkFulfillmentTx(st, tx)
+	}
+
+	compound, err := verifyCompoundTx(st, app.chainID, tx)
 	if err == nil {
 		// ---- Валидация содержимого композита по ER ----
-		p := compound.Body.Promise
-		c := compound.Body.Commitment
+		p := compound.Promise
+		c := compound.Commitment
 
 		// Оба тела должны присутствовать
 		if p == nil || c == nil {
@@ -130,6 +162,18 @@ func (app *PromiseApp) CheckTx(req abci.RequestCheckTx) abci.ResponseCheckTx {
 		if strings.TrimSpace(p.Text) == "" {
 			return abci.ResponseCheckTx{Code: 2, Log: "promise.text is required"}
 		}
+		if err := checkSealedText(st, p, c); err != nil {
+			return abci.ResponseCheckTx{Code: 2, Log: err.Error()}
+		}
+		if err := checkAttachments(p); err != nil {
+			return abci.ResponseCheckTx{Code: 2, Log: err.Error()}
+		}
+		if err := checkRecurrence(p, c); err != nil {
+			return abci.ResponseCheckTx{Code: 2, Log: err.Error()}
+		}
+		if err := checkQuantity(p, c); err != nil {
+			return abci.ResponseCheckTx{Code: 2, Log: err.Error()}
+		}
 		//if p.Due == 0 {
 		//	return abci.ResponseCheckTx{Code: 2, Log: "promise.due is required"}
 		//}
@@ -142,70 +186,48 @@ func (app *PromiseApp) CheckTx(req abci.RequestCheckTx) abci.ResponseCheckTx {
 		if c.PromiseID != p.ID {
 			return abci.ResponseCheckTx{Code: 2, Log: "commitment.promise_id must equal promise.id"}
 		}
+		if len(c.Cosigners) != 0 {
+			return abci.ResponseCheckTx{Code: 2, Log: errCosignersInBody.Error()}
+		}
 
 		// Уникальность Promise.ID
-		if err := app.db.View(func(txn *badger.Txn) error {
-			_, e := txn.Get([]byte(p.ID))
-			if e == badger.ErrKeyNotFound {
-				return nil
-			}
-			return errors.New("duplicate promise ID")
-		}); err != nil {
-			return abci.ResponseCheckTx{Code: 3, Log: err.Error()}
+		if _, e := st.Get([]byte(p.ID)); e != kv.ErrNotFound {
+			return abci.ResponseCheckTx{Code: 3, Log: "duplicate promise ID"}
 		}
 		// Уникальность Commitment.ID
-		if err := app.db.View(func(txn *badger.Txn) error {
-			_, e := txn.Get([]byte(c.ID))
-			if e == badger.ErrKeyNotFound {
-				return nil
-			}
-			return errors.New("duplicate commitment ID")
-		}); err != nil {
-			return abci.ResponseCheckTx{Code: 3, Log: err.Error()}
+		if _, e := st.Get([]byte(c.ID)); e != kv.ErrNotFound {
+			return abci.ResponseCheckTx{Code: 3, Log: "duplicate commitment ID"}
 		}
 
 		// Существование коммитера
-		if err := app.db.View(func(txn *badger.Txn) error {
-			_, e := txn.Get([]byte(c.CommiterID))
-			if e == badger.ErrKeyNotFound {
-				return errors.New("unknown commiter")
-			}
-			return nil
-		}); err != nil {
-			return abci.ResponseCheckTx{Code: 4, Log: err.Error()}
+		if _, e := st.Get([]byte(c.CommiterID)); e == kv.ErrNotFound {
+			return abci.ResponseCheckTx{Code: 4, Log: "unknown commiter"}
 		}
 
 		// Существование бенефициара
-		if err := app.db.View(func(txn *badger.Txn) error {
-			_, e := txn.Get([]byte(p.BeneficiaryID))
-			if e == badger.ErrKeyNotFound {
-				return errors.New("unknown beneficiary")
-			}
-			return nil
-		}); err != nil {
-			return abci.ResponseCheckTx{Code: 5, Log: err.Error()}
+		if _, e := st.Get([]byte(p.BeneficiaryID)); e == kv.ErrNotFound {
+			return abci.ResponseCheckTx{Code: 5, Log: "unknown beneficiary"}
 		}
 
 		// Существование parent (если задан)
 		if p.ParentPromiseID != nil {
-			if err := app.db.View(func(txn *badger.Txn) error {
-				_, e := txn.Get([]byte(*p.ParentPromiseID))
-				if e == badger.ErrKeyNotFound {
-					return errors.New("unknown parent promise")
-				}
-				return nil
-			}); err != nil {
-				return abci.ResponseCheckTx{Code: 6, Log: err.Error()}
+			if _, e := st.Get([]byte(*p.ParentPromiseID)); e == kv.ErrNotFound {
+				return abci.ResponseCheckTx{Code: 6, Log: "unknown parent promise"}
 			}
 		}
 
-		return abci.ResponseCheckTx{Code: 0}
+		// Квота коммитера; блок, в который попадёт транзакция, — следующий.
+		remaining, err := chargeRate(st, app.checkRates, c.CommiterID, app.height+1)
+		if err != nil {
+			return abci.ResponseCheckTx{Code: 8, Log: err.Error()}
+		}
+		return acceptCheckTx(st, map[string]any{p.ID: p, c.ID: c}, int64(remaining))
 	}
 
 	// ---- Попытка ОДИНОЧНЫХ транзакций ----
 
 	// 3.1) Совместимость: одиночный commiter (твоя старая логика)
-	if body, oldErr := verifyAndExtractBody(app.db, req.Tx); oldErr == nil {
+	if body, oldErr := verifyAndExtractBody(app.chainID, tx); oldErr == nil {
 		id, ok := body["id"].(string)
 		if !ok || id == "" {
 			return abci.ResponseCheckTx{Code: 2, Log: "missing id"}
@@ -214,23 +236,26 @@ func (app *PromiseApp) CheckTx(req abci.RequestCheckTx) abci.ResponseCheckTx {
 			return abci.ResponseCheckTx{Code: 2, Log: err.Error()}
 		}
 		// Дубликат
-		if err := app.db.View(func(txn *badger.Txn) error {
-			_, e := txn.Get([]byte(id))
-			if e == badger.ErrKeyNotFound {
-				return nil
-			}
-			return errors.New("duplicate id")
-		}); err != nil {
-			return abci.ResponseCheckTx{Code: 3, Log: err.Error()}
+		if _, e := st.Get([]byte(id)); e != kv.ErrNotFound {
+			return abci.ResponseCheckTx{Code: 3, Log: "duplicate id"}
 		}
-		return abci.ResponseCheckTx{Code: 0}
+		if err := checkAdmission(st, tx); err != nil {
+			return abci.ResponseCheckTx{Code: 10, Log: err.Error()}
+		}
+		var outer struct {
+			Body types.CommiterTxBody `json:"body"`
+		}
+		if err := json.Unmarshal(tx, &outer); err != nil {
+			return abci.ResponseCheckTx{Code: 1, Log: err.Error()}
+		}
+		return acceptCheckTx(st, map[string]any{id: &outer.Body}, priority)
 	}
 
 	var single struct {
 		Body      types.BeneficiaryTxBody `json:"body"`
 		Signature string                  `json:"signature"`
 	}
-	if err2 := json.Unmarshal(req.Tx, &single); err2 == nil && single.Body.Type == "beneficiary" {
+	if err2 := json.Unmarshal(tx, &single); err2 == nil && single.Body.Type == "beneficiary" {
 		if err := requireIDPrefix(single.Body.ID, "beneficiary"); err != nil {
 			return abci.ResponseCheckTx{Code: 2, Log: err.Error()}
 		}
@@ -238,48 +263,135 @@ func (app *PromiseApp) CheckTx(req abci.RequestCheckTx) abci.ResponseCheckTx {
 			return abci.ResponseCheckTx{Code: 2, Log: "beneficiary.name is required"}
 		}
 		// уникальность
-		if err := app.db.View(func(txn *badger.Txn) error {
-			_, e := txn.Get([]byte(single.Body.ID))
-			if e == badger.ErrKeyNotFound {
-				return nil
-			}
-			return errors.New("duplicate beneficiary ID")
-		}); err != nil {
-			return abci.ResponseCheckTx{Code: 3, Log: err.Error()}
+		if _, e := st.Get([]byte(single.Body.ID)); e != kv.ErrNotFound {
+			return abci.ResponseCheckTx{Code: 3, Log: "duplicate beneficiary ID"}
 		}
-		return abci.ResponseCheckTx{Code: 0}
+		return acceptCheckTx(st, map[string]any{single.Body.ID: &single.Body}, priority)
 	}
 
 	// Если дошли сюда — составной формат не прошёл; вернём его причину.
 	return abci.ResponseCheckTx{Code: 1, Log: err.Error()}
 }
 
-func (app *PromiseApp) BeginBlock(_ abci.RequestBeginBlock) abci.ResponseBeginBlock {
+// checkTxState — состояние проверки для CheckTx: зафиксированная база плюс
+// записи транзакций, уже принятых в mempool. Без него две транзакции с
+// одним ID обе проходят проверку, и одна из них падает уже в блоке.
+// Сбрасывается в Commit; перепроверка (Recheck) оставшихся в mempool
+// транзакций заполняет его заново.
+func (app *PromiseApp) checkTxState() kv.Txn {
+	if app.checkState == nil {
+		app.checkState = kv.NewWriteBuffer(app.db)
+		app.checkRates = rateCounts{}
+	}
+	return app.checkState
+}
+
+// resetCheckTxState отбрасывает состояние проверки после блока.
+func (app *PromiseApp) resetCheckTxState() {
+	if app.checkState != nil {
+		app.checkState.Discard()
+		app.checkState = nil
+		app.checkRates = nil
+	}
+}
+
+// acceptCheckTx записывает в состояние проверки записи принятой
+// транзакции (ID → тело записи) и принимает её с приоритетом priority.
+func acceptCheckTx(st kv.Txn, records map[string]any, priority int64) abci.ResponseCheckTx {
+	for id, v := range records {
+		data, err := codec.EncodeRecord(v)
+		if err == nil {
+			err = st.Set([]byte(id), data)
+		}
+		if err != nil {
+			return abci.ResponseCheckTx{Code: 1, Log: err.Error()}
+		}
+	}
+	return abci.ResponseCheckTx{Code: 0, Priority: priority}
+}
+
+func (app *PromiseApp) BeginBlock(req abci.RequestBeginBlock) abci.ResponseBeginBlock {
 	if app.currentBatch != nil {
 		app.currentBatch.Discard()
 	}
-	app.currentBatch = app.db.NewTransaction(true)
+	app.currentBatch = kv.NewWriteBuffer(app.db)
+	app.validatorUpdates = nil
+	app.blockRates = rateCounts{}
+	app.height = req.Header.Height
+	app.blockTime = req.Header.Time.Unix()
+	app.pending = nil
+	app.completed = nil
 	return abci.ResponseBeginBlock{}
 }
 
 func (app *PromiseApp) DeliverTx(req abci.RequestDeliverTx) abci.ResponseDeliverTx {
+	tx, err := decodeTx(req.Tx)
+	if err != nil {
+		return abci.ResponseDeliverTx{Code: 1, Log: err.Error()}
+	}
+	cosigners, err := verifyCosignatures(app.chainID, tx)
+	if err != nil {
+		return abci.ResponseDeliverTx{Code: 1, Log: err.Error()}
+	}
+	if pow.Required(bodyType(tx)) {
+		if _, err := checkPow(app.chainID, tx); err != nil {
+			return abci.ResponseDeliverTx{Code: 9, Log: err.Error()}
+		}
+	}
+	// Голоса за изменение набора валидаторов
+	if isValidatorTxType(bodyType(tx)) {
+		return app.deliverValidatorTx(tx)
+	}
+	if bodyType(tx) == fulfillmentTxType {
+		return app.deliverFulfillmentTx(tx)
+	}
+
 	// Попытка композита
-	if compound, err := verifyCompoundTx(app.db, req.Tx); err == nil {
-		if app.currentBatch == nil {
-			app.currentBatch = app.db.NewTransaction(true)
+	if app.currentBatch == nil {
+		app.currentBatch = kv.NewWriteBuffer(app.db)
+		app.blockRates = rateCounts{}
+	}
+	if compound, err := verifyCompoundTx(app.currentBatch, app.chainID, tx); err == nil {
+		if c := compound.Commitment; c != nil {
+			if _, err := chargeRate(app.currentBatch, app.blockRates, c.CommiterID, app.height); err != nil {
+				return abci.ResponseDeliverTx{Code: 8, Log: err.Error()}
+			}
+		}
+		if p, c := compound.Promise, compound.Commitment; p != nil && c != nil {
+			if err := checkSealedText(app.currentBatch, p, c); err != nil {
+				return abci.ResponseDeliverTx{Code: 2, Log: err.Error()}
+			}
+			if err := checkAttachments(p); err != nil {
+				return abci.ResponseDeliverTx{Code: 2, Log: err.Error()}
+			}
+			if err := checkRecurrence(p, c); err != nil {
+				return abci.ResponseDeliverTx{Code: 2, Log: err.Error()}
+			}
+			if err := checkQuantity(p, c); err != nil {
+				return abci.ResponseDeliverTx{Code: 2, Log: err.Error()}
+			}
+			if len(c.Cosigners) != 0 {
+				return abci.ResponseDeliverTx{Code: 2, Log: errCosignersInBody.Error()}
+			}
 		}
-		if compound.Body.Promise != nil {
-			data, _ := json.Marshal(compound.Body.Promise)
-			if err := app.currentBatch.Set([]byte(compound.Body.Promise.ID), data); err != nil {
+		if compound.Promise != nil {
+			if err := app.putRecord(compound.Promise.ID, compound.Promise); err != nil {
 				return abci.ResponseDeliverTx{Code: 1, Log: "failed to save promise"}
 			}
 		}
-		if compound.Body.Commitment != nil {
-			data, _ := json.Marshal(compound.Body.Commitment)
-			if err := app.currentBatch.Set([]byte(compound.Body.Commitment.ID), data); err != nil {
+		if c := compound.Commitment; c != nil {
+			if len(cosigners) != 0 {
+				c.Cosigners = cosigners
+			}
+			if err := app.putRecord(c.ID, c); err != nil {
 				return abci.ResponseDeliverTx{Code: 1, Log: "failed to save commitment"}
 			}
 		}
+		if p := compound.Promise; p != nil && p.Recurrence != nil && compound.Commitment != nil {
+			if err := scheduleRecurrence(app.currentBatch, p, compound.Commitment, 1); err != nil {
+				return abci.ResponseDeliverTx{Code: 1, Log: err.Error()}
+			}
+		}
 		return abci.ResponseDeliverTx{Code: 0}
 	}
 
@@ -289,16 +401,15 @@ func (app *PromiseApp) DeliverTx(req abci.RequestDeliverTx) abci.ResponseDeliver
 			Body      types.CommiterTxBody `json:"body"`
 			Signature string               `json:"signature"`
 		}
-		if err := json.Unmarshal(req.Tx, &outer); err == nil && outer.Body.Type == "commiter" {
+		if err := json.Unmarshal(tx, &outer); err == nil && outer.Body.Type == "commiter" {
 			// сигнатуру проверяем прежней функцией
-			if _, vErr := verifyAndExtractBody(app.db, req.Tx); vErr != nil {
+			if _, vErr := verifyAndExtractBody(app.chainID, tx); vErr != nil {
 				return abci.ResponseDeliverTx{Code: 1, Log: vErr.Error()}
 			}
-			if app.currentBatch == nil {
-				app.currentBatch = app.db.NewTransaction(true)
+			if err := checkAdmission(app.currentBatch, tx); err != nil {
+				return abci.ResponseDeliverTx{Code: 10, Log: err.Error()}
 			}
-			data, _ := json.Marshal(outer.Body)
-			if err := app.currentBatch.Set([]byte(outer.Body.ID), data); err != nil {
+			if err := app.putRecord(outer.Body.ID, &outer.Body); err != nil {
 				return abci.ResponseDeliverTx{Code: 1, Log: err.Error()}
 			}
 			return abci.ResponseDeliverTx{Code: 0}
@@ -311,13 +422,9 @@ func (app *PromiseApp) DeliverTx(req abci.RequestDeliverTx) abci.ResponseDeliver
 			Body      types.BeneficiaryTxBody `json:"body"`
 			Signature string                  `json:"signature"`
 		}
-		if err := json.Unmarshal(req.Tx, &outer); err == nil && outer.Body.Type == "beneficiary" {
-			// (пока без проверки подписи — можно добавить политику позже)
-			if app.currentBatch == nil {
-				app.currentBatch = app.db.NewTransaction(true)
-			}
-			data, _ := json.Marshal(outer.Body)
-			if err := app.currentBatch.Set([]byte(outer.Body.ID), data); err != nil {
+		if err := json.Unmarshal(tx, &outer); err == nil && outer.Body.Type == "beneficiary" {
+			// Подпись не проверяется: ключа у бенефициара нет (см. docs/signing.md).
+			if err := app.putRecord(outer.Body.ID, &outer.Body); err != nil {
 				return abci.ResponseDeliverTx{Code: 1, Log: err.Error()}
 			}
 			return abci.ResponseDeliverTx{Code: 0}
@@ -327,7 +434,7 @@ func (app *PromiseApp) DeliverTx(req abci.RequestDeliverTx) abci.ResponseDeliver
 	return abci.ResponseDeliverTx{Code: 1, Log: "invalid tx format"}
 }
 
-func verifyCompoundTx(db *badger.DB, tx []byte) (*types.CompoundTx, error) {
+func verifyCompoundTx(r kv.Reader, chainID string, tx []byte) (*codec.Compound, error) {
 	// 1) Разобрать внешний конверт, body оставить сырым
 	var outerRaw compoundTxRaw
 	if err := json.Unmarshal(tx, &outerRaw); err != nil {
@@ -352,122 +459,142 @@ func verifyCompoundTx(db *badger.DB, tx []byte) (*types.CompoundTx, error) {
 	commiterID := tiny.Commitment.CommiterID
 
 	// 3) Достать коммитера из БД и получить публичный ключ
-	var commiterData []byte
-	err := db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(commiterID))
-		if err != nil {
-			return errors.New("unknown commiter")
-		}
-		return item.Value(func(v []byte) error {
-			commiterData = append([]byte{}, v...)
-			return nil
-		})
-	})
+	commiterData, err := r.Get([]byte(commiterID))
 	if err != nil {
-		return nil, err
+		return nil, errors.New("unknown commiter")
+	}
+	commiterJSON, err := codec.RecordJSON(commiterData)
+	if err != nil {
+		return nil, errors.New("corrupted commiter record")
 	}
 	var commiter types.CommiterTxBody
-	if err := json.Unmarshal(commiterData, &commiter); err != nil {
+	if err := json.Unmarshal(commiterJSON, &commiter); err != nil {
 		return nil, errors.New("corrupted commiter record")
 	}
 	pubkeyB64 := strings.TrimSpace(commiter.CommiterPubKey)
 	if pubkeyB64 == "" {
 		return nil, errors.New("missing commiter pubkey")
 	}
-	pubkey, err := base64.StdEncoding.DecodeString(pubkeyB64)
+	pubkey, err := decodeEd25519PubKey(pubkeyB64)
 	if err != nil {
-		return nil, errors.New("invalid pubkey base64")
-	}
-	if len(pubkey) != ed25519.PublicKeySize {
-		return nil, fmt.Errorf("invalid pubkey length: got %d, want %d", len(pubkey), ed25519.PublicKeySize)
+		return nil, err
 	}
 
-	// 4) Проверка подписи над СЫРЫМИ БАЙТАМИ body (как клиент подписывал)
-	sig, err := base64.StdEncoding.DecodeString(outerRaw.Signature)
-	if err != nil {
-		return nil, errors.New("invalid signature base64")
-	}
-	if len(sig) != ed25519.SignatureSize {
-		return nil, fmt.Errorf("invalid signature length: got %d, want %d", len(sig), ed25519.SignatureSize)
-	}
-	if !ed25519.Verify(pubkey, outerRaw.Body, sig) {
-		return nil, errors.New("signature verification failed")
+	// 4) Проверка подписи над каноническим body (см. пакет canonical)
+	if err := canonical.Verify(pubkey, outerRaw.Signature, chainID, "promise", outerRaw.Body); err != nil {
+		return nil, err
 	}
 
 	// 5) Только после успешной проверки — распарсить body в наши структуры
-	var body compoundBody
+	var body codec.Compound
 	if err := json.Unmarshal(outerRaw.Body, &body); err != nil {
 		return nil, errors.New("invalid body JSON")
 	}
-
-	// 6) Вернуть в привычной форме
-	return &types.CompoundTx{
-		Body: struct {
-			Promise    *types.PromiseTxBody    `json:"promise"`
-			Commitment *types.CommitmentTxBody `json:"commitment"`
-		}{
-			Promise:    body.Promise,
-			Commitment: body.Commitment,
-		},
-		Signature: outerRaw.Signature,
-	}, nil
+	return &body, nil
 }
 
 func (app *PromiseApp) Commit() abci.ResponseCommit {
+	// Состояние пока не хешируется: app hash пуст, как и до его сохранения.
+	appHash := []byte{}
 	if app.currentBatch != nil {
-		err := app.currentBatch.Commit()
-		if err != nil {
-			fmt.Printf("Commit error: %v\n", err)
+		ev := BlockEvent{Height: app.height, Records: app.pending, Completed: app.completed}
+		var err error
+		if len(ev.Records) > 0 {
+			err = storeEvent(app.currentBatch, ev)
+		}
+		if err == nil {
+			err = app.currentBatch.Set([]byte(heightKey), []byte(strconv.FormatInt(app.height, 10)))
+		}
+		if err == nil {
+			err = app.currentBatch.Set([]byte(appHashKey), appHash)
+		}
+		if err == nil {
+			err = app.currentBatch.Set([]byte(blockTimeKey), []byte(strconv.FormatInt(app.blockTime, 10)))
+		}
+		if err == nil {
+			err = app.currentBatch.Commit()
 		}
 		app.currentBatch = nil
+		if err != nil {
+			// Блок уже принят сетью: продолжать с незаписанным состоянием
+			// нельзя. Нода останавливается, и после перезапуска Tendermint
+			// повторит блок. Батч атомарен (у Badger — через журнал, см.
+			// kv.Open), поэтому половины блока в базе не остаётся, а
+			// meta:height указывает на последний целиком записанный блок.
+			panic(fmt.Errorf("commit block %d: %w", app.height, err))
+		}
+		if len(ev.Records) > 0 {
+			app.events.publish(ev)
+		}
 	}
-	return abci.ResponseCommit{Data: []byte{}}
+	app.pending = nil
+	app.completed = nil
+	app.resetCheckTxState()
+	return abci.ResponseCommit{Data: appHash}
 }
 
-func (app *PromiseApp) Query(req abci.RequestQuery) abci.ResponseQuery {
-	parts := strings.Split(strings.Trim(req.Path, "/"), "/")
-	if len(parts) != 2 || parts[0] != "list" {
-		return abci.ResponseQuery{Code: 1, Log: "unsupported query"}
-	}
-	prefix := []byte(parts[1] + ":")
-
-	var result []json.RawMessage
-	err := app.db.View(func(txn *badger.Txn) error {
-		it := txn.NewIterator(badger.DefaultIteratorOptions)
-		defer it.Close()
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			item := it.Item()
-			err := item.Value(func(v []byte) error {
-				var raw json.RawMessage = make([]byte, len(v))
-				copy(raw, v)
-				result = append(result, raw)
-				return nil
-			})
-			if err != nil {
-				return err
-			}
+// Info сообщает Tendermint высоту и app hash последнего зафиксированного
+// блока: при запуске он повторяет только блоки после неё, а InitChain
+// вызывает лишь для пустой базы (высота 0).
+func (app *PromiseApp) Info(req abci.RequestInfo) abci.ResponseInfo {
+	res := abci.ResponseInfo{Data: "promises", Version: "0.1"}
+	err := kv.View(app.db, func(r kv.Reader) error {
+		v, err := r.Get([]byte(heightKey))
+		if err == kv.ErrNotFound {
+			return nil
 		}
-		return nil
+		if err != nil {
+			return err
+		}
+		if res.LastBlockHeight, err = strconv.ParseInt(string(v), 10, 64); err != nil {
+			return err
+		}
+		res.LastBlockAppHash, err = r.Get([]byte(appHashKey))
+		if err == kv.ErrNotFound {
+			res.LastBlockAppHash, err = []byte{}, nil
+		}
+		return err
 	})
-
 	if err != nil {
-		return abci.ResponseQuery{Code: 1, Log: err.Error()}
+		// Без высоты Tendermint повторил бы всю цепочку поверх состояния.
+		panic(fmt.Errorf("read last block: %w", err))
 	}
-	all, _ := json.Marshal(result)
-	return abci.ResponseQuery{Code: 0, Value: all}
-}
-
-func (app *PromiseApp) Info(req abci.RequestInfo) abci.ResponseInfo {
-	return abci.ResponseInfo{Data: "promises", Version: "0.1"}
+	return res
 }
 func (app *PromiseApp) SetOption(req abci.RequestSetOption) abci.ResponseSetOption {
 	return abci.ResponseSetOption{}
 }
+
+// InitChain записывает chain_id, начальный набор валидаторов и состояние из
+// app_state. Большое состояние не помещается в одну транзакцию, поэтому
+// импорт не атомарен, но возобновляем: пока не зафиксирован первый блок,
+// Info отдаёт высоту 0, и после сбоя Tendermint вызывает InitChain снова.
+// Каждый шаг при повторе пишет те же ключи с теми же значениями, так что
+// повторный вызов доводит прерванный импорт до того же состояния.
 func (app *PromiseApp) InitChain(req abci.RequestInitChain) abci.ResponseInitChain {
+	app.chainID = req.ChainId
+	if err := kv.Update(app.db, func(txn kv.Txn) error {
+		return txn.Set([]byte(chainIDKey), []byte(req.ChainId))
+	}); err != nil {
+		panic(fmt.Errorf("store chain id: %w", err))
+	}
+	if err := app.storeGenesisValidators(req.Validators); err != nil {
+		panic(fmt.Errorf("store genesis validators: %w", err))
+	}
+	if err := app.loadGenesisState(req.AppStateBytes); err != nil {
+		panic(fmt.Errorf("load genesis app_state: %w", err))
+	}
 	return abci.ResponseInitChain{}
 }
 func (app *PromiseApp) EndBlock(req abci.RequestEndBlock) abci.ResponseEndBlock {
-	return abci.ResponseEndBlock{}
+	if app.currentBatch != nil {
+		if err := app.materializeRecurrences(app.blockTime); err != nil {
+			panic(fmt.Errorf("recurrences at height %d: %w", app.height, err))
+		}
+	}
+	updates := validatorUpdates(app.validatorUpdates)
+	app.validatorUpdates = nil
+	return abci.ResponseEndBlock{ValidatorUpdates: updates}
 }
 func (app *PromiseApp) ListSnapshots(req abci.RequestListSnapshots) abci.ResponseListSnapshots {
 	return abci.ResponseListSnapshots{}
@@ -0,0 +1,178 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gregorybednov/lbc/canonical"
+	"github.com/gregorybednov/lbc/codec"
+	"github.com/gregorybednov/lbc/kv"
+	"github.com/gregorybednov/lbc/sealed"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+// TestInfoAfterRestart: после перезапуска Info отдаёт высоту и app hash
+// последнего блока, иначе Tendermint заново вызвал бы InitChain и повторил
+// всю цепочку поверх уже записанного состояния.
+func TestInfoAfterRestart(t *testing.T) {
+	c := newTestChain(t)
+	if res := c.app.Info(abci.RequestInfo{}); res.LastBlockHeight != 0 {
+		t.Fatalf("fresh chain: height %d, want 0", res.LastBlockHeight)
+	}
+	for _, n := range []string{"1", "2", "3"} {
+		requireCodes(t, c.block(c.promiseTx(t, n)), 0)
+	}
+
+	app := NewPromiseApp(c.db)
+	res := app.Info(abci.RequestInfo{})
+	if res.LastBlockHeight != 3 {
+		t.Errorf("height %d, want 3", res.LastBlockHeight)
+	}
+	if res.LastBlockAppHash == nil || !bytes.Equal(res.LastBlockAppHash, c.appHash) {
+		t.Errorf("app hash %x, want %x", res.LastBlockAppHash, c.appHash)
+	}
+}
+
+// compoundTx — обещание n с обязательством коммитера, поля обещания
+// дополняются extra.
+func (c *testChain) compoundTx(t *testing.T, n string, extra map[string]any) []byte {
+	t.Helper()
+	promise := map[string]any{
+		"type": "promise", "id": "promise:" + n, "text": "t" + n,
+		"due": 4000000000, "beneficiary_id": "beneficiary:1",
+	}
+	for k, v := range extra {
+		promise[k] = v
+	}
+	commitment := map[string]any{
+		"type": "commitment", "id": "commitment:" + n, "promise_id": "promise:" + n,
+		"commiter_id": c.commiterID, "due": 4000000000,
+	}
+	return c.signTx(t, "promise", map[string]any{"promise": promise, "commitment": commitment})
+}
+
+// TestDeliverTxChecksPromise: DeliverTx проверяет тело обещания так же, как
+// CheckTx, — транзакция, попавшая в блок в обход мемпула своей ноды, не
+// должна записать то, что CheckTx отверг бы.
+func TestDeliverTxChecksPromise(t *testing.T) {
+	c := newTestChain(t)
+	commiterPub := c.priv.Public().(ed25519.PublicKey)
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealedFor := func(pubs ...ed25519.PublicKey) string {
+		text, err := sealed.Seal("secret", pubs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return text
+	}
+	hash := "sha256:" + strings.Repeat("ab", 32)
+	tests := []struct {
+		name  string
+		extra map[string]any
+		code  uint32
+	}{
+		{"sealed for the commiter", map[string]any{"text": sealedFor(commiterPub, otherPub)}, 0},
+		{"sealed without the commiter", map[string]any{"text": sealedFor(otherPub)}, 2},
+		{"malformed envelope", map[string]any{"text": sealed.Prefix + "garbage"}, 2},
+		{"attachment", map[string]any{"attachments": []string{hash}}, 0},
+		{"duplicate attachment", map[string]any{"attachments": []string{hash, hash}}, 2},
+		{"malformed attachment", map[string]any{"attachments": []string{"md5:00"}}, 2},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := c.compoundTx(t, strconv.Itoa(i+1), tt.extra)
+			if res := c.app.CheckTx(abci.RequestCheckTx{Tx: tx}); res.Code != tt.code {
+				t.Errorf("CheckTx: code %d (%s), want %d", res.Code, res.Log, tt.code)
+			}
+			requireCodes(t, c.block(tx), tt.code)
+		})
+	}
+}
+
+// TestQueryList: list/<kind> перечисляет только записи реестра, служебные
+// ключи через него не читаются.
+func TestQueryList(t *testing.T) {
+	c := newTestChain(t)
+	requireCodes(t, c.block(c.promiseTx(t, "1")), 0)
+
+	res := c.app.Query(abci.RequestQuery{Path: "list/promise"})
+	if res.Code != 0 || !bytes.Contains(res.Value, []byte(`"promise:1"`)) {
+		t.Errorf("list/promise: code %d (%s), value %s", res.Code, res.Log, res.Value)
+	}
+	for _, kind := range []string{"meta", "validator", "progress", "event", "ratelimit", "local", "prom"} {
+		if res := c.app.Query(abci.RequestQuery{Path: "list/" + kind}); res.Code != 1 {
+			t.Errorf("list/%s: code %d, want 1", kind, res.Code)
+		}
+	}
+	if res := c.app.Query(abci.RequestQuery{Path: "validators"}); res.Code != 0 || string(res.Value) != "[]" {
+		t.Errorf("validators: code %d (%s), value %s", res.Code, res.Log, res.Value)
+	}
+}
+
+// TestCommitmentCosigners: ключи проверенных cosignatures сохраняются в
+// обязательстве, а заданные в теле транзакции — отвергаются.
+func TestCommitmentCosigners(t *testing.T) {
+	c := newTestChain(t)
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cosigner := base64.StdEncoding.EncodeToString(pub)
+	cosign := func(tx []byte) []byte {
+		t.Helper()
+		var env map[string]json.RawMessage
+		if err := json.Unmarshal(tx, &env); err != nil {
+			t.Fatal(err)
+		}
+		sig, err := canonical.Sign(priv, testChainID, "promise", env["body"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		env["cosignatures"], _ = json.Marshal([]cosignature{{PubKey: cosigner, Signature: sig}})
+		out, _ := json.Marshal(env)
+		return out
+	}
+
+	requireCodes(t, c.block(cosign(c.promiseTx(t, "1")), c.promiseTx(t, "2")), 0, 0)
+	for id, want := range map[string][]string{"commitment:1": {cosigner}, "commitment:2": nil} {
+		value, err := c.app.getRecord(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got codec.Commitment
+		if err := json.Unmarshal(value, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got.Cosigners, want) {
+			t.Errorf("%s: cosigners %v, want %v", id, got.Cosigners, want)
+		}
+	}
+
+	forged := c.signTx(t, "promise", map[string]any{
+		"promise": map[string]any{
+			"type": "promise", "id": "promise:3", "text": "t3",
+			"due": 4000000000, "beneficiary_id": "beneficiary:1",
+		},
+		"commitment": map[string]any{
+			"type": "commitment", "id": "commitment:3", "promise_id": "promise:3",
+			"commiter_id": c.commiterID, "due": 4000000000, "cosigners": []string{cosigner},
+		},
+	})
+	if res := c.app.CheckTx(abci.RequestCheckTx{Tx: forged}); res.Code != 2 {
+		t.Errorf("CheckTx: code %d (%s), want 2", res.Code, res.Log)
+	}
+	requireCodes(t, c.block(forged), 2)
+	if _, err := c.app.getRecord("promise:3"); err != kv.ErrNotFound {
+		t.Errorf("promise:3 stored: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/gregorybednov/lbc/kv"
+	"github.com/gregorybednov/lbc/pow"
+)
+
+// Защита от спама без токена. Зарегистрированный коммитер может отправить не
+// больше rateLimit транзакций за окно из rateWindow блоков; счётчики лежат в
+// базе и меняются в DeliverTx, поэтому одинаковы на всех узлах. Регистрации
+// (подписант ещё не в реестре) вместо квоты требуют доказательства работы
+// (пакет pow). Значения — правила консенсуса, как и pow.Difficulty.
+const (
+	rateLimitPrefix = "ratelimit:"
+	rateWindow      = 100
+	rateLimit       = 50
+)
+
+// Приоритет в mempool (ResponseCheckTx.Priority): голоса валидаторов — первыми,
+// затем транзакции коммитеров по остатку квоты и регистрации по лишним битам
+// работы.
+const validatorTxPriority = math.MaxInt64
+
+// rateKey — число транзакций коммитера в блоке height. Счётчик на каждый
+// блок, а не один на окно: повтор блока после остановки перезаписывает его
+// тем же значением, а не прибавляет к нему второй раз.
+func rateKey(commiterID string, height int64) []byte {
+	return []byte(fmt.Sprintf("%s%s:%020d", rateLimitPrefix, commiterID, height))
+}
+
+// rateCounts — транзакции коммитеров в блоке, который сейчас собирается.
+// Счёт ведётся в памяти, а не читается из базы: при повторе блока в базе
+// уже лежит его прежний счётчик.
+type rateCounts map[string]int
+
+// chargeRate учитывает транзакцию коммитера в блоке height и возвращает
+// остаток квоты в текущем окне. counts — транзакции этого блока, учтённые
+// до неё. Счётчики блоков прошлых окон удаляются.
+func chargeRate(txn kv.Txn, counts rateCounts, commiterID string, height int64) (int, error) {
+	start := height / rateWindow * rateWindow
+	prefix := []byte(rateLimitPrefix + commiterID + ":")
+	used := 0
+	var stale [][]byte
+	err := txn.Iterate(prefix, nil, func(key, value []byte) error {
+		h, err := strconv.ParseInt(string(key[len(prefix):]), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		switch {
+		case h < start:
+			stale = append(stale, key)
+		case h < height:
+			n, err := strconv.Atoi(string(value))
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			used += n
+		default:
+			return errStopIteration
+		}
+		return nil
+	})
+	if err != nil && err != errStopIteration {
+		return 0, err
+	}
+	for _, key := range stale {
+		if err := txn.Delete(key); err != nil {
+			return 0, err
+		}
+	}
+	n := counts[commiterID]
+	if used+n >= rateLimit {
+		return 0, fmt.Errorf("rate limit exceeded: %d transactions per %d blocks", rateLimit, rateWindow)
+	}
+	counts[commiterID] = n + 1
+	return rateLimit - used - n - 1, txn.Set(rateKey(commiterID, height), []byte(strconv.Itoa(n+1)))
+}
+
+// checkPow проверяет pow_nonce конверта регистрации и возвращает приоритет:
+// число бит работы сверх pow.Difficulty.
+func checkPow(chainID string, tx []byte) (int64, error) {
+	var env struct {
+		Body     json.RawMessage `json:"body"`
+		PowNonce uint64          `json:"pow_nonce"`
+	}
+	if err := json.Unmarshal(tx, &env); err != nil {
+		return 0, errors.New("invalid tx JSON")
+	}
+	zeros, err := pow.Check(chainID, txKind(env.Body), env.Body, env.PowNonce)
+	if err != nil {
+		return 0, err
+	}
+	return int64(zeros - pow.Difficulty), nil
+}
@@ -0,0 +1,63 @@
+package blockchain
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRateLimit(t *testing.T) {
+	type block struct {
+		height int64
+		txs    int
+		replay bool // повтор предыдущего блока новым экземпляром приложения
+	}
+	cases := []struct {
+		name     string
+		blocks   []block
+		rejected int // сколько транзакций последнего блока отклонено с кодом 8
+	}{
+		{"limit in one block", []block{{1, rateLimit, false}}, 0},
+		{"over limit in one block", []block{{1, rateLimit + 1, false}}, 1},
+		{"limit across blocks of a window", []block{{1, 30, false}, {2, 21, false}}, 1},
+		{"next window starts anew", []block{{99, rateLimit, false}, {100, rateLimit, false}}, 0},
+		{"previous window is forgotten", []block{{99, rateLimit, false}, {100, 1, false}, {101, rateLimit, false}}, 1},
+		{"replayed block is counted once", []block{{1, 30, false}, {1, 30, true}, {2, 20, false}}, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestChain(t)
+			n := 0
+			var txs [][]byte
+			for i, b := range tc.blocks {
+				app := c.app
+				if b.replay {
+					app = NewPromiseApp(c.db)
+				} else {
+					txs = nil
+					for j := 0; j < b.txs; j++ {
+						n++
+						txs = append(txs, c.promiseTx(t, fmt.Sprint(n)))
+					}
+				}
+				res, _ := deliverBlock(app, b.height, txs...)
+				rejected := 0
+				for _, r := range res {
+					switch r.Code {
+					case 0:
+					case 8:
+						rejected++
+					default:
+						t.Fatalf("block %d: code %d (%s)", b.height, r.Code, r.Log)
+					}
+				}
+				want := 0
+				if i == len(tc.blocks)-1 {
+					want = tc.rejected
+				}
+				if rejected != want {
+					t.Fatalf("block %d: %d rejected, want %d", b.height, rejected, want)
+				}
+			}
+		})
+	}
+}
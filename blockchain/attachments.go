@@ -0,0 +1,31 @@
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/gregorybednov/lbc/blobstore"
+	"github.com/gregorybednov/lbc/codec"
+)
+
+// maxAttachments — предел числа вложений одного обещания.
+const maxAttachments = 16
+
+// checkAttachments проверяет поле attachments: хеши sha256:<hex> без
+// повторов. Наличие самих файлов не проверяется: они хранятся вне цепочки
+// (пакет blobstore) и у разных нод могут быть разными.
+func checkAttachments(p *codec.Promise) error {
+	if len(p.Attachments) > maxAttachments {
+		return fmt.Errorf("too many attachments: %d, max %d", len(p.Attachments), maxAttachments)
+	}
+	seen := make(map[string]bool, len(p.Attachments))
+	for _, a := range p.Attachments {
+		if _, err := blobstore.ParseHash(a); err != nil {
+			return err
+		}
+		if seen[a] {
+			return fmt.Errorf("duplicate attachment %s", a)
+		}
+		seen[a] = true
+	}
+	return nil
+}
@@ -0,0 +1,552 @@
+package blockchain
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gregorybednov/lbc/canonical"
+	"github.com/gregorybednov/lbc/codec"
+	"github.com/gregorybednov/lbc/kv"
+	types "github.com/gregorybednov/lbc_sdk"
+)
+
+// Выгрузка реестра (lbc export / lbc import). Формат версионирован:
+// заголовок DumpHeader и записи в порядке ID — либо одним JSON-объектом
+// (он же кладётся в app_state genesis.json), либо NDJSON: первая строка —
+// заголовок, далее по записи в строке. Версия 1 отличается только именем
+// поля state_hash (app_hash) и по-прежнему читается.
+const (
+	DumpFormat  = "lbc-dump"
+	DumpVersion = 2
+)
+
+// heightKey — высота последнего зафиксированного блока, appHashKey — его
+// app hash (ответ Commit). Оба пишутся в батче блока и отдаются в Info, по
+// ним Tendermint при запуске решает, какие блоки повторить.
+const (
+	heightKey  = "meta:height"
+	appHashKey = "meta:app_hash"
+)
+
+// RecordKinds — виды записей реестра, попадающие в выгрузку.
+var RecordKinds = []string{"commiter", "beneficiary", "promise", "commitment", "fulfillment"}
+
+type DumpHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+	ChainID string `json:"chain_id"`
+	Height  int64  `json:"height"`
+	// StateHash — контрольная сумма записей выгрузки (функция StateHash),
+	// hex. Есть только в выгрузке: это не app hash цепочки, который Commit
+	// пока оставляет пустым.
+	StateHash string `json:"state_hash"`
+	Count     int    `json:"count"`
+	// Params — параметры приложения; в state_hash не входят.
+	Params *Params `json:"params,omitempty"`
+}
+
+type Dump struct {
+	DumpHeader
+	Records []Record `json:"records"`
+}
+
+// StateHash — SHA-256 над записями в порядке ID: для каждой длина и байты
+// ID, затем длина и байты канонического JSON значения. Не зависит от формы
+// хранения (JSON или protobuf), поэтому совпадает у выгрузки и базы.
+func StateHash(records []Record) (string, error) {
+	sorted := make([]Record, len(records))
+	copy(sorted, records)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	h := sha256.New()
+	var n [binary.MaxVarintLen64]byte
+	for _, r := range sorted {
+		value, err := canonical.Canonicalize(r.Value)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", r.ID, err)
+		}
+		h.Write(n[:binary.PutUvarint(n[:], uint64(len(r.ID)))])
+		h.Write([]byte(r.ID))
+		h.Write(n[:binary.PutUvarint(n[:], uint64(len(value)))])
+		h.Write(value)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// LastHeight — высота последнего зафиксированного блока; 0, если нода ещё
+// не фиксировала блоков этой версией.
+func (app *PromiseApp) LastHeight() (int64, error) {
+	var height int64
+	err := kv.View(app.db, func(r kv.Reader) error {
+		v, err := r.Get([]byte(heightKey))
+		if err == kv.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		height, err = strconv.ParseInt(string(v), 10, 64)
+		return err
+	})
+	return height, err
+}
+
+// Export собирает выгрузку реестра на высоте height (0 — последний блок).
+// Текущее состояние читается из базы; прошлое восстанавливается по журналу
+// событий блоков, поэтому требует, чтобы журнал покрывал все записи.
+func (app *PromiseApp) Export(height int64) (*Dump, error) {
+	last, err := app.LastHeight()
+	if err != nil {
+		return nil, err
+	}
+	if last == 0 {
+		return nil, errors.New("last committed height is unknown: start the node to let it commit a block first")
+	}
+	if height == 0 {
+		height = last
+	}
+	if height < 0 || height > last {
+		return nil, fmt.Errorf("height %d is out of range: last committed height is %d", height, last)
+	}
+
+	var records []Record
+	var params Params
+	err = app.View(func(s *Snapshot) error {
+		var err error
+		if params, err = loadParams(s.r); err != nil {
+			return err
+		}
+		var live []Record
+		for _, kind := range RecordKinds {
+			if err := s.scanRecords(kind+":", func(r Record) error {
+				live = append(live, r)
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		if height == last {
+			records = live
+			return nil
+		}
+
+		byID := map[string]Record{}
+		seen := map[string]bool{}
+		if err := s.scanEvents(func(ev BlockEvent) error {
+			for _, r := range ev.Records {
+				seen[r.ID] = true
+				if ev.Height <= height {
+					byID[r.ID] = r
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, r := range live {
+			if !seen[r.ID] {
+				return fmt.Errorf("record %s predates the event log: only the last height %d can be exported", r.ID, last)
+			}
+		}
+		for _, r := range byID {
+			records = append(records, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+
+	hash, err := StateHash(records)
+	if err != nil {
+		return nil, err
+	}
+	return &Dump{
+		DumpHeader: DumpHeader{
+			Format:    DumpFormat,
+			Version:   DumpVersion,
+			ChainID:   app.chainID,
+			Height:    height,
+			StateHash: hash,
+			Count:     len(records),
+			Params:    &params,
+		},
+		Records: records,
+	}, nil
+}
+
+// scanRecords вызывает fn для записей с префиксом prefix в виде Record.
+func (s *Snapshot) scanRecords(prefix string, fn func(r Record) error) error {
+	return s.r.Iterate([]byte(prefix), nil, func(key, v []byte) error {
+		id := string(key)
+		v, err := codec.RecordJSON(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		return fn(Record{ID: id, Kind: recordKind(id), Value: v})
+	})
+}
+
+// scanEvents вызывает fn для всех сохранённых событий, включая событие
+// genesis на высоте 0.
+func (s *Snapshot) scanEvents(fn func(ev BlockEvent) error) error {
+	return s.r.Iterate([]byte(eventPrefix), nil, func(_, v []byte) error {
+		var ev BlockEvent
+		if err := json.Unmarshal(v, &ev); err != nil {
+			return err
+		}
+		return fn(ev)
+	})
+}
+
+// Verify проверяет целостность выгрузки: формат, число записей, state_hash,
+// уникальность ID и связи между записями по ER-модели.
+func (d *Dump) Verify() error {
+	if d.Format != DumpFormat {
+		return fmt.Errorf("not an lbc dump (format %q)", d.Format)
+	}
+	if d.Version != DumpVersion {
+		return fmt.Errorf("unsupported dump version %d", d.Version)
+	}
+	if d.Count != len(d.Records) {
+		return fmt.Errorf("dump declares %d records, contains %d", d.Count, len(d.Records))
+	}
+	hash, err := StateHash(d.Records)
+	if err != nil {
+		return err
+	}
+	if hash != d.StateHash {
+		return fmt.Errorf("state hash mismatch: dump declares %s, records hash to %s", d.StateHash, hash)
+	}
+
+	kinds := map[string]string{}
+	for _, r := range d.Records {
+		if _, dup := kinds[r.ID]; dup {
+			return fmt.Errorf("duplicate record %s", r.ID)
+		}
+		if r.Kind != recordKind(r.ID) {
+			return fmt.Errorf("record %s has kind %q", r.ID, r.Kind)
+		}
+		kinds[r.ID] = r.Kind
+	}
+	exists := func(id, kind string) bool { return kinds[id] == kind }
+
+	for _, r := range d.Records {
+		if err := verifyDumpRecord(r, exists); err != nil {
+			return fmt.Errorf("%s: %w", r.ID, err)
+		}
+	}
+	return nil
+}
+
+// decodeRecord разбирает JSON-значение записи в тип SDK по её виду.
+func decodeRecord(r Record) (any, error) {
+	var v any
+	switch r.Kind {
+	case "commiter":
+		v = &types.CommiterTxBody{}
+	case "beneficiary":
+		v = &types.BeneficiaryTxBody{}
+	case "promise":
+		v = &codec.Promise{}
+	case "commitment":
+		v = &codec.Commitment{}
+	case "fulfillment":
+		v = &codec.Fulfillment{}
+	default:
+		return nil, fmt.Errorf("unknown record kind %q", r.Kind)
+	}
+	if err := json.Unmarshal(r.Value, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func verifyDumpRecord(r Record, exists func(id, kind string) bool) error {
+	v, err := decodeRecord(r)
+	if err != nil {
+		return err
+	}
+	// Запись должна переживать разбор без потерь: иначе база нового узла
+	// разойдётся с state_hash выгрузки.
+	got, err := canonical.Marshal(v)
+	if err != nil {
+		return err
+	}
+	want, err := canonical.Canonicalize(r.Value)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, want) {
+		return errors.New("record has fields unknown to this version")
+	}
+	switch b := v.(type) {
+	case *types.CommiterTxBody:
+		if b.ID != r.ID {
+			return errors.New("id does not match the key")
+		}
+		if _, err := decodeEd25519PubKey(b.CommiterPubKey); err != nil {
+			return err
+		}
+	case *types.BeneficiaryTxBody:
+		if b.ID != r.ID {
+			return errors.New("id does not match the key")
+		}
+		if strings.TrimSpace(b.Name) == "" {
+			return errors.New("beneficiary.name is required")
+		}
+	case *codec.Promise:
+		if b.ID != r.ID {
+			return errors.New("id does not match the key")
+		}
+		if strings.TrimSpace(b.Text) == "" {
+			return errors.New("promise.text is required")
+		}
+		if !exists(b.BeneficiaryID, "beneficiary") {
+			return fmt.Errorf("unknown beneficiary %s", b.BeneficiaryID)
+		}
+		if b.ParentPromiseID != nil && (*b.ParentPromiseID == b.ID || !exists(*b.ParentPromiseID, "promise")) {
+			return fmt.Errorf("invalid parent promise %s", *b.ParentPromiseID)
+		}
+		if err := checkAttachments(b); err != nil {
+			return err
+		}
+		if b.Recurrence != nil && b.Recurrence.Period < minRecurrencePeriod {
+			return fmt.Errorf("recurrence.period must be at least %d seconds", minRecurrencePeriod)
+		}
+		if b.Quantity < 0 || (b.Quantity == 0) != (b.Unit == "") {
+			return errors.New("invalid promise quantity or unit")
+		}
+	case *codec.Commitment:
+		if b.ID != r.ID {
+			return errors.New("id does not match the key")
+		}
+		if !exists(b.PromiseID, "promise") {
+			return fmt.Errorf("unknown promise %s", b.PromiseID)
+		}
+		if !exists(b.CommiterID, "commiter") {
+			return fmt.Errorf("unknown commiter %s", b.CommiterID)
+		}
+		if b.Quantity < 0 || (b.Quantity == 0) != (b.Unit == "") {
+			return errors.New("invalid commitment quantity or unit")
+		}
+	case *codec.Fulfillment:
+		if b.ID != r.ID {
+			return errors.New("id does not match the key")
+		}
+		if !exists(b.CommitmentID, "commitment") {
+			return fmt.Errorf("unknown commitment %s", b.CommitmentID)
+		}
+		if b.Quantity < 0 {
+			return errors.New("fulfillment.quantity must not be negative")
+		}
+	}
+	return nil
+}
+
+// WriteJSON пишет выгрузку одним JSON-объектом.
+func (d *Dump) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// WriteNDJSON пишет заголовок и записи по строке.
+func (d *Dump) WriteNDJSON(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	if err := enc.Encode(d.DumpHeader); err != nil {
+		return err
+	}
+	for _, r := range d.Records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadDump читает выгрузку в любом из двух форматов.
+func ReadDump(r io.Reader) (*Dump, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var d Dump
+	if err := dec.Decode(&d); err != nil {
+		return nil, fmt.Errorf("dump header: %w", err)
+	}
+	if d.Format == DumpFormat && d.Version == 1 {
+		var v1 struct {
+			AppHash string `json:"app_hash"`
+		}
+		if err := json.NewDecoder(bytes.NewReader(data)).Decode(&v1); err != nil {
+			return nil, fmt.Errorf("dump header: %w", err)
+		}
+		d.Version, d.StateHash = DumpVersion, v1.AppHash
+	}
+	if d.Records != nil {
+		if dec.More() {
+			return nil, errors.New("trailing data after JSON dump")
+		}
+		return &d, nil
+	}
+	d.Records = []Record{}
+	for dec.More() {
+		var rec Record
+		if err := dec.Decode(&rec); err != nil {
+			return nil, fmt.Errorf("dump record %d: %w", len(d.Records)+1, err)
+		}
+		d.Records = append(d.Records, rec)
+	}
+	return &d, nil
+}
+
+// genesisChunkBytes — предел объёма одной транзакции импорта и одного
+// события высоты 0. Выгрузка может быть сколь угодно большой, а Badger
+// отвергает транзакции больше ~15% memtable (ErrTxnTooBig).
+const genesisChunkBytes = 4 << 20
+
+// chunkedWriter пишет в db транзакциями не больше genesisChunkBytes.
+// Записанное до сбоя остаётся в базе: пользоваться им можно только там, где
+// повтор всей записи безопасен (см. InitChain).
+type chunkedWriter struct {
+	db   kv.Store
+	txn  kv.Txn
+	size int
+}
+
+func (w *chunkedWriter) Set(key, value []byte) error {
+	if w.txn == nil {
+		w.txn = w.db.NewTxn()
+	}
+	if err := w.txn.Set(key, value); err != nil {
+		return err
+	}
+	return w.grow(len(key) + len(value))
+}
+
+func (w *chunkedWriter) Delete(key []byte) error {
+	if w.txn == nil {
+		w.txn = w.db.NewTxn()
+	}
+	if err := w.txn.Delete(key); err != nil {
+		return err
+	}
+	return w.grow(len(key))
+}
+
+func (w *chunkedWriter) grow(n int) error {
+	if w.size += n; w.size < genesisChunkBytes {
+		return nil
+	}
+	return w.Flush()
+}
+
+// Flush фиксирует накопленную транзакцию.
+func (w *chunkedWriter) Flush() error {
+	txn := w.txn
+	if txn == nil {
+		return nil
+	}
+	w.txn, w.size = nil, 0
+	defer txn.Discard()
+	return txn.Commit()
+}
+
+// genesisEvents делит записи выгрузки на события высоты 0 объёмом не
+// больше genesisChunkBytes/2 — каждое пишется отдельным ключом (см.
+// genesisEventKey), а не одним значением на всю выгрузку.
+func genesisEvents(records []Record) []BlockEvent {
+	var events []BlockEvent
+	var cur []Record
+	size := 0
+	for _, r := range records {
+		n := len(r.ID) + len(r.Value)
+		if len(cur) > 0 && size+n > genesisChunkBytes/2 {
+			events = append(events, BlockEvent{Height: 0, Records: cur})
+			cur, size = nil, 0
+		}
+		cur = append(cur, r)
+		size += n
+	}
+	if len(cur) > 0 {
+		events = append(events, BlockEvent{Height: 0, Records: cur})
+	}
+	return events
+}
+
+// loadGenesisState записывает в базу записи из app_state genesis.json
+// (выгрузку, подготовленную lbc import) и сохраняет их как события высоты 0.
+// app_state без записей может содержать одни параметры: {"params": {...}}.
+// Запись идёт частями (chunkedWriter) и при повторе даёт то же состояние.
+func (app *PromiseApp) loadGenesisState(appState []byte) error {
+	trimmed := bytes.TrimSpace(appState)
+	if len(trimmed) == 0 || string(trimmed) == "null" || string(trimmed) == "{}" || string(trimmed) == `""` {
+		return nil
+	}
+	var head DumpHeader
+	if err := json.Unmarshal(trimmed, &head); err != nil {
+		return err
+	}
+	if head.Format == "" {
+		return storeParams(app.db, head.Params)
+	}
+	d, err := ReadDump(bytes.NewReader(trimmed))
+	if err != nil {
+		return err
+	}
+	if err := d.Verify(); err != nil {
+		return err
+	}
+	if err := storeParams(app.db, d.Params); err != nil {
+		return err
+	}
+	w := &chunkedWriter{db: app.db}
+	defer func() {
+		if w.txn != nil {
+			w.txn.Discard()
+		}
+	}()
+	for _, r := range d.Records {
+		v, err := decodeRecord(r)
+		if err != nil {
+			return err
+		}
+		data, err := codec.EncodeRecord(v)
+		if err != nil {
+			return err
+		}
+		if err := w.Set([]byte(r.ID), data); err != nil {
+			return err
+		}
+	}
+	if err := rebuildRecurrences(w, d.Records); err != nil {
+		return err
+	}
+	if err := rebuildProgress(w, d.Records); err != nil {
+		return err
+	}
+	for i, ev := range genesisEvents(d.Records) {
+		data, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		if err := w.Set(genesisEventKey(i), data); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
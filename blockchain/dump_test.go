@@ -0,0 +1,196 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gregorybednov/lbc/codec"
+	"github.com/gregorybednov/lbc/kv"
+	types "github.com/gregorybednov/lbc_sdk"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+// TestDumpStateHash: выгрузка несёт state_hash записей, а выгрузки версии 1
+// с тем же значением в app_hash по-прежнему читаются.
+func TestDumpStateHash(t *testing.T) {
+	c := newTestChain(t)
+	requireCodes(t, c.block(c.promiseTx(t, "1")), 0)
+	d, err := c.app.Export(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Version != DumpVersion || d.StateHash == "" {
+		t.Fatalf("header %+v", d.DumpHeader)
+	}
+	var buf bytes.Buffer
+	if err := d.WriteNDJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	ndjson := buf.String()
+	if !strings.Contains(ndjson, `"state_hash":"`+d.StateHash+`"`) {
+		t.Fatalf("no state_hash in %s", ndjson)
+	}
+
+	v1 := strings.Replace(ndjson, `"version":2`, `"version":1`, 1)
+	v1 = strings.Replace(v1, `"state_hash":`, `"app_hash":`, 1)
+	for name, text := range map[string]string{"v2": ndjson, "v1": v1} {
+		got, err := ReadDump(strings.NewReader(text))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := got.Verify(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	tampered := strings.Replace(ndjson, d.StateHash, strings.Repeat("0", len(d.StateHash)), 1)
+	got, err := ReadDump(strings.NewReader(tampered))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := got.Verify(); err == nil || !strings.Contains(err.Error(), "state hash mismatch") {
+		t.Errorf("tampered dump: %v", err)
+	}
+}
+
+// bigDump — выгрузка из n обещаний с обязательствами и отметками, заметно
+// больше genesisChunkBytes.
+func bigDump(t *testing.T, n int) *Dump {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk := base64.StdEncoding.EncodeToString(pub)
+	commiterID := "commiter:" + pk
+	var records []Record
+	add := func(id string, v any) {
+		value, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, Record{ID: id, Kind: recordKind(id), Value: value})
+	}
+	add(commiterID, &types.CommiterTxBody{Type: "commiter", ID: commiterID, Name: "A", CommiterPubKey: pk})
+	add("beneficiary:1", &types.BeneficiaryTxBody{Type: "beneficiary", ID: "beneficiary:1", Name: "B"})
+	text := strings.Repeat("x", 5000)
+	for i := range n {
+		p := &codec.Promise{PromiseTxBody: types.PromiseTxBody{
+			Type: "promise", ID: fmt.Sprintf("promise:%d", i), Text: text, Due: 4000000000, BeneficiaryID: "beneficiary:1",
+		}, Quantity: 2, Unit: "kg"}
+		c := &codec.Commitment{CommitmentTxBody: types.CommitmentTxBody{
+			Type: "commitment", ID: fmt.Sprintf("commitment:%d", i), PromiseID: p.ID, CommiterID: commiterID, Due: 4000000000,
+		}, Quantity: 2, Unit: "kg"}
+		add(p.ID, p)
+		add(c.ID, c)
+		add(fmt.Sprintf("fulfillment:%d", i), &codec.Fulfillment{Type: "fulfillment", ID: fmt.Sprintf("fulfillment:%d", i), CommitmentID: c.ID, Quantity: 1})
+	}
+	hash, err := StateHash(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &Dump{
+		DumpHeader: DumpHeader{Format: DumpFormat, Version: DumpVersion, ChainID: testChainID, StateHash: hash, Count: len(records)},
+		Records:    records,
+	}
+	if err := d.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// failingStore — хранилище, у которого после commits успешных Commit
+// транзакции перестают фиксироваться.
+type failingStore struct {
+	kv.Store
+	commits int
+}
+
+type failingTxn struct {
+	kv.Txn
+	s *failingStore
+}
+
+func (s *failingStore) NewTxn() kv.Txn { return failingTxn{s.Store.NewTxn(), s} }
+
+func (t failingTxn) Commit() error {
+	if t.s.commits == 0 {
+		return errors.New("disk full")
+	}
+	t.s.commits--
+	return t.Txn.Commit()
+}
+
+// TestGenesisImportResumes: выгрузка больше одной транзакции пишется
+// частями, а InitChain, прерванный посередине импорта, при повторе
+// приводит базу к тому же состоянию, что и импорт без сбоя.
+func TestGenesisImportResumes(t *testing.T) {
+	d := bigDump(t, 1000)
+	var buf bytes.Buffer
+	if err := d.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	req := abci.RequestInitChain{ChainId: testChainID, AppStateBytes: buf.Bytes()}
+
+	full := kv.NewMemory()
+	NewPromiseApp(full).InitChain(req)
+	want, err := Summarize(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := kv.NewMemory()
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("InitChain did not fail")
+			}
+		}()
+		// chain_id, валидаторы и первая часть записей
+		NewPromiseApp(&failingStore{Store: db, commits: 3}).InitChain(req)
+	}()
+	partial, err := Summarize(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := partial.Kinds["promise"]; n == 0 || n == 1000 {
+		t.Fatalf("interrupted import wrote %d of 1000 promises", n)
+	}
+
+	NewPromiseApp(db).InitChain(req)
+	got, err := Summarize(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Checksum != want.Checksum || got.StateHash != d.StateHash {
+		t.Errorf("resumed import: %+v, want %+v", got, want)
+	}
+
+	var events, records int
+	if err := kv.View(db, func(r kv.Reader) error {
+		return r.Iterate(eventKey(0), nil, func(_, v []byte) error {
+			var ev BlockEvent
+			if err := json.Unmarshal(v, &ev); err != nil {
+				return err
+			}
+			if len(v) > genesisChunkBytes {
+				t.Errorf("genesis event of %d bytes", len(v))
+			}
+			events++
+			records += len(ev.Records)
+			return nil
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if events < 2 || records != len(d.Records) {
+		t.Errorf("genesis events: %d with %d records, want several with %d", events, records, len(d.Records))
+	}
+}
@@ -0,0 +1,39 @@
+package blockchain
+
+import (
+	"encoding/json"
+
+	"github.com/gregorybednov/lbc/codec"
+)
+
+// decodeTx приводит транзакцию к JSON-конверту: бинарная (первый байт
+// codec.VersionProto) переводится, JSON возвращается как есть. Дальше обе
+// проверяются одним кодом, подпись — над тем же каноническим body.
+func decodeTx(tx []byte) ([]byte, error) {
+	if !codec.IsProto(tx) {
+		return tx, nil
+	}
+	env, err := codec.DecodeTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	return env.JSON()
+}
+
+// putRecord сохраняет запись реестра в текущий блок в бинарной форме codec
+// и запоминает её для BlockEvent.
+func (app *PromiseApp) putRecord(id string, v any) error {
+	data, err := codec.EncodeRecord(v)
+	if err != nil {
+		return err
+	}
+	if err := app.currentBatch.Set([]byte(id), data); err != nil {
+		return err
+	}
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	app.pending = append(app.pending, Record{ID: id, Kind: recordKind(id), Value: value})
+	return nil
+}
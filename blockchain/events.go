@@ -0,0 +1,118 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gregorybednov/lbc/kv"
+)
+
+// Record — запись реестра, созданная транзакцией.
+type Record struct {
+	ID    string          `json:"id"`
+	Kind  string          `json:"kind"` // commiter | beneficiary | promise | commitment
+	Value json.RawMessage `json:"value"`
+}
+
+// BlockEvent — записи, созданные в одном зафиксированном блоке, в порядке
+// транзакций. Публикуется после успешного Commit, поэтому подписчик видит
+// только то, что уже можно прочитать из базы.
+type BlockEvent struct {
+	Height  int64    `json:"height"`
+	Records []Record `json:"records"`
+	// Completed — обязательства, выполнение которых завершилось в этом
+	// блоке (отметкой Fulfillment из Records).
+	Completed []string `json:"completed,omitempty"`
+}
+
+// eventPrefix — BlockEvent-ы хранятся по высоте, чтобы подписчик мог
+// продолжить с курсора: event:<высота, 20 цифр>.
+const eventPrefix = "event:"
+
+func eventKey(height int64) []byte {
+	return []byte(fmt.Sprintf("%s%020d", eventPrefix, height))
+}
+
+// genesisEventKey — i-я часть события высоты 0 (записи из genesis):
+// event:<0, 20 цифр>:<i, 6 цифр>. Базы, импортированные раньше, хранят его
+// одним ключом eventKey(0); читать событие genesis надо обходом по этому
+// префиксу.
+func genesisEventKey(i int) []byte {
+	return []byte(fmt.Sprintf("%s:%06d", eventKey(0), i))
+}
+
+// storeEvent кладёт событие блока в тот же батч, что и сами записи.
+func storeEvent(txn kv.Writer, ev BlockEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return txn.Set(eventKey(ev.Height), data)
+}
+
+// EventsSince вызывает fn для сохранённых событий блоков выше after, по
+// возрастанию высоты.
+func (app *PromiseApp) EventsSince(after int64, fn func(ev BlockEvent) error) error {
+	return kv.View(app.db, func(r kv.Reader) error {
+		return r.Iterate([]byte(eventPrefix), eventKey(after+1), func(_, v []byte) error {
+			var ev BlockEvent
+			if err := json.Unmarshal(v, &ev); err != nil {
+				return err
+			}
+			return fn(ev)
+		})
+	})
+}
+
+// subscriberBuffer — сколько блоков подписчик может отстать, прежде чем
+// его канал закроют.
+const subscriberBuffer = 64
+
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[chan BlockEvent]struct{}
+}
+
+// Subscribe возвращает канал событий зафиксированных блоков и функцию
+// отписки. Если подписчик не успевает читать, канал закрывается — ему нужно
+// переподписаться и дочитать пропущенное запросами.
+func (app *PromiseApp) Subscribe() (<-chan BlockEvent, func()) {
+	ch := make(chan BlockEvent, subscriberBuffer)
+	h := &app.events
+	h.mu.Lock()
+	if h.subs == nil {
+		h.subs = make(map[chan BlockEvent]struct{})
+	}
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subs[ch]; ok {
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (h *eventHub) publish(ev BlockEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- ev:
+		default:
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// recordKind — вид записи по префиксу ID.
+func recordKind(id string) string {
+	kind, _, _ := strings.Cut(id, ":")
+	return kind
+}
@@ -0,0 +1,307 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gregorybednov/lbc/canonical"
+	"github.com/gregorybednov/lbc/codec"
+	"github.com/gregorybednov/lbc/kv"
+	types "github.com/gregorybednov/lbc_sdk"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+const (
+	fulfillmentTxType = "fulfillment"
+	// progressPrefix — индекс отметок о выполнении по обязательству:
+	// progress:<commitment id>:<fulfillment id> → quantity отметки. Пишется
+	// вместе с Fulfillment, чтобы проверка перевыполнения не перебирала все
+	// отметки реестра. Ключ на каждую отметку, а не сумма: повтор блока
+	// перезаписывает ключ тем же значением.
+	progressPrefix = "progress:"
+	// maxUnitLen — предел длины единицы объёма.
+	maxUnitLen = 32
+)
+
+// progressEntry — сумма и число отметок о выполнении одного обязательства.
+type progressEntry struct {
+	Fulfilled int64
+	Count     int
+}
+
+// complete сообщает, выполнено ли обязательство c полностью.
+func (pr progressEntry) complete(c *codec.Commitment) bool {
+	if c.Quantity == 0 {
+		return pr.Count > 0
+	}
+	return pr.Fulfilled >= c.Quantity
+}
+
+// Progress — выполнение обязательства или обещания (запрос progress/<id>).
+type Progress struct {
+	ID           string `json:"id"`
+	Quantity     int64  `json:"quantity,omitempty"`
+	Unit         string `json:"unit,omitempty"`
+	Fulfilled    int64  `json:"fulfilled"`
+	Remaining    int64  `json:"remaining"`
+	Fulfillments int    `json:"fulfillments"`
+	Complete     bool   `json:"complete"`
+	// Commitments — выполнение каждого обязательства (только для обещания).
+	Commitments []*Progress `json:"commitments,omitempty"`
+}
+
+// checkQuantity проверяет объём обещания p и доли c в нём: единица задаётся
+// вместе с объёмом, доля коммитера — в тех же единицах и не больше объёма.
+func checkQuantity(p *codec.Promise, c *codec.Commitment) error {
+	if p.Quantity < 0 || c.Quantity < 0 {
+		return errors.New("quantity must not be negative")
+	}
+	if len(p.Unit) > maxUnitLen || len(c.Unit) > maxUnitLen {
+		return fmt.Errorf("unit is longer than %d bytes", maxUnitLen)
+	}
+	if p.Quantity == 0 {
+		if p.Unit != "" || c.Quantity != 0 || c.Unit != "" {
+			return errors.New("unit and commitment.quantity require promise.quantity")
+		}
+		return nil
+	}
+	if strings.TrimSpace(p.Unit) == "" {
+		return errors.New("promise.unit is required with quantity")
+	}
+	if c.Quantity == 0 || c.Quantity > p.Quantity {
+		return fmt.Errorf("commitment.quantity must be between 1 and %d", p.Quantity)
+	}
+	if c.Unit != p.Unit {
+		return fmt.Errorf("commitment.unit must be %q", p.Unit)
+	}
+	return nil
+}
+
+// verifyFulfillmentTx проверяет подпись отметки о выполнении: ставит её
+// коммитер обязательства. Возвращает отметку и обязательство.
+func verifyFulfillmentTx(r kv.Reader, chainID string, tx []byte) (*codec.Fulfillment, *codec.Commitment, uint32, error) {
+	var outer compoundTxRaw
+	if err := json.Unmarshal(tx, &outer); err != nil {
+		return nil, nil, 1, errors.New("invalid fulfillment tx JSON")
+	}
+	var f codec.Fulfillment
+	if err := json.Unmarshal(outer.Body, &f); err != nil {
+		return nil, nil, 1, errors.New("invalid body JSON")
+	}
+	if err := requireIDPrefix(f.ID, "fulfillment"); err != nil {
+		return nil, nil, 2, err
+	}
+	if err := requireIDPrefix(f.CommitmentID, "commitment"); err != nil {
+		return nil, nil, 2, err
+	}
+	var c codec.Commitment
+	if err := readRecord(r, f.CommitmentID, &c); errors.Is(err, kv.ErrNotFound) {
+		return nil, nil, 11, errors.New("unknown commitment")
+	} else if err != nil {
+		return nil, nil, 1, err
+	}
+	var commiter types.CommiterTxBody
+	if err := readRecord(r, c.CommiterID, &commiter); err != nil {
+		return nil, nil, 4, errors.New("unknown commiter")
+	}
+	pubkey, err := decodeEd25519PubKey(commiter.CommiterPubKey)
+	if err != nil {
+		return nil, nil, 1, err
+	}
+	if err := canonical.Verify(pubkey, outer.Signature, chainID, fulfillmentTxType, outer.Body); err != nil {
+		return nil, nil, 1, err
+	}
+	return &f, &c, 0, nil
+}
+
+// checkFulfillment проверяет отметку f по обязательству c и уже
+// накопленному выполнению: количественное обязательство выполняется
+// частями до своего объёма, остальное — одной отметкой без quantity.
+func checkFulfillment(r kv.Reader, f *codec.Fulfillment, c *codec.Commitment) (uint32, error) {
+	if _, err := r.Get([]byte(f.ID)); err != kv.ErrNotFound {
+		return 3, errors.New("duplicate fulfillment ID")
+	}
+	pr, err := loadProgress(r, c.ID)
+	if err != nil {
+		return 1, err
+	}
+	if c.Quantity == 0 {
+		if f.Quantity != 0 {
+			return 2, errors.New("commitment has no quantity")
+		}
+		if pr.Count > 0 {
+			return 2, errors.New("commitment is already fulfilled")
+		}
+		return 0, nil
+	}
+	if f.Quantity <= 0 {
+		return 2, errors.New("fulfillment.quantity must be positive")
+	}
+	if f.Quantity > c.Quantity-pr.Fulfilled {
+		return 2, fmt.Errorf("fulfillment exceeds the commitment: %d of %d %s remaining", c.Quantity-pr.Fulfilled, c.Quantity, c.Unit)
+	}
+	return 0, nil
+}
+
+func progressKey(commitmentID, fulfillmentID string) []byte {
+	return []byte(progressPrefix + commitmentID + ":" + fulfillmentID)
+}
+
+// loadProgress суммирует отметки обязательства по индексу progress:.
+func loadProgress(r kv.Reader, commitmentID string) (progressEntry, error) {
+	var pr progressEntry
+	err := r.Iterate([]byte(progressPrefix+commitmentID+":"), nil, func(key, v []byte) error {
+		q, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		pr.Fulfilled += q
+		pr.Count++
+		return nil
+	})
+	return pr, err
+}
+
+// addProgress добавляет отметку f в индекс выполнения.
+func addProgress(txn kv.Writer, f *codec.Fulfillment) error {
+	return txn.Set(progressKey(f.CommitmentID, f.ID), []byte(strconv.FormatInt(f.Quantity, 10)))
+}
+
+func (app *PromiseApp) checkFulfillmentTx(st kv.Txn, tx []byte) abci.ResponseCheckTx {
+	f, c, code, err := verifyFulfillmentTx(st, app.chainID, tx)
+	if err != nil {
+		return abci.ResponseCheckTx{Code: code, Log: err.Error()}
+	}
+	if code, err := checkFulfillment(st, f, c); err != nil {
+		return abci.ResponseCheckTx{Code: code, Log: err.Error()}
+	}
+	remaining, err := chargeRate(st, app.checkRates, c.CommiterID, app.height+1)
+	if err != nil {
+		return abci.ResponseCheckTx{Code: 8, Log: err.Error()}
+	}
+	// Выполнение учитывается и в состоянии проверки: две отметки, вместе
+	// превышающие объём, не попадут в mempool обе.
+	if err := addProgress(st, f); err != nil {
+		return abci.ResponseCheckTx{Code: 1, Log: err.Error()}
+	}
+	return acceptCheckTx(st, map[string]any{f.ID: f}, int64(remaining))
+}
+
+func (app *PromiseApp) deliverFulfillmentTx(tx []byte) abci.ResponseDeliverTx {
+	if app.currentBatch == nil {
+		app.currentBatch = kv.NewWriteBuffer(app.db)
+		app.blockRates = rateCounts{}
+	}
+	f, c, code, err := verifyFulfillmentTx(app.currentBatch, app.chainID, tx)
+	if err != nil {
+		return abci.ResponseDeliverTx{Code: code, Log: err.Error()}
+	}
+	if code, err := checkFulfillment(app.currentBatch, f, c); err != nil {
+		return abci.ResponseDeliverTx{Code: code, Log: err.Error()}
+	}
+	if _, err := chargeRate(app.currentBatch, app.blockRates, c.CommiterID, app.height); err != nil {
+		return abci.ResponseDeliverTx{Code: 8, Log: err.Error()}
+	}
+	if err := addProgress(app.currentBatch, f); err != nil {
+		return abci.ResponseDeliverTx{Code: 1, Log: err.Error()}
+	}
+	if err := app.putRecord(f.ID, f); err != nil {
+		return abci.ResponseDeliverTx{Code: 1, Log: "failed to save fulfillment"}
+	}
+	if pr, err := loadProgress(app.currentBatch, c.ID); err != nil {
+		return abci.ResponseDeliverTx{Code: 1, Log: err.Error()}
+	} else if pr.complete(c) {
+		app.completed = append(app.completed, c.ID)
+	}
+	return abci.ResponseDeliverTx{Code: 0}
+}
+
+// rebuildProgress пересчитывает накопленное выполнение по записям реестра
+// (импорт из genesis).
+func rebuildProgress(txn kv.Writer, records []Record) error {
+	for _, r := range records {
+		if r.Kind != "fulfillment" {
+			continue
+		}
+		var f codec.Fulfillment
+		if err := json.Unmarshal(r.Value, &f); err != nil {
+			return fmt.Errorf("%s: %w", r.ID, err)
+		}
+		if err := addProgress(txn, &f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Progress возвращает выполнение обязательства или обещания id; ok=false,
+// если такой записи нет. Объём и выполнение обещания — суммы по его
+// обязательствам (у повторяющегося — по всем созданным периодам).
+func (s *Snapshot) Progress(id string) (p *Progress, ok bool, err error) {
+	switch recordKind(id) {
+	case "commitment":
+		var c codec.Commitment
+		if err := readRecord(s.r, id, &c); errors.Is(err, kv.ErrNotFound) {
+			return nil, false, nil
+		} else if err != nil {
+			return nil, false, err
+		}
+		p, err := s.commitmentProgress(&c)
+		return p, err == nil, err
+	case "promise":
+		var pr codec.Promise
+		if err := readRecord(s.r, id, &pr); errors.Is(err, kv.ErrNotFound) {
+			return nil, false, nil
+		} else if err != nil {
+			return nil, false, err
+		}
+		total := &Progress{ID: pr.ID, Unit: pr.Unit, Complete: true}
+		err := s.r.Iterate([]byte("commitment:"), nil, func(_, v []byte) error {
+			value, err := codec.RecordJSON(v)
+			if err != nil {
+				return err
+			}
+			var c codec.Commitment
+			if err := json.Unmarshal(value, &c); err != nil || c.PromiseID != pr.ID {
+				return err
+			}
+			cp, err := s.commitmentProgress(&c)
+			if err != nil {
+				return err
+			}
+			total.Quantity += cp.Quantity
+			total.Fulfilled += cp.Fulfilled
+			total.Remaining += cp.Remaining
+			total.Fulfillments += cp.Fulfillments
+			total.Complete = total.Complete && cp.Complete
+			total.Commitments = append(total.Commitments, cp)
+			return nil
+		})
+		if len(total.Commitments) == 0 {
+			total.Complete = false
+		}
+		return total, err == nil, err
+	}
+	return nil, false, nil
+}
+
+func (s *Snapshot) commitmentProgress(c *codec.Commitment) (*Progress, error) {
+	pr, err := loadProgress(s.r, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	p := &Progress{
+		ID:           c.ID,
+		Quantity:     c.Quantity,
+		Unit:         c.Unit,
+		Fulfilled:    pr.Fulfilled,
+		Remaining:    c.Quantity - pr.Fulfilled,
+		Fulfillments: pr.Count,
+	}
+	p.Complete = pr.complete(c)
+	return p, nil
+}
@@ -0,0 +1,166 @@
+package blockchain
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gregorybednov/lbc/codec"
+	"github.com/gregorybednov/lbc/kv"
+)
+
+func TestFulfillmentProgress(t *testing.T) {
+	type fulfillment struct {
+		id   string
+		qty  int64
+		code uint32
+	}
+	cases := []struct {
+		name         string
+		qty          int64
+		fulfillments []fulfillment
+		want         Progress
+	}{
+		{
+			name:         "in parts",
+			qty:          10,
+			fulfillments: []fulfillment{{"a", 4, 0}, {"b", 6, 0}},
+			want:         Progress{Quantity: 10, Unit: "kg", Fulfilled: 10, Remaining: 0, Fulfillments: 2, Complete: true},
+		},
+		{
+			name:         "partial",
+			qty:          10,
+			fulfillments: []fulfillment{{"a", 3, 0}},
+			want:         Progress{Quantity: 10, Unit: "kg", Fulfilled: 3, Remaining: 7, Fulfillments: 1},
+		},
+		{
+			name:         "over the quantity",
+			qty:          10,
+			fulfillments: []fulfillment{{"a", 8, 0}, {"b", 3, 2}},
+			want:         Progress{Quantity: 10, Unit: "kg", Fulfilled: 8, Remaining: 2, Fulfillments: 1},
+		},
+		{
+			name:         "zero quantity",
+			qty:          10,
+			fulfillments: []fulfillment{{"a", 0, 2}},
+			want:         Progress{Quantity: 10, Unit: "kg", Remaining: 10},
+		},
+		{
+			name:         "without quantity",
+			fulfillments: []fulfillment{{"a", 0, 0}},
+			want:         Progress{Fulfillments: 1, Complete: true},
+		},
+		{
+			name:         "without quantity twice",
+			fulfillments: []fulfillment{{"a", 0, 0}, {"b", 0, 2}},
+			want:         Progress{Fulfillments: 1, Complete: true},
+		},
+		{
+			name:         "duplicate id",
+			qty:          10,
+			fulfillments: []fulfillment{{"a", 2, 0}, {"a", 2, 3}},
+			want:         Progress{Quantity: 10, Unit: "kg", Fulfilled: 2, Remaining: 8, Fulfillments: 1},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestChain(t)
+			unit := ""
+			if tc.qty != 0 {
+				unit = "kg"
+			}
+			requireCodes(t, c.block(c.quantityPromiseTx(t, "1", tc.qty, unit)), 0)
+			for _, f := range tc.fulfillments {
+				requireCodes(t, c.block(c.fulfillmentTx(t, f.id, "1", f.qty)), f.code)
+			}
+			tc.want.ID = "commitment:1"
+			got := progressOf(t, c.app, "commitment:1")
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestAddProgressIdempotent: отметка, учтённая повторно (повтор блока,
+// повторный импорт genesis), не прибавляется к выполнению второй раз.
+func TestAddProgressIdempotent(t *testing.T) {
+	db := kv.NewMemory()
+	fs := []*codec.Fulfillment{
+		{Type: "fulfillment", ID: "fulfillment:a", CommitmentID: "commitment:1", Quantity: 4},
+		{Type: "fulfillment", ID: "fulfillment:b", CommitmentID: "commitment:1", Quantity: 2},
+		{Type: "fulfillment", ID: "fulfillment:c", CommitmentID: "commitment:10", Quantity: 7},
+	}
+	for i := 0; i < 2; i++ {
+		if err := kv.Update(db, func(txn kv.Txn) error {
+			for _, f := range fs {
+				if err := addProgress(txn, f); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := kv.View(db, func(r kv.Reader) error {
+		pr, err := loadProgress(r, "commitment:1")
+		if pr != (progressEntry{Fulfilled: 6, Count: 2}) {
+			t.Errorf("got %+v", pr)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPromiseProgress(t *testing.T) {
+	c := newTestChain(t)
+	requireCodes(t, c.block(c.quantityPromiseTx(t, "1", 10, "kg")), 0)
+	requireCodes(t, c.block(c.fulfillmentTx(t, "a", "1", 4), c.fulfillmentTx(t, "b", "1", 6)), 0, 0)
+	got := progressOf(t, c.app, "promise:1")
+	want := Progress{ID: "promise:1", Quantity: 10, Unit: "kg", Fulfilled: 10, Fulfillments: 2, Complete: true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func progressOf(t *testing.T, app *PromiseApp, id string) Progress {
+	t.Helper()
+	var p *Progress
+	err := app.View(func(s *Snapshot) error {
+		var ok bool
+		var err error
+		p, ok, err = s.Progress(id)
+		if err == nil && !ok {
+			t.Fatalf("%s not found", id)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Commitments = nil
+	return *p
+}
+
+// TestCompletedInBlockEvent: событие блока называет обязательства, чьё
+// выполнение в нём завершилось, — ровно один раз.
+func TestCompletedInBlockEvent(t *testing.T) {
+	c := newTestChain(t)
+	requireCodes(t, c.block(c.quantityPromiseTx(t, "q", 10, "kg"), c.promiseTx(t, "one")), 0, 0)
+	requireCodes(t, c.block(c.fulfillmentTx(t, "a", "q", 4)), 0)
+	requireCodes(t, c.block(c.fulfillmentTx(t, "b", "q", 6), c.fulfillmentTx(t, "c", "one", 0)), 0, 0)
+
+	completed := map[int64][]string{}
+	if err := c.app.EventsSince(0, func(ev BlockEvent) error {
+		completed[ev.Height] = ev.Completed
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[int64][]string{1: nil, 2: nil, 3: {"commitment:q", "commitment:one"}}
+	if !reflect.DeepEqual(completed, want) {
+		t.Errorf("got %v, want %v", completed, want)
+	}
+}
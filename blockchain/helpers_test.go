@@ -0,0 +1,151 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gregorybednov/lbc/canonical"
+	"github.com/gregorybednov/lbc/codec"
+	"github.com/gregorybednov/lbc/kv"
+	types "github.com/gregorybednov/lbc_sdk"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+)
+
+const testChainID = "test"
+
+// testChain — приложение над базой в памяти с одним коммитером и одним
+// бенефициаром. Они кладутся в базу напрямую, минуя доказательство работы.
+type testChain struct {
+	app        *PromiseApp
+	db         kv.Store
+	priv       ed25519.PrivateKey
+	commiterID string
+	height     int64
+	appHash    []byte // ответ Commit последнего блока
+}
+
+func newTestChain(t *testing.T) *testChain {
+	t.Helper()
+	db := kv.NewMemory()
+	app := NewPromiseApp(db)
+	app.InitChain(abci.RequestInitChain{ChainId: testChainID})
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk := base64.StdEncoding.EncodeToString(pub)
+	c := &testChain{app: app, db: db, priv: priv, commiterID: "commiter:" + pk}
+	c.putRecord(t, c.commiterID, &types.CommiterTxBody{Type: "commiter", ID: c.commiterID, Name: "A", CommiterPubKey: pk})
+	c.putRecord(t, "beneficiary:1", &types.BeneficiaryTxBody{Type: "beneficiary", ID: "beneficiary:1", Name: "B"})
+	return c
+}
+
+func (c *testChain) putRecord(t *testing.T, id string, v any) {
+	t.Helper()
+	data, err := codec.EncodeRecord(v)
+	if err == nil {
+		err = kv.Update(c.db, func(txn kv.Txn) error { return txn.Set([]byte(id), data) })
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// signTx собирает конверт транзакции, подписанный ключом коммитера.
+func (c *testChain) signTx(t *testing.T, kind string, body any) []byte {
+	t.Helper()
+	return signTx(t, c.priv, kind, body)
+}
+
+func signTx(t *testing.T, priv ed25519.PrivateKey, kind string, body any) []byte {
+	t.Helper()
+	raw, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := canonical.Sign(priv, testChainID, kind, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := json.Marshal(map[string]any{"body": json.RawMessage(raw), "signature": sig})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// promiseTx — обещание бенефициару и обязательство коммитера по нему.
+func (c *testChain) promiseTx(t *testing.T, n string) []byte {
+	t.Helper()
+	return c.quantityPromiseTx(t, n, 0, "")
+}
+
+// quantityPromiseTx — то же с объёмом qty в единицах unit, который
+// коммитер берёт на себя целиком; qty=0 — обещание без объёма.
+func (c *testChain) quantityPromiseTx(t *testing.T, n string, qty int64, unit string) []byte {
+	t.Helper()
+	promise := map[string]any{
+		"type": "promise", "id": "promise:" + n, "text": "t" + n,
+		"due": 4000000000, "beneficiary_id": "beneficiary:1",
+	}
+	commitment := map[string]any{
+		"type": "commitment", "id": "commitment:" + n, "promise_id": "promise:" + n,
+		"commiter_id": c.commiterID, "due": 4000000000,
+	}
+	if qty != 0 {
+		promise["quantity"], promise["unit"] = qty, unit
+		commitment["quantity"], commitment["unit"] = qty, unit
+	}
+	return c.signTx(t, "promise", map[string]any{"promise": promise, "commitment": commitment})
+}
+
+// fulfillmentTx — отметка id о выполнении обязательства commitment:<n>.
+func (c *testChain) fulfillmentTx(t *testing.T, id, n string, qty int64) []byte {
+	t.Helper()
+	body := map[string]any{"type": "fulfillment", "id": "fulfillment:" + id, "commitment_id": "commitment:" + n}
+	if qty != 0 {
+		body["quantity"] = qty
+	}
+	return c.signTx(t, "fulfillment", body)
+}
+
+// block проводит следующий блок с транзакциями txs и возвращает ответы
+// DeliverTx.
+func (c *testChain) block(txs ...[]byte) []abci.ResponseDeliverTx {
+	c.height++
+	res, commit := deliverBlock(c.app, c.height, txs...)
+	c.appHash = commit.Data
+	return res
+}
+
+func deliverBlock(app *PromiseApp, height int64, txs ...[]byte) ([]abci.ResponseDeliverTx, abci.ResponseCommit) {
+	app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{
+		ChainID: testChainID,
+		Height:  height,
+		Time:    time.Unix(1700000000+height, 0),
+	}})
+	res := make([]abci.ResponseDeliverTx, len(txs))
+	for i, tx := range txs {
+		res[i] = app.DeliverTx(abci.RequestDeliverTx{Tx: tx})
+	}
+	app.EndBlock(abci.RequestEndBlock{Height: height})
+	return res, app.Commit()
+}
+
+func requireCodes(t *testing.T, res []abci.ResponseDeliverTx, want ...uint32) {
+	t.Helper()
+	if len(res) != len(want) {
+		t.Fatalf("got %d results, want %d", len(res), len(want))
+	}
+	for i, r := range res {
+		if r.Code != want[i] {
+			t.Errorf("tx %d: code %d (%s), want %d", i, r.Code, r.Log, want[i])
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package blockchain
+
+import "github.com/gregorybednov/lbc/kv"
+
+// localKind — префикс служебных данных ноды вне консенсуса (очереди
+// доставки, курсоры служб): local:<служба>:... Они лежат в той же базе, но
+// не входят в состояние цепочки и не отдаются через list/.
+const localKind = "local"
+
+// LocalPrefix возвращает префикс ключей службы name.
+func LocalPrefix(name string) string { return localKind + ":" + name + ":" }
+
+// LocalView и LocalUpdate дают службам ноды доступ к их данным под
+// LocalPrefix. Ключи вне своего префикса службы трогать не должны. Срока
+// жизни ключей в kv нет: устаревшие данные служба удаляет сама.
+func (app *PromiseApp) LocalView(fn func(r kv.Reader) error) error {
+	return kv.View(app.db, fn)
+}
+
+func (app *PromiseApp) LocalUpdate(fn func(txn kv.Txn) error) error {
+	return kv.Update(app.db, fn)
+}
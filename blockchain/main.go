@@ -2,13 +2,14 @@ package blockchain
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"os"
 
 	"github.com/gregorybednov/lbc/cfg"
+	"github.com/gregorybednov/lbc/kv"
 
-	"github.com/dgraph-io/badger"
 	abci "github.com/tendermint/tendermint/abci/types"
 	"github.com/tendermint/tendermint/libs/log"
 	nm "github.com/tendermint/tendermint/node"
@@ -18,8 +19,28 @@ import (
 	tmTypes "github.com/tendermint/tendermint/types"
 )
 
-func openBadger(path string) (*badger.DB, error) {
-	return badger.Open(badger.DefaultOptions(path).WithTruncate(true))
+// OpenDB открывает базу ноды на движке backend для записи (запуск ноды,
+// lbc db ...). Хвост value log Badger, повреждённый при аварийной
+// остановке, не отрезается молча: обрезку нужно подтвердить через
+// `lbc db repair`.
+func OpenDB(backend, path string) (kv.Store, error) {
+	db, err := kv.Open(backend, path, kv.Options{})
+	if errors.Is(err, kv.ErrTruncateNeeded) {
+		return nil, fmt.Errorf("%w; run `lbc db repair` to truncate the damaged tail", err)
+	}
+	return db, err
+}
+
+// RepairDB открывает базу с обрезкой повреждённого хвоста value log.
+// Записи после места повреждения теряются.
+func RepairDB(backend, path string) (kv.Store, error) {
+	return kv.Open(backend, path, kv.Options{Truncate: true})
+}
+
+// OpenReadOnly открывает базу остановленной ноды только для чтения
+// (lbc export).
+func OpenReadOnly(backend, path string) (kv.Store, error) {
+	return kv.Open(backend, path, kv.Options{ReadOnly: true})
 }
 
 func newTendermint(app abci.Application, config *cfg.Config, laddrReturner chan string) (*nm.Node, error) {
@@ -58,10 +79,10 @@ func newTendermint(app abci.Application, config *cfg.Config, laddrReturner chan
 	)
 }
 
-func GetNodeInfo(config *cfg.Config, dbPath string) (p2p.NodeInfo, error) {
-	db, err := openBadger(dbPath)
+func GetNodeInfo(config *cfg.Config, backend, dbPath string) (p2p.NodeInfo, error) {
+	db, err := OpenDB(backend, dbPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open badger db to get node info %v", err)
+		return nil, fmt.Errorf("failed to open db to get node info %v", err)
 	}
 	defer db.Close()
 
@@ -105,13 +126,42 @@ func GetNodeInfo(config *cfg.Config, dbPath string) (p2p.NodeInfo, error) {
 
 }
 
-func Run(ctx context.Context, dbPath string, config *cfg.Config, laddrReturner chan string) error {
-	db, err := openBadger(dbPath)
+// Service — фоновая служба, которой нужен доступ к приложению (чтение
+// снимков, подписка на события блоков). Запускается после старта ноды и
+// должна завершиться при отмене ctx.
+type Service func(ctx context.Context, app *PromiseApp) error
+
+// Run запускает ноду над базой dbPath на движке backend (см. kv.Open).
+func Run(ctx context.Context, backend, dbPath string, config *cfg.Config, laddrReturner chan string, services ...Service) error {
+	db, err := OpenDB(backend, dbPath)
 	if err != nil {
-		return fmt.Errorf("open badger db: %w", err)
+		return fmt.Errorf("open %s db: %w", backend, err)
 	}
 	defer db.Close()
 
+	genesis, err := tmTypes.GenesisDocFromFile(config.GenesisFile())
+	if err != nil {
+		return fmt.Errorf("read genesis: %w", err)
+	}
+	applied, err := Migrate(db, MigrationEnv{Genesis: genesis}, false)
+	if err != nil {
+		return fmt.Errorf("migrate db: %w", err)
+	}
+	for _, m := range applied {
+		fmt.Printf("schema migration %d (%s): %d keys updated\n", m.Version, m.Description, m.Changed)
+	}
+
+	gcCtx, stopGC := context.WithCancel(ctx)
+	gcDone := make(chan struct{})
+	go func() {
+		defer close(gcDone)
+		runValueLogGC(gcCtx, db, gcInterval)
+	}()
+	defer func() {
+		stopGC()
+		<-gcDone
+	}()
+
 	app := NewPromiseApp(db)
 	node, err := newTendermint(app, config, laddrReturner)
 	if err != nil {
@@ -120,6 +170,13 @@ func Run(ctx context.Context, dbPath string, config *cfg.Config, laddrReturner c
 	if err := node.Start(); err != nil {
 		return fmt.Errorf("start node: %w", err)
 	}
+	for _, svc := range services {
+		go func(svc Service) {
+			if err := svc(ctx, app); err != nil {
+				fmt.Printf("service error: %v\n", err)
+			}
+		}(svc)
+	}
 	defer func() {
 
 	}()
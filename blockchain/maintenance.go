@@ -0,0 +1,302 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gregorybednov/lbc/codec"
+	"github.com/gregorybednov/lbc/kv"
+
+	"github.com/tendermint/tendermint/store"
+)
+
+const (
+	// gcInterval — период фоновой сборки мусора value log в Run.
+	gcInterval = 10 * time.Minute
+	// GCDiscardRatio — доля мусора в файле value log, при которой он
+	// переписывается.
+	GCDiscardRatio = 0.5
+)
+
+// runValueLogGC периодически запускает сборку мусора до отмены ctx. Для
+// движков без kv.Maintainer ничего не делает.
+func runValueLogGC(ctx context.Context, db kv.Store, interval time.Duration) {
+	m, ok := db.(kv.Maintainer)
+	if !ok {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := m.CollectGarbage(GCDiscardRatio); err != nil {
+				fmt.Printf("value log GC: %v\n", err)
+			} else if n > 0 {
+				fmt.Printf("value log GC: rewrote %d files\n", n)
+			}
+		}
+	}
+}
+
+// DirSize — суммарный размер файлов в каталоге базы. Для Badger отдельно
+// считаются файлы LSM (.sst) и value log (.vlog).
+func DirSize(path string) (total, lsm, vlog int64, err error) {
+	err = filepath.WalkDir(path, func(p string, e fs.DirEntry, err error) error {
+		if err != nil || e.IsDir() {
+			return err
+		}
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		switch filepath.Ext(e.Name()) {
+		case ".sst":
+			lsm += info.Size()
+		case ".vlog":
+			vlog += info.Size()
+		}
+		return nil
+	})
+	return total, lsm, vlog, err
+}
+
+// Stats — сводка по базе для `lbc db stats`.
+type Stats struct {
+	ChainID       string
+	Height        int64
+	SchemaVersion int
+	Tables        int            // таблиц LSM; только Badger
+	Keys          map[string]int // по виду: часть ключа до первого ':'
+}
+
+// CollectStats считает ключи по видам и читает служебные ключи.
+func (app *PromiseApp) CollectStats() (*Stats, error) {
+	st := &Stats{ChainID: app.chainID, Keys: map[string]int{}}
+	if b, ok := app.db.(interface{ Tables() int }); ok {
+		st.Tables = b.Tables()
+	}
+	var err error
+	if st.Height, err = app.LastHeight(); err != nil {
+		return nil, err
+	}
+	if st.SchemaVersion, err = StoredSchemaVersion(app.db); err != nil {
+		return nil, err
+	}
+	err = kv.View(app.db, func(r kv.Reader) error {
+		return r.Iterate(nil, nil, func(key, _ []byte) error {
+			kind, _, _ := strings.Cut(string(key), ":")
+			st.Keys[kind]++
+			return nil
+		})
+	})
+	return st, err
+}
+
+// VerifyReport — результат `lbc db verify`.
+type VerifyReport struct {
+	Records  int
+	Problems []string
+
+	// Заполняются VerifyBlocks.
+	BlocksFrom, BlocksTo int64
+	Txs                  int // транзакций в блоках
+	InvalidTxs           int // из них с неверной подписью или формой
+	Unsigned             int // записей без подписанной транзакции (beneficiary)
+	Genesis              int // записей из app_state genesis
+	Recurring            int // обязательств, созданных по правилу повторения
+}
+
+func (r *VerifyReport) problem(format string, args ...any) {
+	r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+}
+
+// VerifyRecords проверяет каждую запись по ER-модели: разбор, совпадение ID
+// с ключом, обязательные поля и существование связанных записей.
+func (app *PromiseApp) VerifyRecords() (*VerifyReport, error) {
+	rep := &VerifyReport{}
+	err := app.View(func(s *Snapshot) error {
+		exists := func(id, kind string) bool {
+			if recordKind(id) != kind {
+				return false
+			}
+			_, err := s.r.Get([]byte(id))
+			return err == nil
+		}
+		for _, kind := range RecordKinds {
+			if err := s.r.Iterate([]byte(kind+":"), nil, func(key, raw []byte) error {
+				id := string(key)
+				rep.Records++
+				value, err := codec.RecordJSON(raw)
+				if err != nil {
+					rep.problem("%s: %v", id, err)
+					return nil
+				}
+				if err := verifyDumpRecord(Record{ID: id, Kind: kind, Value: value}, exists); err != nil {
+					rep.problem("%s: %v", id, err)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return rep, err
+}
+
+// recurrenceBaseSigned сообщает, создано ли обязательство id по правилу
+// повторения из обязательства, которое подписано или пришло из genesis.
+func recurrenceBaseSigned(id string, signed, genesis map[string]bool) bool {
+	base, _, ok := parseRecurrenceID(id)
+	return ok && recordKind(id) == "commitment" && (signed[base] || genesis[base])
+}
+
+// VerifyBlocks перепроверяет подписи всех транзакций из хранилища блоков
+// Tendermint и отмечает записи, для которых не нашлось подписанной
+// транзакции. Проверка идёт против текущего состояния: ключ коммитера
+// берётся из его записи.
+func (app *PromiseApp) VerifyBlocks(bs *store.BlockStore, rep *VerifyReport) error {
+	rep.BlocksFrom, rep.BlocksTo = bs.Base(), bs.Height()
+	signed := map[string]bool{}
+	unsigned := map[string]bool{}
+	for h := rep.BlocksFrom; h > 0 && h <= rep.BlocksTo; h++ {
+		block := bs.LoadBlock(h)
+		if block == nil {
+			return fmt.Errorf("block %d is missing from the block store", h)
+		}
+		for _, tx := range block.Data.Txs {
+			rep.Txs++
+			ids, sig, err := app.verifyStoredTx(tx)
+			if err != nil {
+				rep.InvalidTxs++
+				continue
+			}
+			for _, id := range ids {
+				if sig {
+					signed[id] = true
+				} else {
+					unsigned[id] = true
+				}
+			}
+		}
+	}
+
+	genesis := map[string]bool{}
+	if err := app.View(func(s *Snapshot) error {
+		return s.r.Iterate(eventKey(0), nil, func(_, v []byte) error {
+			var ev BlockEvent
+			if err := json.Unmarshal(v, &ev); err != nil {
+				return err
+			}
+			for _, r := range ev.Records {
+				genesis[r.ID] = true
+			}
+			return nil
+		})
+	}); err != nil {
+		return err
+	}
+
+	var missing []string
+	err := app.View(func(s *Snapshot) error {
+		for _, kind := range RecordKinds {
+			if err := s.scanRecords(kind+":", func(r Record) error {
+				switch {
+				case signed[r.ID]:
+				case genesis[r.ID]:
+					rep.Genesis++
+				case unsigned[r.ID]:
+					rep.Unsigned++
+				case recurrenceBaseSigned(r.ID, signed, genesis):
+					rep.Recurring++
+				default:
+					missing = append(missing, r.ID)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(missing)
+	for _, id := range missing {
+		if rep.BlocksFrom > 1 {
+			rep.problem("%s: no signed transaction in blocks %d..%d (earlier blocks are pruned)", id, rep.BlocksFrom, rep.BlocksTo)
+		} else {
+			rep.problem("%s: no signed transaction in blocks", id)
+		}
+	}
+	return nil
+}
+
+// verifyStoredTx проверяет подписи транзакции из блока так же, как CheckTx,
+// и возвращает ID создаваемых ею записей; signed=false для форм без
+// подписи (регистрация бенефициара).
+func (app *PromiseApp) verifyStoredTx(raw []byte) (ids []string, signed bool, err error) {
+	tx, err := decodeTx(raw)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := verifyCosignatures(app.chainID, tx); err != nil {
+		return nil, false, err
+	}
+	if isValidatorTxType(bodyType(tx)) {
+		_, err := verifyValidatorTx(app.chainID, tx)
+		return nil, true, err
+	}
+	if bodyType(tx) == fulfillmentTxType {
+		var f *codec.Fulfillment
+		if err := kv.View(app.db, func(r kv.Reader) error {
+			f, _, _, err = verifyFulfillmentTx(r, app.chainID, tx)
+			return err
+		}); err != nil {
+			return nil, false, err
+		}
+		return []string{f.ID}, true, nil
+	}
+	var compound *codec.Compound
+	if err := kv.View(app.db, func(r kv.Reader) error {
+		compound, err = verifyCompoundTx(r, app.chainID, tx)
+		return err
+	}); err == nil {
+		if p := compound.Promise; p != nil {
+			ids = append(ids, p.ID)
+		}
+		if c := compound.Commitment; c != nil {
+			ids = append(ids, c.ID)
+		}
+		return ids, true, nil
+	}
+	if body, err := verifyAndExtractBody(app.chainID, tx); err == nil {
+		id, _ := body["id"].(string)
+		return []string{id}, true, nil
+	}
+	switch bodyType(tx) {
+	case "beneficiary":
+		var env struct {
+			Body struct {
+				ID string `json:"id"`
+			} `json:"body"`
+		}
+		if err := json.Unmarshal(tx, &env); err != nil {
+			return nil, false, err
+		}
+		return []string{env.Body.ID}, false, nil
+	}
+	return nil, false, errors.New("invalid or unsigned transaction")
+}
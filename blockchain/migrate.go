@@ -0,0 +1,253 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gregorybednov/lbc/canonical"
+	"github.com/gregorybednov/lbc/codec"
+	"github.com/gregorybednov/lbc/kv"
+
+	"github.com/tendermint/tendermint/crypto/ed25519"
+	tmtypes "github.com/tendermint/tendermint/types"
+)
+
+// schemaVersionKey — версия схемы хранения. База без ключа имеет версию 0:
+// записи в JSON, как до введения миграций.
+const schemaVersionKey = "meta:schema_version"
+
+// Migration переводит базу с версии Version-1 на Version. Apply читает
+// через r и пишет только через put: при --dry-run put лишь считает
+// изменения. Миграция должна быть идемпотентной — запись батчами не
+// атомарна, и прерванную миграцию запустят заново.
+type Migration struct {
+	Version     int
+	Description string
+	Apply       func(env MigrationEnv, r kv.Reader, put func(key, value []byte) error) error
+}
+
+// MigrationEnv — то, что миграциям нужно помимо самой базы.
+type MigrationEnv struct {
+	// Genesis — genesis.json сети. Нужен миграциям, которые восстанавливают
+	// данные InitChain в базах, созданных до того, как их стали сохранять;
+	// nil — genesis недоступен.
+	Genesis *tmtypes.GenesisDoc
+}
+
+// migrations — реестр миграций по возрастанию версии; новая миграция
+// добавляется в конец.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "re-encode legacy JSON records as protobuf",
+		Apply:       migrateJSONRecords,
+	},
+	{
+		Version:     2,
+		Description: "restore meta:chain_id from genesis",
+		Apply:       migrateChainID,
+	},
+	{
+		Version:     3,
+		Description: "seed the validator set from genesis",
+		Apply:       migrateValidators,
+	},
+}
+
+// SchemaVersion — версия схемы, которую пишет эта сборка.
+func SchemaVersion() int { return migrations[len(migrations)-1].Version }
+
+// MigrationResult — итог одной миграции.
+type MigrationResult struct {
+	Version     int
+	Description string
+	Changed     int // сколько ключей записано (или было бы записано)
+}
+
+// StoredSchemaVersion читает версию схемы базы.
+func StoredSchemaVersion(db kv.Store) (int, error) {
+	var version int
+	err := kv.View(db, func(r kv.Reader) error {
+		v, err := r.Get([]byte(schemaVersionKey))
+		if err == kv.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		version, err = strconv.Atoi(string(v))
+		return err
+	})
+	return version, err
+}
+
+// Migrate применяет недостающие миграции по порядку, сохраняя версию после
+// каждой. При dryRun база не меняется. База новее сборки — ошибка: старый
+// код не должен писать в неё.
+func Migrate(db kv.Store, env MigrationEnv, dryRun bool) ([]MigrationResult, error) {
+	current, err := StoredSchemaVersion(db)
+	if err != nil {
+		return nil, fmt.Errorf("read schema version: %w", err)
+	}
+	if current > SchemaVersion() {
+		return nil, fmt.Errorf("database schema version %d is newer than supported %d", current, SchemaVersion())
+	}
+
+	var results []MigrationResult
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		res := MigrationResult{Version: m.Version, Description: m.Description}
+		var wb kv.Batch
+		if !dryRun {
+			wb = db.NewBatch()
+		}
+		put := func(key, value []byte) error {
+			res.Changed++
+			if dryRun {
+				return nil
+			}
+			return wb.Set(key, value)
+		}
+		err := kv.View(db, func(r kv.Reader) error { return m.Apply(env, r, put) })
+		if dryRun {
+			results = append(results, res)
+			if err != nil {
+				return results, fmt.Errorf("migration %d: %w", m.Version, err)
+			}
+			continue
+		}
+		if err != nil {
+			wb.Close()
+			return results, fmt.Errorf("migration %d: %w", m.Version, err)
+		}
+		if err := wb.Set([]byte(schemaVersionKey), []byte(strconv.Itoa(m.Version))); err != nil {
+			wb.Close()
+			return results, err
+		}
+		if err := wb.Write(); err != nil {
+			return results, fmt.Errorf("migration %d: %w", m.Version, err)
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// migrateJSONRecords перекодирует записи, сохранённые в JSON до перехода на
+// protobuf. Запись, которую protobuf не передаёт без потерь, остаётся в JSON.
+func migrateJSONRecords(_ MigrationEnv, r kv.Reader, put func(key, value []byte) error) error {
+	for _, kind := range RecordKinds {
+		if err := r.Iterate([]byte(kind+":"), nil, func(key, raw []byte) error {
+			if codec.IsProto(raw) {
+				return nil
+			}
+			id := string(key)
+			data, ok, err := reencodeRecord(Record{ID: id, Kind: kind, Value: raw})
+			if err != nil {
+				return fmt.Errorf("%s: %w", id, err)
+			}
+			if !ok {
+				return nil
+			}
+			return put(key, data)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reencodeRecord возвращает бинарную форму JSON-записи; ok=false, если
+// разбор теряет поля.
+func reencodeRecord(r Record) ([]byte, bool, error) {
+	v, err := decodeRecord(r)
+	if err != nil {
+		return nil, false, err
+	}
+	got, err := canonical.Marshal(v)
+	if err != nil {
+		return nil, false, err
+	}
+	want, err := canonical.Canonicalize(r.Value)
+	if err != nil {
+		return nil, false, err
+	}
+	if !bytes.Equal(got, want) {
+		return nil, false, nil
+	}
+	data, err := codec.EncodeRecord(v)
+	if err != nil {
+		return nil, false, nil
+	}
+	return data, true, nil
+}
+
+// migrateChainID сохраняет chain_id из genesis в базах, созданных до того,
+// как InitChain стал его записывать. Без него подписи не проверяются, а
+// InitChain для такой базы Tendermint больше не вызовет.
+func migrateChainID(env MigrationEnv, r kv.Reader, put func(key, value []byte) error) error {
+	if _, err := r.Get([]byte(chainIDKey)); err != kv.ErrNotFound {
+		return err
+	}
+	if env.Genesis == nil {
+		// Базу без записей InitChain ещё заполнит сам.
+		if found, err := hasRecords(r); err != nil || !found {
+			return err
+		}
+		return errors.New("meta:chain_id is missing and genesis.json is not available to restore it")
+	}
+	return put([]byte(chainIDKey), []byte(env.Genesis.ChainID))
+}
+
+// migrateValidators сохраняет набор валидаторов из genesis в базах
+// цепочек, начатых до того, как InitChain стал его записывать: без набора
+// голосование за его изменение невозможно. До голосований набор совпадал с
+// genesis.
+func migrateValidators(env MigrationEnv, r kv.Reader, put func(key, value []byte) error) error {
+	if seeded, err := hasValidators(r); err != nil || seeded {
+		return err
+	}
+	if env.Genesis == nil {
+		if found, err := hasRecords(r); err != nil || !found {
+			return err
+		}
+		return errors.New("validator set is missing and genesis.json is not available to restore it")
+	}
+	for _, v := range env.Genesis.Validators {
+		if v.PubKey == nil || v.PubKey.Type() != ed25519.KeyType {
+			return errors.New("only ed25519 validators are supported")
+		}
+		rec := validatorRecord{PubKey: base64.StdEncoding.EncodeToString(v.PubKey.Bytes()), Power: v.Power}
+		value, err := json.Marshal(&rec)
+		if err != nil {
+			return err
+		}
+		if err := put(validatorKey(rec.PubKey), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasRecords сообщает, что в базе есть хотя бы одна запись реестра.
+func hasRecords(r kv.Reader) (bool, error) {
+	found := false
+	for _, kind := range RecordKinds {
+		err := r.Iterate([]byte(kind+":"), nil, func(_, _ []byte) error {
+			found = true
+			return errStopIteration
+		})
+		if err != nil && err != errStopIteration {
+			return false, err
+		}
+		if found {
+			return true, nil
+		}
+	}
+	return false, nil
+}
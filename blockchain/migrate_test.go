@@ -0,0 +1,208 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gregorybednov/lbc/codec"
+	"github.com/gregorybednov/lbc/kv"
+
+	tmed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	tmtypes "github.com/tendermint/tendermint/types"
+)
+
+// baselineDB — база в исходном формате: записи в JSON, служебных ключей
+// meta: нет (InitChain ещё не сохранял chain_id).
+func baselineDB(t *testing.T) *testChain {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk := base64.StdEncoding.EncodeToString(pub)
+	cid := "commiter:" + pk
+	db := kv.NewMemory()
+	records := map[string]string{
+		cid:             `{"type":"commiter","id":"` + cid + `","name":"A","commiter_pubkey":"` + pk + `"}`,
+		"beneficiary:1": `{"type":"beneficiary","id":"beneficiary:1","name":"B"}`,
+		"promise:1":     `{"type":"promise","id":"promise:1","text":"t","due":4000000000,"beneficiary_id":"beneficiary:1","parent_promise_id":null}`,
+		"commitment:1":  `{"type":"commitment","id":"commitment:1","promise_id":"promise:1","commiter_id":"` + cid + `","due":4000000000}`,
+	}
+	if err := kv.Update(db, func(txn kv.Txn) error {
+		for id, v := range records {
+			if err := txn.Set([]byte(id), []byte(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return &testChain{db: db, priv: priv, commiterID: cid}
+}
+
+// TestMigrateBaselineDB: база, созданная до сохранения chain_id, после
+// миграций принимает транзакции, подписанные для chain_id из genesis.
+func TestMigrateBaselineDB(t *testing.T) {
+	c := baselineDB(t)
+	db := c.db
+	if _, err := Migrate(db, MigrationEnv{}, false); err == nil {
+		t.Fatal("migrated a database without chain_id and without genesis")
+	}
+	if _, err := Migrate(db, MigrationEnv{Genesis: &tmtypes.GenesisDoc{ChainID: testChainID}}, false); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := StoredSchemaVersion(db); err != nil || v != SchemaVersion() {
+		t.Fatalf("schema version %d (%v), want %d", v, err, SchemaVersion())
+	}
+	if err := kv.View(db, func(r kv.Reader) error {
+		v, err := r.Get([]byte("promise:1"))
+		if err == nil && !codec.IsProto(v) {
+			t.Error("promise:1 is still JSON")
+		}
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	c.app = NewPromiseApp(db)
+	if c.app.chainID != testChainID {
+		t.Fatalf("chain id %q, want %q", c.app.chainID, testChainID)
+	}
+	requireCodes(t, c.block(c.fulfillmentTx(t, "a", "1", 0), c.promiseTx(t, "2")), 0, 0)
+}
+
+// TestMigrateEmptyDB: пустую базу миграции не трогают без genesis — её
+// заполнит InitChain.
+func TestMigrateEmptyDB(t *testing.T) {
+	db := kv.NewMemory()
+	if _, err := Migrate(db, MigrationEnv{}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.View(db, func(r kv.Reader) error {
+		_, err := r.Get([]byte(chainIDKey))
+		if err != kv.ErrNotFound {
+			t.Errorf("chain id: %v, want ErrNotFound", err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestMigrateDryRun: пробный прогон не меняет базу и насчитывает столько же
+// изменений, сколько потом делает настоящий.
+func TestMigrateDryRun(t *testing.T) {
+	db := baselineDB(t).db
+	env := MigrationEnv{Genesis: &tmtypes.GenesisDoc{ChainID: testChainID}}
+	dry, err := Migrate(db, env, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dry) != len(migrations) {
+		t.Fatalf("dry run: %d results, want %d", len(dry), len(migrations))
+	}
+	if v, err := StoredSchemaVersion(db); err != nil || v != 0 {
+		t.Fatalf("schema version after dry run: %d, %v", v, err)
+	}
+	if err := kv.View(db, func(r kv.Reader) error {
+		v, err := r.Get([]byte("promise:1"))
+		if err == nil && codec.IsProto(v) {
+			t.Error("dry run re-encoded promise:1")
+		}
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := Migrate(db, env, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dry, applied) {
+		t.Errorf("dry run %+v, migration %+v", dry, applied)
+	}
+	if again, err := Migrate(db, env, false); err != nil || len(again) != 0 {
+		t.Errorf("second run: %+v, %v", again, err)
+	}
+}
+
+func TestMigrateNewerSchema(t *testing.T) {
+	db := kv.NewMemory()
+	if err := kv.Update(db, func(txn kv.Txn) error {
+		return txn.Set([]byte(schemaVersionKey), []byte(strconv.Itoa(SchemaVersion()+1)))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Migrate(db, MigrationEnv{}, true); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Fatalf("got %v, want a newer-schema error", err)
+	}
+}
+
+// TestMigrations проверяет отдельные миграции: какие ключи они пишут по
+// заданному содержимому базы.
+func TestMigrations(t *testing.T) {
+	val := tmed25519.GenPrivKey().PubKey()
+	valKey := base64.StdEncoding.EncodeToString(val.Bytes())
+	genesis := &tmtypes.GenesisDoc{
+		ChainID:    testChainID,
+		Validators: []tmtypes.GenesisValidator{{PubKey: val, Power: 10}},
+	}
+	tests := []struct {
+		name    string
+		version int
+		genesis *tmtypes.GenesisDoc
+		db      map[string]string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"v2 restores chain id", 2, genesis, map[string]string{"promise:1": "{}"}, map[string]string{chainIDKey: testChainID}, false},
+		{"v2 keeps chain id", 2, genesis, map[string]string{chainIDKey: "other", "promise:1": "{}"}, map[string]string{}, false},
+		{"v2 leaves an empty database to InitChain", 2, nil, map[string]string{}, map[string]string{}, false},
+		{"v2 needs genesis for records", 2, nil, map[string]string{"promise:1": "{}"}, nil, true},
+		{"v3 seeds validators", 3, genesis, map[string]string{"promise:1": "{}"}, map[string]string{
+			"validator:" + valKey: `{"pub_key":"` + valKey + `","power":10}`,
+		}, false},
+		{"v3 keeps validators", 3, genesis, map[string]string{"validator:x": `{"pub_key":"x","power":1}`}, map[string]string{}, false},
+		{"v3 leaves an empty database to InitChain", 3, nil, map[string]string{}, map[string]string{}, false},
+		{"v3 needs genesis for records", 3, nil, map[string]string{"promise:1": "{}"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m Migration
+			for _, mm := range migrations {
+				if mm.Version == tt.version {
+					m = mm
+				}
+			}
+			db := kv.NewMemory()
+			if err := kv.Update(db, func(txn kv.Txn) error {
+				for k, v := range tt.db {
+					if err := txn.Set([]byte(k), []byte(v)); err != nil {
+						return err
+					}
+				}
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]string{}
+			err := kv.View(db, func(r kv.Reader) error {
+				return m.Apply(MigrationEnv{Genesis: tt.genesis}, r, func(key, value []byte) error {
+					got[string(key)] = string(value)
+					return nil
+				})
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
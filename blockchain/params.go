@@ -0,0 +1,156 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gregorybednov/lbc/codec"
+	"github.com/gregorybednov/lbc/kv"
+	types "github.com/gregorybednov/lbc_sdk"
+)
+
+// paramsKey — параметры приложения (JSON Params), заданные в genesis.
+const paramsKey = "meta:params"
+
+// Политики приёма новых коммитеров.
+const (
+	AdmissionOpen   = "open"   // любая самоподписанная регистрация
+	AdmissionInvite = "invite" // приглашение действующего коммитера
+	AdmissionVote   = "vote"   // Endorsements поручительств действующих коммитеров
+)
+
+// Params — параметры приложения, общие для всей сети. Задаются в app_state
+// genesis.json (поле params) и переносятся выгрузкой lbc export / import.
+type Params struct {
+	Admission AdmissionParams `json:"admission"`
+}
+
+// AdmissionParams — политика приёма коммитеров. Приглашение и поручительство —
+// дополнительная подпись (cosignatures) действующего коммитера над телом
+// регистрации, см. `lbc tx sign` и `lbc tx multisign`.
+type AdmissionParams struct {
+	Policy       string `json:"policy"`
+	Endorsements int    `json:"endorsements,omitempty"` // только для vote
+}
+
+// DefaultParams — параметры цепочек, в genesis которых их нет.
+func DefaultParams() Params {
+	return Params{Admission: AdmissionParams{Policy: AdmissionOpen}}
+}
+
+// Validate проверяет параметры перед записью в genesis.
+func (p Params) Validate() error {
+	switch p.Admission.Policy {
+	case AdmissionOpen, AdmissionInvite:
+		if p.Admission.Endorsements != 0 {
+			return fmt.Errorf("admission: endorsements apply only to the %q policy", AdmissionVote)
+		}
+	case AdmissionVote:
+		if p.Admission.Endorsements < 1 {
+			return fmt.Errorf("admission: %q policy needs at least 1 endorsement", AdmissionVote)
+		}
+	default:
+		return fmt.Errorf("admission: unknown policy %q (open|invite|vote)", p.Admission.Policy)
+	}
+	return nil
+}
+
+// required — число поручительств, которого требует политика.
+func (a AdmissionParams) required() int {
+	switch a.Policy {
+	case AdmissionInvite:
+		return 1
+	case AdmissionVote:
+		return a.Endorsements
+	}
+	return 0
+}
+
+func loadParams(r kv.Reader) (Params, error) {
+	p := DefaultParams()
+	_, err := getJSON(r, []byte(paramsKey), &p)
+	return p, err
+}
+
+// storeParams проверяет и сохраняет параметры из genesis; nil — параметры
+// по умолчанию, ключ не пишется.
+func storeParams(db kv.Store, p *Params) error {
+	if p == nil {
+		return nil
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	return kv.Update(db, func(txn kv.Txn) error {
+		return setJSON(txn, []byte(paramsKey), p)
+	})
+}
+
+// Params возвращает параметры приложения.
+func (app *PromiseApp) Params() (Params, error) {
+	var p Params
+	err := kv.View(app.db, func(r kv.Reader) error {
+		var err error
+		p, err = loadParams(r)
+		return err
+	})
+	return p, err
+}
+
+// commiterKeys возвращает base64-ключи зарегистрированных коммитеров.
+func commiterKeys(r kv.Reader) (map[string]bool, error) {
+	keys := map[string]bool{}
+	err := r.Iterate([]byte("commiter:"), nil, func(_, v []byte) error {
+		data, err := codec.RecordJSON(v)
+		if err != nil {
+			return err
+		}
+		var c types.CommiterTxBody
+		if err := json.Unmarshal(data, &c); err != nil {
+			return err
+		}
+		keys[strings.TrimSpace(c.CommiterPubKey)] = true
+		return nil
+	})
+	return keys, err
+}
+
+// checkAdmission проверяет регистрацию коммитера по политике приёма:
+// считаются дополнительные подписи действующих коммитеров (сами подписи
+// проверены verifyCosignatures). Пока коммитеров меньше, чем требуется
+// поручительств, достаточно подписей всех; первый коммитер цепочки без
+// записей в genesis регистрируется сам.
+func checkAdmission(r kv.Reader, tx []byte) error {
+	p, err := loadParams(r)
+	if err != nil {
+		return err
+	}
+	need := p.Admission.required()
+	if need == 0 {
+		return nil
+	}
+	members, err := commiterKeys(r)
+	if err != nil {
+		return err
+	}
+	if len(members) < need {
+		need = len(members)
+	}
+	var env struct {
+		Cosignatures []cosignature `json:"cosignatures"`
+	}
+	if err := json.Unmarshal(tx, &env); err != nil {
+		return err
+	}
+	got := 0
+	for _, cs := range env.Cosignatures {
+		if members[strings.TrimSpace(cs.PubKey)] {
+			got++
+		}
+	}
+	if got < need {
+		return fmt.Errorf("admission policy %q requires %d endorsement(s) by existing commiters, got %d", p.Admission.Policy, need, got)
+	}
+	return nil
+}
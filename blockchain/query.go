@@ -0,0 +1,213 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/gregorybednov/lbc/kv"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+// Поддерживаемые пути abci_query:
+//
+//	list/<kind>                         — все записи вида <kind>:... (только RecordKinds)
+//	get/<id>                            — одна запись по ID
+//	rel/promises-of-beneficiary/<id>    — обещания бенефициара
+//	rel/children/<promise id>           — дочерние обещания
+//	rel/commitments-of-promise/<id>     — обязательства по обещанию
+//	rel/commitments-of-commiter/<id>    — обязательства коммитера
+//	rel/fulfillments-of-commitment/<id> — отметки о выполнении обязательства
+//	progress/<id>                       — выполнение обязательства или обещания (Progress)
+//	reputation/<commiter id>            — выполненные и пропущенные обязательства (Reputation)
+//	params                              — параметры приложения (Params)
+//	validators                          — действующий набор валидаторов
+//	valproposals                        — голосования за изменение набора
+//
+// ID может содержать "/" (base64 в commiter:...), поэтому хвост пути не режется.
+// Записи хранятся в бинарной форме codec, но ответы всегда в JSON.
+func (app *PromiseApp) Query(req abci.RequestQuery) abci.ResponseQuery {
+	path := strings.Trim(req.Path, "/")
+	if path == "params" {
+		p, err := app.Params()
+		if err == nil {
+			var value []byte
+			if value, err = json.Marshal(p); err == nil {
+				return abci.ResponseQuery{Code: 0, Value: value}
+			}
+		}
+		return abci.ResponseQuery{Code: 1, Log: err.Error()}
+	}
+	if path == "validators" || path == "valproposals" {
+		prefix := validatorPrefix
+		if path == "valproposals" {
+			prefix = validatorProposalPrefix
+		}
+		value, err := app.listValidatorState(prefix)
+		if err != nil {
+			return abci.ResponseQuery{Code: 1, Log: err.Error()}
+		}
+		return abci.ResponseQuery{Code: 0, Value: value}
+	}
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return abci.ResponseQuery{Code: 1, Log: "unsupported query"}
+	}
+
+	var (
+		value []byte
+		err   error
+	)
+	switch parts[0] {
+	case "list":
+		// Служебные ключи (meta, validator, progress, event, ...) наружу
+		// не отдаются: перечислять можно только виды записей реестра.
+		if !slices.Contains(RecordKinds, parts[1]) {
+			return abci.ResponseQuery{Code: 1, Log: "unsupported query"}
+		}
+		var result []json.RawMessage
+		result, err = app.listByPrefix(parts[1]+":", nil)
+		if err == nil {
+			value, err = json.Marshal(result)
+		}
+	case "get":
+		if strings.HasPrefix(parts[1], localKind+":") {
+			return abci.ResponseQuery{Code: 2, Log: "not found"}
+		}
+		value, err = app.getRecord(parts[1])
+		if err == kv.ErrNotFound {
+			return abci.ResponseQuery{Code: 2, Log: "not found"}
+		}
+	case "progress":
+		var p *Progress
+		var ok bool
+		err = app.View(func(s *Snapshot) error {
+			p, ok, err = s.Progress(parts[1])
+			return err
+		})
+		if err == nil && !ok {
+			return abci.ResponseQuery{Code: 2, Log: "not found"}
+		}
+		if err == nil {
+			value, err = json.Marshal(p)
+		}
+	case "reputation":
+		var rep *Reputation
+		var ok bool
+		err = app.View(func(s *Snapshot) error {
+			rep, ok, err = s.Reputation(parts[1])
+			return err
+		})
+		if err == nil && !ok {
+			return abci.ResponseQuery{Code: 2, Log: "not found"}
+		}
+		if err == nil {
+			value, err = json.Marshal(rep)
+		}
+	case "rel":
+		rel := strings.SplitN(parts[1], "/", 2)
+		if len(rel) != 2 || rel[1] == "" {
+			return abci.ResponseQuery{Code: 1, Log: "unsupported query"}
+		}
+		var result []json.RawMessage
+		result, err = app.queryRelation(rel[0], rel[1])
+		if err == nil {
+			value, err = json.Marshal(result)
+		}
+	default:
+		return abci.ResponseQuery{Code: 1, Log: "unsupported query"}
+	}
+
+	if err != nil {
+		return abci.ResponseQuery{Code: 1, Log: err.Error()}
+	}
+	return abci.ResponseQuery{Code: 0, Value: value}
+}
+
+func (app *PromiseApp) getRecord(id string) ([]byte, error) {
+	var value []byte
+	err := app.View(func(s *Snapshot) error {
+		v, ok, err := s.Get(id)
+		if err == nil && !ok {
+			err = kv.ErrNotFound
+		}
+		value = v
+		return err
+	})
+	return value, err
+}
+
+// listByPrefix возвращает записи с данным префиксом; match (если задан)
+// отбирает записи по содержимому.
+func (app *PromiseApp) listByPrefix(prefix string, match func([]byte) bool) ([]json.RawMessage, error) {
+	result := []json.RawMessage{}
+	err := app.View(func(s *Snapshot) error {
+		return s.Scan(prefix, func(v json.RawMessage) error {
+			if match == nil || match(v) {
+				result = append(result, v)
+			}
+			return nil
+		})
+	})
+	return result, err
+}
+
+// listValidatorState отдаёт JSON-массив служебных записей набора
+// валидаторов (validator: или valproposal:) — они хранятся в JSON, а не в
+// codec, и через list/<kind> не видны.
+func (app *PromiseApp) listValidatorState(prefix string) ([]byte, error) {
+	result := []json.RawMessage{}
+	err := kv.View(app.db, func(r kv.Reader) error {
+		return r.Iterate([]byte(prefix), nil, func(_, v []byte) error {
+			result = append(result, append(json.RawMessage(nil), v...))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(result)
+}
+
+func (app *PromiseApp) queryRelation(rel, id string) ([]json.RawMessage, error) {
+	switch rel {
+	case "promises-of-beneficiary":
+		return app.listByPrefix("promise:", func(v []byte) bool {
+			var p struct {
+				BeneficiaryID string `json:"beneficiary_id"`
+			}
+			return json.Unmarshal(v, &p) == nil && p.BeneficiaryID == id
+		})
+	case "children":
+		return app.listByPrefix("promise:", func(v []byte) bool {
+			var p struct {
+				ParentPromiseID *string `json:"parent_promise_id"`
+			}
+			return json.Unmarshal(v, &p) == nil && p.ParentPromiseID != nil && *p.ParentPromiseID == id
+		})
+	case "commitments-of-promise":
+		return app.listByPrefix("commitment:", func(v []byte) bool {
+			var c struct {
+				PromiseID string `json:"promise_id"`
+			}
+			return json.Unmarshal(v, &c) == nil && c.PromiseID == id
+		})
+	case "commitments-of-commiter":
+		return app.listByPrefix("commitment:", func(v []byte) bool {
+			var c struct {
+				CommiterID string `json:"commiter_id"`
+			}
+			return json.Unmarshal(v, &c) == nil && c.CommiterID == id
+		})
+	case "fulfillments-of-commitment":
+		return app.listByPrefix("fulfillment:", func(v []byte) bool {
+			var f struct {
+				CommitmentID string `json:"commitment_id"`
+			}
+			return json.Unmarshal(v, &f) == nil && f.CommitmentID == id
+		})
+	}
+	return nil, errors.New("unknown relation " + rel)
+}
@@ -0,0 +1,48 @@
+package blockchain
+
+import (
+	"encoding/json"
+
+	"github.com/gregorybednov/lbc/codec"
+	"github.com/gregorybednov/lbc/kv"
+)
+
+// Snapshot — согласованный снимок реестра для чтения вне ABCI (Query,
+// GraphQL): все чтения идут в одном снимке хранилища. Записи отдаются в
+// JSON независимо от формы хранения.
+type Snapshot struct {
+	r kv.Reader
+}
+
+// View выполняет fn над снимком зафиксированного состояния. Снимок
+// действителен только внутри fn.
+func (app *PromiseApp) View(fn func(s *Snapshot) error) error {
+	return kv.View(app.db, func(r kv.Reader) error {
+		return fn(&Snapshot{r: r})
+	})
+}
+
+// Get возвращает запись по ID; ok=false, если её нет.
+func (s *Snapshot) Get(id string) (value json.RawMessage, ok bool, err error) {
+	v, err := s.r.Get([]byte(id))
+	if err == kv.ErrNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	value, err = codec.RecordJSON(v)
+	return value, err == nil, err
+}
+
+// Scan вызывает fn для каждой записи, ID которой начинается с prefix, в
+// порядке ключей.
+func (s *Snapshot) Scan(prefix string, fn func(value json.RawMessage) error) error {
+	return s.r.Iterate([]byte(prefix), nil, func(_, v []byte) error {
+		v, err := codec.RecordJSON(v)
+		if err != nil {
+			return err
+		}
+		return fn(v)
+	})
+}
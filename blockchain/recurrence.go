@@ -0,0 +1,219 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gregorybednov/lbc/codec"
+	"github.com/gregorybednov/lbc/kv"
+	types "github.com/gregorybednov/lbc_sdk"
+)
+
+const (
+	// recurrencePrefix — расписание повторяющихся обещаний:
+	// recur:<unix-время, 20 цифр>:<promise id> → recurrenceEntry. Ключи
+	// упорядочены по времени, поэтому EndBlock читает только наступившие.
+	recurrencePrefix = "recur:"
+	// minRecurrencePeriod — самый короткий период повторения (час): чаще
+	// обязательства создавались бы почти в каждом блоке.
+	minRecurrencePeriod = 3600
+	// maxRecurrencesPerBlock — предел обязательств, создаваемых одним
+	// блоком; остальные создаются в следующих.
+	maxRecurrencesPerBlock = 1000
+)
+
+// recurrenceEntry — следующий период повторяющегося обещания.
+type recurrenceEntry struct {
+	PromiseID    string `json:"promise_id"`
+	CommitmentID string `json:"commitment_id"` // обязательство первого периода
+	Period       int64  `json:"period"`        // номер следующего периода, с 1
+}
+
+// checkRecurrence проверяет правило повторения обещания p с обязательством
+// первого периода c.
+func checkRecurrence(p *codec.Promise, c *codec.Commitment) error {
+	r := p.Recurrence
+	if r == nil {
+		return nil
+	}
+	if r.Period < minRecurrencePeriod {
+		return fmt.Errorf("recurrence.period must be at least %d seconds", minRecurrencePeriod)
+	}
+	if c.Due <= 0 {
+		return errors.New("recurring promise requires commitment.due")
+	}
+	if r.Until != 0 && r.Until < c.Due {
+		return errors.New("recurrence.until is before commitment.due")
+	}
+	if strings.Contains(c.ID, ".") {
+		return errors.New("commitment id of a recurring promise must not contain '.'")
+	}
+	return nil
+}
+
+// recurrenceID — ID обязательства периода n, созданного по правилу
+// повторения из обязательства первого периода base.
+func recurrenceID(base string, n int64) string {
+	return base + "." + strconv.FormatInt(n, 10)
+}
+
+// parseRecurrenceID разбирает ID, составленный recurrenceID.
+func parseRecurrenceID(id string) (base string, n int64, ok bool) {
+	i := strings.LastIndexByte(id, '.')
+	if i < 0 {
+		return "", 0, false
+	}
+	n, err := strconv.ParseInt(id[i+1:], 10, 64)
+	if err != nil || n < 1 {
+		return "", 0, false
+	}
+	return id[:i], n, true
+}
+
+// recurrenceDue — срок обязательства периода n.
+func recurrenceDue(r *codec.Recurrence, base *codec.Commitment, n int64) int64 {
+	return base.Due + n*r.Period
+}
+
+// scheduleRecurrence ставит в расписание период n: его обязательство
+// создаётся, когда истекает срок предыдущего. Периоды со сроком позже
+// Until не ставятся.
+func scheduleRecurrence(w kv.Writer, p *codec.Promise, base *codec.Commitment, n int64) error {
+	due := recurrenceDue(p.Recurrence, base, n)
+	if p.Recurrence.Until != 0 && due > p.Recurrence.Until {
+		return nil
+	}
+	v, err := json.Marshal(recurrenceEntry{PromiseID: p.ID, CommitmentID: base.ID, Period: n})
+	if err != nil {
+		return err
+	}
+	return w.Set(recurrenceKey(due-p.Recurrence.Period, p.ID), v)
+}
+
+func recurrenceKey(at int64, promiseID string) []byte {
+	return []byte(fmt.Sprintf("%s%020d:%s", recurrencePrefix, at, promiseID))
+}
+
+// materializeRecurrences создаёт обязательства периодов, начавшихся к
+// времени блока now. За блок у обещания создаётся не больше одного периода:
+// пропущенные (например, после долгой остановки сети) догоняются в
+// следующих блоках.
+func (app *PromiseApp) materializeRecurrences(now int64) error {
+	type due struct {
+		key   []byte
+		entry recurrenceEntry
+	}
+	var todo []due
+	end := string(recurrenceKey(now, "\xff"))
+	err := app.currentBatch.Iterate([]byte(recurrencePrefix), nil, func(key, v []byte) error {
+		if string(key) > end || len(todo) == maxRecurrencesPerBlock {
+			return errStopIteration
+		}
+		var e recurrenceEntry
+		if err := json.Unmarshal(v, &e); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		todo = append(todo, due{key: key, entry: e})
+		return nil
+	})
+	if err != nil && err != errStopIteration {
+		return err
+	}
+
+	for _, d := range todo {
+		if err := app.currentBatch.Delete(d.key); err != nil {
+			return err
+		}
+		var p codec.Promise
+		var base codec.Commitment
+		err := readRecord(app.currentBatch, d.entry.PromiseID, &p)
+		if err == nil {
+			err = readRecord(app.currentBatch, d.entry.CommitmentID, &base)
+		}
+		if errors.Is(err, kv.ErrNotFound) || (err == nil && p.Recurrence == nil) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		c := &codec.Commitment{
+			CommitmentTxBody: types.CommitmentTxBody{
+				Type:       "commitment",
+				ID:         recurrenceID(base.ID, d.entry.Period),
+				PromiseID:  p.ID,
+				CommiterID: base.CommiterID,
+				Due:        recurrenceDue(p.Recurrence, &base, d.entry.Period),
+			},
+			Quantity: base.Quantity,
+			Unit:     base.Unit,
+		}
+		if _, err := app.currentBatch.Get([]byte(c.ID)); err == kv.ErrNotFound {
+			if err := app.putRecord(c.ID, c); err != nil {
+				return err
+			}
+		}
+		if err := scheduleRecurrence(app.currentBatch, &p, &base, d.entry.Period+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildRecurrences восстанавливает расписание по записям реестра (импорт
+// из genesis): следующим ставится период после последнего созданного.
+func rebuildRecurrences(txn kv.Writer, records []Record) error {
+	promises := map[string]*codec.Promise{}
+	for _, r := range records {
+		if r.Kind != "promise" {
+			continue
+		}
+		var p codec.Promise
+		if err := json.Unmarshal(r.Value, &p); err != nil {
+			return fmt.Errorf("%s: %w", r.ID, err)
+		}
+		if p.Recurrence != nil {
+			promises[p.ID] = &p
+		}
+	}
+	bases := map[string]*codec.Commitment{}
+	last := map[string]int64{}
+	for _, r := range records {
+		if r.Kind != "commitment" {
+			continue
+		}
+		var c codec.Commitment
+		if err := json.Unmarshal(r.Value, &c); err != nil {
+			return fmt.Errorf("%s: %w", r.ID, err)
+		}
+		if promises[c.PromiseID] == nil {
+			continue
+		}
+		if base, n, ok := parseRecurrenceID(c.ID); ok {
+			last[base] = max(last[base], n)
+		} else {
+			bases[c.ID] = &c
+		}
+	}
+	for id, base := range bases {
+		if err := scheduleRecurrence(txn, promises[base.PromiseID], base, last[id]+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readRecord читает запись реестра id из r в v.
+func readRecord(r kv.Reader, id string, v any) error {
+	data, err := r.Get([]byte(id))
+	if err != nil {
+		return fmt.Errorf("%s: %w", id, err)
+	}
+	value, err := codec.RecordJSON(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", id, err)
+	}
+	return json.Unmarshal(value, v)
+}
@@ -0,0 +1,101 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/gregorybednov/lbc/codec"
+	"github.com/gregorybednov/lbc/kv"
+	types "github.com/gregorybednov/lbc_sdk"
+)
+
+// blockTimeKey — время последнего зафиксированного блока, unix. По нему вне
+// ABCI решается, истёк ли срок обязательства: часы узлов расходятся, а время
+// блока у всех одно.
+const blockTimeKey = "meta:block_time"
+
+// Reputation — исполнительность коммитера на время последнего блока
+// (запрос reputation/<commiter id>). Каждый период повторяющегося обещания
+// — отдельное обязательство и учитывается отдельно.
+type Reputation struct {
+	ID          string `json:"id"`
+	AsOf        int64  `json:"as_of"` // время последнего блока, unix
+	Commitments int    `json:"commitments"`
+	Fulfilled   int    `json:"fulfilled"` // выполнены полностью
+	Missed      int    `json:"missed"`    // срок истёк, не выполнены
+	Pending     int    `json:"pending"`   // срок не истёк или не задан, не выполнены
+	// MissedPeriods — сколько из Missed приходится на периоды повторяющихся
+	// обещаний.
+	MissedPeriods int `json:"missed_periods"`
+	// MissedCommitments — ID пропущенных обязательств в порядке ключей.
+	MissedCommitments []string `json:"missed_commitments,omitempty"`
+}
+
+// blockTime читает время последнего блока; 0, если его ещё нет.
+func blockTime(r kv.Reader) (int64, error) {
+	v, err := r.Get([]byte(blockTimeKey))
+	if err == kv.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(v), 10, 64)
+}
+
+// Reputation возвращает исполнительность коммитера id; ok=false, если
+// такого коммитера нет. Обязательство пропущено, если его срок раньше
+// времени последнего блока, а выполнено оно не полностью.
+func (s *Snapshot) Reputation(id string) (rep *Reputation, ok bool, err error) {
+	var commiter types.CommiterTxBody
+	if err := readRecord(s.r, id, &commiter); errors.Is(err, kv.ErrNotFound) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	now, err := blockTime(s.r)
+	if err != nil {
+		return nil, false, err
+	}
+	rep = &Reputation{ID: id, AsOf: now}
+	recurring := map[string]bool{}
+	err = s.r.Iterate([]byte("commitment:"), nil, func(_, v []byte) error {
+		value, err := codec.RecordJSON(v)
+		if err != nil {
+			return err
+		}
+		var c codec.Commitment
+		if err := json.Unmarshal(value, &c); err != nil || c.CommiterID != id {
+			return err
+		}
+		cp, err := s.commitmentProgress(&c)
+		if err != nil {
+			return err
+		}
+		rep.Commitments++
+		switch {
+		case cp.Complete:
+			rep.Fulfilled++
+		case c.Due > 0 && c.Due < now:
+			rep.Missed++
+			rep.MissedCommitments = append(rep.MissedCommitments, c.ID)
+			isRecurring, seen := recurring[c.PromiseID]
+			if !seen {
+				var p codec.Promise
+				if err := readRecord(s.r, c.PromiseID, &p); err != nil && !errors.Is(err, kv.ErrNotFound) {
+					return err
+				}
+				isRecurring = p.Recurrence != nil
+				recurring[c.PromiseID] = isRecurring
+			}
+			if isRecurring {
+				rep.MissedPeriods++
+			}
+		default:
+			rep.Pending++
+		}
+		return nil
+	})
+	return rep, err == nil, err
+}
@@ -0,0 +1,58 @@
+package blockchain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReputation(t *testing.T) {
+	const t0 = 1700000000 // время блока h — t0+h, см. deliverBlock
+	c := newTestChain(t)
+	promise := func(n string, due int64, recurrence map[string]any) []byte {
+		p := map[string]any{"type": "promise", "id": "promise:" + n, "text": "t" + n, "beneficiary_id": "beneficiary:1"}
+		if recurrence != nil {
+			p["recurrence"] = recurrence
+		}
+		return c.signTx(t, "promise", map[string]any{"promise": p, "commitment": map[string]any{
+			"type": "commitment", "id": "commitment:" + n, "promise_id": "promise:" + n,
+			"commiter_id": c.commiterID, "due": due,
+		}})
+	}
+	requireCodes(t, c.block(
+		promise("done", t0+5, nil),
+		promise("late", t0+5, nil),
+		promise("later", 4000000000, nil),
+		promise("weekly", t0+2, map[string]any{"period": 3600}),
+	), 0, 0, 0, 0)
+	requireCodes(t, c.block(c.fulfillmentTx(t, "a", "done", 0)), 0)
+	c.height = 9
+	c.block() // создаёт период 1 со сроком t0+3602
+	c.height = 3699
+	c.block() // создаёт период 2; срок периода 1 истёк
+
+	var got *Reputation
+	if err := c.app.View(func(s *Snapshot) error {
+		var ok bool
+		var err error
+		got, ok, err = s.Reputation(c.commiterID)
+		if err == nil && !ok {
+			t.Fatal("commiter not found")
+		}
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := &Reputation{
+		ID:                c.commiterID,
+		AsOf:              t0 + 3700,
+		Commitments:       6,
+		Fulfilled:         1,
+		Missed:            3,
+		Pending:           2,
+		MissedPeriods:     2,
+		MissedCommitments: []string{"commitment:late", "commitment:weekly", "commitment:weekly.1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
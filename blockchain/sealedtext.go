@@ -0,0 +1,41 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/gregorybednov/lbc/codec"
+	"github.com/gregorybednov/lbc/kv"
+	"github.com/gregorybednov/lbc/sealed"
+	types "github.com/gregorybednov/lbc_sdk"
+)
+
+// checkSealedText проверяет зашифрованный текст обещания (пакет sealed):
+// форму конверта и то, что коммитер среди получателей — иначе он не смог бы
+// прочитать собственное обещание. Открытый текст не проверяется.
+func checkSealedText(r kv.Reader, p *codec.Promise, c *codec.Commitment) error {
+	if !sealed.IsSealed(p.Text) {
+		return nil
+	}
+	env, err := sealed.Parse(p.Text)
+	if err != nil {
+		return err
+	}
+	data, err := r.Get([]byte(c.CommiterID))
+	if err != nil {
+		return errors.New("unknown commiter")
+	}
+	commiterJSON, err := codec.RecordJSON(data)
+	if err != nil {
+		return errors.New("corrupted commiter record")
+	}
+	var commiter types.CommiterTxBody
+	if err := json.Unmarshal(commiterJSON, &commiter); err != nil {
+		return errors.New("corrupted commiter record")
+	}
+	if !env.HasRecipient(strings.TrimSpace(commiter.CommiterPubKey)) {
+		return errors.New("sealed text must include the commiter as a recipient")
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gregorybednov/lbc/canonical"
+)
+
+const chainIDKey = "meta:chain_id"
+
+// txKind — тип транзакции, входящий в домен подписи: body.type, а для
+// композита promise+commitment — "promise".
+func txKind(body json.RawMessage) string {
+	var tiny struct {
+		Type       string          `json:"type"`
+		Commitment json.RawMessage `json:"commitment"`
+	}
+	if err := json.Unmarshal(body, &tiny); err != nil {
+		return ""
+	}
+	if tiny.Type == "" && tiny.Commitment != nil {
+		return "promise"
+	}
+	return tiny.Type
+}
+
+// cosignature — дополнительная подпись над тем же каноническим body,
+// собранная через `lbc tx multisign`.
+type cosignature struct {
+	PubKey    string `json:"pub_key"`
+	Signature string `json:"signature"`
+}
+
+// errCosignersInBody — тело обязательства само задаёт cosigners: это поле
+// заполняет узел по проверенным cosignatures конверта.
+var errCosignersInBody = errors.New("commitment.cosigners is set by the node, not by the transaction")
+
+// verifyCosignatures проверяет необязательное поле cosignatures конверта и
+// возвращает base64-ключи подписавших. Одна неверная подпись отклоняет всю
+// транзакцию, чтобы в блок не попадали непроверяемые подписи.
+func verifyCosignatures(chainID string, tx []byte) ([]string, error) {
+	var env struct {
+		Body         json.RawMessage `json:"body"`
+		Cosignatures []cosignature   `json:"cosignatures"`
+	}
+	if err := json.Unmarshal(tx, &env); err != nil {
+		return nil, errors.New("invalid tx JSON")
+	}
+	seen := make(map[string]bool, len(env.Cosignatures))
+	signers := make([]string, 0, len(env.Cosignatures))
+	for _, cs := range env.Cosignatures {
+		pubkey, err := decodeEd25519PubKey(cs.PubKey)
+		if err != nil {
+			return nil, fmt.Errorf("cosignature: %w", err)
+		}
+		if seen[cs.PubKey] {
+			return nil, errors.New("duplicate cosignature")
+		}
+		seen[cs.PubKey] = true
+		if err := canonical.Verify(pubkey, cs.Signature, chainID, txKind(env.Body), env.Body); err != nil {
+			return nil, fmt.Errorf("cosignature of %s: %w", cs.PubKey, err)
+		}
+		signers = append(signers, cs.PubKey)
+	}
+	return signers, nil
+}
@@ -0,0 +1,118 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/gregorybednov/lbc/codec"
+	"github.com/gregorybednov/lbc/kv"
+)
+
+// StoreSummary — сводка базы для сверки при переносе на другой движок.
+type StoreSummary struct {
+	Keys      int
+	Kinds     map[string]int // по виду: часть ключа до первого ':'
+	Checksum  string         // SHA-256 по всем парам ключ-значение, hex
+	StateHash string         // StateHash записей реестра
+}
+
+// Summarize считает сводку по снимку базы.
+func Summarize(db kv.Store) (*StoreSummary, error) {
+	sum := &StoreSummary{Kinds: map[string]int{}}
+	h := sha256.New()
+	var n [binary.MaxVarintLen64]byte
+	var records []Record
+	err := kv.View(db, func(r kv.Reader) error {
+		return r.Iterate(nil, nil, func(key, value []byte) error {
+			sum.Keys++
+			h.Write(n[:binary.PutUvarint(n[:], uint64(len(key)))])
+			h.Write(key)
+			h.Write(n[:binary.PutUvarint(n[:], uint64(len(value)))])
+			h.Write(value)
+
+			kind, _, _ := strings.Cut(string(key), ":")
+			sum.Kinds[kind]++
+			if !slices.Contains(RecordKinds, kind) {
+				return nil
+			}
+			v, err := codec.RecordJSON(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			records = append(records, Record{ID: string(key), Kind: kind, Value: v})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sum.Checksum = hex.EncodeToString(h.Sum(nil))
+	if sum.StateHash, err = StateHash(records); err != nil {
+		return nil, err
+	}
+	return sum, nil
+}
+
+// CopyStore переносит все ключи из src в пустую dst и сверяет сводки обеих
+// баз: число ключей по видам, контрольную сумму и state_hash. src не должна
+// меняться во время переноса (нода остановлена).
+func CopyStore(dst, src kv.Store) (*StoreSummary, error) {
+	empty := true
+	if err := kv.View(dst, func(r kv.Reader) error {
+		return r.Iterate(nil, nil, func(_, _ []byte) error {
+			empty = false
+			return errStopIteration
+		})
+	}); err != nil && err != errStopIteration {
+		return nil, err
+	}
+	if !empty {
+		return nil, errors.New("destination database is not empty")
+	}
+
+	want, err := Summarize(src)
+	if err != nil {
+		return nil, fmt.Errorf("source: %w", err)
+	}
+	var copied int
+	if err := kv.View(src, func(r kv.Reader) error {
+		copied, err = kv.Copy(dst, r)
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("copy: %w", err)
+	}
+	got, err := Summarize(dst)
+	if err != nil {
+		return nil, fmt.Errorf("destination: %w", err)
+	}
+
+	switch {
+	case copied != want.Keys || got.Keys != want.Keys:
+		return nil, fmt.Errorf("key count mismatch: source %d, copied %d, destination %d", want.Keys, copied, got.Keys)
+	case !maps.Equal(got.Kinds, want.Kinds):
+		return nil, fmt.Errorf("key kinds mismatch: source %s, destination %s", formatKinds(want.Kinds), formatKinds(got.Kinds))
+	case got.StateHash != want.StateHash:
+		return nil, fmt.Errorf("state hash mismatch: source %s, destination %s", want.StateHash, got.StateHash)
+	case got.Checksum != want.Checksum:
+		return nil, fmt.Errorf("checksum mismatch: source %s, destination %s", want.Checksum, got.Checksum)
+	}
+	return got, nil
+}
+
+// errStopIteration прерывает обход, когда ответ уже известен.
+var errStopIteration = errors.New("stop iteration")
+
+func formatKinds(kinds map[string]int) string {
+	keys := slices.Sorted(maps.Keys(kinds))
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%d", k, kinds[k])
+	}
+	return strings.Join(parts, ",")
+}
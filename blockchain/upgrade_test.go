@@ -0,0 +1,75 @@
+package blockchain
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gregorybednov/lbc/kv"
+)
+
+func TestCopyStore(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     func(t *testing.T) kv.Store
+		dst     map[string]string // ключи, уже лежащие в dst
+		wantErr string
+	}{
+		{"empty", func(*testing.T) kv.Store { return kv.NewMemory() }, nil, ""},
+		{"ledger", func(t *testing.T) kv.Store {
+			c := newTestChain(t)
+			requireCodes(t, c.block(c.promiseTx(t, "1"), c.promiseTx(t, "2")), 0, 0)
+			requireCodes(t, c.block(c.fulfillmentTx(t, "1", "1", 0)), 0)
+			return c.db
+		}, nil, ""},
+		{"more than one batch", func(t *testing.T) kv.Store {
+			db := kv.NewMemory()
+			if err := kv.Update(db, func(txn kv.Txn) error {
+				for i := 0; i < 25000; i++ {
+					if err := txn.Set([]byte(fmt.Sprintf("x:%05d", i)), []byte{byte(i)}); err != nil {
+						return err
+					}
+				}
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			return db
+		}, nil, ""},
+		{"destination not empty", func(*testing.T) kv.Store { return kv.NewMemory() }, map[string]string{"meta:height": "1"}, "not empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := tt.src(t)
+			dst := kv.NewMemory()
+			if err := kv.Update(dst, func(txn kv.Txn) error {
+				for k, v := range tt.dst {
+					if err := txn.Set([]byte(k), []byte(v)); err != nil {
+						return err
+					}
+				}
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := CopyStore(dst, src)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("CopyStore: %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := Summarize(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Keys != want.Keys || got.Checksum != want.Checksum || got.StateHash != want.StateHash {
+				t.Errorf("summary %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,358 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gregorybednov/lbc/canonical"
+	"github.com/gregorybednov/lbc/kv"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+const (
+	validatorPrefix         = "validator:"
+	validatorProposalPrefix = "valproposal:"
+)
+
+const (
+	txAddValidator    = "add_validator"
+	txRemoveValidator = "remove_validator"
+	txSetPower        = "set_power"
+)
+
+// validatorTxBody — голос одного действующего валидатора за изменение набора.
+type validatorTxBody struct {
+	Type   string `json:"type"`    // add_validator | remove_validator | set_power
+	PubKey string `json:"pub_key"` // base64(ed25519) целевого валидатора
+	Power  int64  `json:"power"`   // для remove_validator — 0
+	Voter  string `json:"voter"`   // base64(ed25519) голосующего валидатора
+}
+
+type validatorRecord struct {
+	PubKey string `json:"pub_key"`
+	Power  int64  `json:"power"`
+}
+
+type validatorProposal struct {
+	Type   string   `json:"type"`
+	PubKey string   `json:"pub_key"`
+	Power  int64    `json:"power"`
+	Voters []string `json:"voters"`
+}
+
+func isValidatorTxType(t string) bool {
+	switch t {
+	case txAddValidator, txRemoveValidator, txSetPower:
+		return true
+	}
+	return false
+}
+
+// bodyType достаёт body.type из конверта, не разбирая остальное.
+func bodyType(tx []byte) string {
+	var tiny struct {
+		Body struct {
+			Type string `json:"type"`
+		} `json:"body"`
+	}
+	if err := json.Unmarshal(tx, &tiny); err != nil {
+		return ""
+	}
+	return tiny.Body.Type
+}
+
+func decodeEd25519PubKey(b64 string) ([]byte, error) {
+	pubkey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(b64))
+	if err != nil {
+		return nil, errors.New("invalid pubkey base64")
+	}
+	if len(pubkey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid pubkey length: got %d, want %d", len(pubkey), ed25519.PublicKeySize)
+	}
+	return pubkey, nil
+}
+
+// decodeValidatorPubKey — decodeEd25519PubKey, принимающий только
+// каноническую запись: ключ validator:<pub_key> строится из строки как есть,
+// и другая запись тех же байтов (пробелы, переводы строк, ненулевые хвостовые
+// биты) дала бы второй ключ для того же валидатора.
+func decodeValidatorPubKey(b64 string) ([]byte, error) {
+	pubkey, err := decodeEd25519PubKey(b64)
+	if err != nil {
+		return nil, err
+	}
+	if base64.StdEncoding.EncodeToString(pubkey) != b64 {
+		return nil, errors.New("pubkey is not in canonical base64")
+	}
+	return pubkey, nil
+}
+
+func validatorKey(pubKey string) []byte { return []byte(validatorPrefix + pubKey) }
+
+func proposalKey(b *validatorTxBody) []byte {
+	return []byte(fmt.Sprintf("%s%s:%s:%d", validatorProposalPrefix, b.Type, b.PubKey, b.Power))
+}
+
+// verifyValidatorTx проверяет форму голоса и подпись голосующего.
+func verifyValidatorTx(chainID string, tx []byte) (*validatorTxBody, error) {
+	var outerRaw compoundTxRaw
+	if err := json.Unmarshal(tx, &outerRaw); err != nil {
+		return nil, errors.New("invalid validator tx JSON")
+	}
+	var body validatorTxBody
+	if err := json.Unmarshal(outerRaw.Body, &body); err != nil {
+		return nil, errors.New("invalid body JSON")
+	}
+	if !isValidatorTxType(body.Type) {
+		return nil, fmt.Errorf("unknown validator tx type %q", body.Type)
+	}
+	if _, err := decodeValidatorPubKey(body.PubKey); err != nil {
+		return nil, fmt.Errorf("pub_key: %w", err)
+	}
+	voter, err := decodeValidatorPubKey(body.Voter)
+	if err != nil {
+		return nil, fmt.Errorf("voter: %w", err)
+	}
+	switch body.Type {
+	case txAddValidator, txSetPower:
+		if body.Power <= 0 {
+			return nil, errors.New("power must be positive")
+		}
+	case txRemoveValidator:
+		if body.Power != 0 {
+			return nil, errors.New("power must be 0 for remove_validator")
+		}
+	}
+
+	if err := canonical.Verify(voter, outerRaw.Signature, chainID, body.Type, outerRaw.Body); err != nil {
+		return nil, err
+	}
+	return &body, nil
+}
+
+func getJSON(r kv.Reader, key []byte, v interface{}) (bool, error) {
+	val, err := r.Get(key)
+	if err == kv.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(val, v)
+}
+
+func setJSON(txn kv.Writer, key []byte, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return txn.Set(key, data)
+}
+
+// loadValidators возвращает текущий набор валидаторов, упорядоченный по ключу.
+func loadValidators(r kv.Reader) ([]validatorRecord, error) {
+	var result []validatorRecord
+	err := r.Iterate([]byte(validatorPrefix), nil, func(_, val []byte) error {
+		var v validatorRecord
+		if err := json.Unmarshal(val, &v); err != nil {
+			return err
+		}
+		result = append(result, v)
+		return nil
+	})
+	return result, err
+}
+
+// checkValidatorTx проверяет голос против состояния, видимого через txn.
+func checkValidatorTx(txn kv.Reader, body *validatorTxBody) (code uint32, err error) {
+	var voter validatorRecord
+	ok, err := getJSON(txn, validatorKey(body.Voter), &voter)
+	if err != nil {
+		return 1, err
+	}
+	if !ok {
+		return 7, errors.New("voter is not an active validator")
+	}
+
+	var target validatorRecord
+	exists, err := getJSON(txn, validatorKey(body.PubKey), &target)
+	if err != nil {
+		return 1, err
+	}
+	switch body.Type {
+	case txAddValidator:
+		if exists {
+			return 3, errors.New("validator already active")
+		}
+	case txRemoveValidator, txSetPower:
+		if !exists {
+			return 4, errors.New("unknown validator")
+		}
+	}
+	if body.Type == txSetPower && target.Power == body.Power {
+		return 2, errors.New("validator already has this power")
+	}
+	if body.Type == txRemoveValidator {
+		vals, err := loadValidators(txn)
+		if err != nil {
+			return 1, err
+		}
+		if len(vals) <= 1 {
+			return 2, errors.New("cannot remove the last validator")
+		}
+	}
+
+	var prop validatorProposal
+	if _, err := getJSON(txn, proposalKey(body), &prop); err != nil {
+		return 1, err
+	}
+	for _, v := range prop.Voters {
+		if v == body.Voter {
+			return 3, errors.New("duplicate vote")
+		}
+	}
+	return 0, nil
+}
+
+// applyValidatorVote учитывает голос и, если набралось более 2/3 мощности
+// действующего набора, применяет изменение. Возвращает применённое обновление.
+func applyValidatorVote(txn kv.Txn, body *validatorTxBody) (*validatorRecord, error) {
+	var prop validatorProposal
+	if _, err := getJSON(txn, proposalKey(body), &prop); err != nil {
+		return nil, err
+	}
+	prop.Type, prop.PubKey, prop.Power = body.Type, body.PubKey, body.Power
+	prop.Voters = append(prop.Voters, body.Voter)
+
+	vals, err := loadValidators(txn)
+	if err != nil {
+		return nil, err
+	}
+	power := make(map[string]int64, len(vals))
+	var total, approved int64
+	for _, v := range vals {
+		power[v.PubKey] = v.Power
+		total += v.Power
+	}
+	// Голоса тех, кто уже выбыл из набора, не учитываются.
+	voters := prop.Voters[:0]
+	for _, v := range prop.Voters {
+		if p, ok := power[v]; ok {
+			approved += p
+			voters = append(voters, v)
+		}
+	}
+	prop.Voters = voters
+
+	if 3*approved <= 2*total {
+		return nil, setJSON(txn, proposalKey(body), &prop)
+	}
+
+	if err := txn.Delete(proposalKey(body)); err != nil {
+		return nil, err
+	}
+	update := &validatorRecord{PubKey: body.PubKey, Power: body.Power}
+	if body.Type == txRemoveValidator {
+		return update, txn.Delete(validatorKey(body.PubKey))
+	}
+	return update, setJSON(txn, validatorKey(body.PubKey), update)
+}
+
+// validatorUpdates переводит накопленные за блок изменения в ответ EndBlock:
+// по одному обновлению на ключ (последнее побеждает), в порядке ключей.
+func validatorUpdates(pending []validatorRecord) []abci.ValidatorUpdate {
+	last := make(map[string]int64, len(pending))
+	for _, u := range pending {
+		last[u.PubKey] = u.Power
+	}
+	keys := make([]string, 0, len(last))
+	for k := range last {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	updates := make([]abci.ValidatorUpdate, 0, len(keys))
+	for _, k := range keys {
+		pk, _ := base64.StdEncoding.DecodeString(k)
+		updates = append(updates, abci.Ed25519ValidatorUpdate(pk, last[k]))
+	}
+	return updates
+}
+
+func (app *PromiseApp) checkValidatorTx(st kv.Txn, tx []byte) abci.ResponseCheckTx {
+	body, err := verifyValidatorTx(app.chainID, tx)
+	if err != nil {
+		return abci.ResponseCheckTx{Code: 1, Log: err.Error()}
+	}
+	if code, err := checkValidatorTx(st, body); err != nil {
+		return abci.ResponseCheckTx{Code: code, Log: err.Error()}
+	}
+	// Голос учитывается и в состоянии проверки: повторный голос того же
+	// валидатора отсеется ещё в mempool.
+	if _, err := applyValidatorVote(st, body); err != nil {
+		return abci.ResponseCheckTx{Code: 1, Log: err.Error()}
+	}
+	return abci.ResponseCheckTx{Code: 0, Priority: validatorTxPriority}
+}
+
+func (app *PromiseApp) deliverValidatorTx(tx []byte) abci.ResponseDeliverTx {
+	body, err := verifyValidatorTx(app.chainID, tx)
+	if err != nil {
+		return abci.ResponseDeliverTx{Code: 1, Log: err.Error()}
+	}
+	if app.currentBatch == nil {
+		app.currentBatch = kv.NewWriteBuffer(app.db)
+	}
+	if code, err := checkValidatorTx(app.currentBatch, body); err != nil {
+		return abci.ResponseDeliverTx{Code: code, Log: err.Error()}
+	}
+	update, err := applyValidatorVote(app.currentBatch, body)
+	if err != nil {
+		return abci.ResponseDeliverTx{Code: 1, Log: err.Error()}
+	}
+	if update != nil {
+		app.validatorUpdates = append(app.validatorUpdates, *update)
+	}
+	return abci.ResponseDeliverTx{Code: 0}
+}
+
+// storeGenesisValidators сохраняет начальный набор из InitChain. Набор,
+// уже лежащий в базе (засеянный миграцией или изменённый голосованием), не
+// перезаписывается.
+func (app *PromiseApp) storeGenesisValidators(vals []abci.ValidatorUpdate) error {
+	return kv.Update(app.db, func(txn kv.Txn) error {
+		if seeded, err := hasValidators(txn); err != nil || seeded {
+			return err
+		}
+		for _, v := range vals {
+			pk := v.PubKey.GetEd25519()
+			if len(pk) != ed25519.PublicKeySize {
+				return errors.New("only ed25519 validators are supported")
+			}
+			rec := validatorRecord{PubKey: base64.StdEncoding.EncodeToString(pk), Power: v.Power}
+			if err := setJSON(txn, validatorKey(rec.PubKey), &rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// hasValidators сообщает, что набор валидаторов уже сохранён.
+func hasValidators(r kv.Reader) (bool, error) {
+	found := false
+	err := r.Iterate([]byte(validatorPrefix), nil, func(_, _ []byte) error {
+		found = true
+		return errStopIteration
+	})
+	if err == errStopIteration {
+		err = nil
+	}
+	return found, err
+}
@@ -0,0 +1,189 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/gregorybednov/lbc/kv"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	tmtypes "github.com/tendermint/tendermint/types"
+)
+
+type testValidator struct {
+	priv   ed25519.PrivateKey
+	pubKey string
+}
+
+func newValidators(t *testing.T, n int) []testValidator {
+	t.Helper()
+	vals := make([]testValidator, n)
+	for i := range vals {
+		pub, priv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vals[i] = testValidator{priv: priv, pubKey: base64.StdEncoding.EncodeToString(pub)}
+	}
+	return vals
+}
+
+// validatorChain — приложение с генезисным набором из валидаторов с
+// мощностями powers.
+func validatorChain(t *testing.T, powers ...int64) (*PromiseApp, []testValidator) {
+	t.Helper()
+	vals := newValidators(t, len(powers))
+	updates := make([]abci.ValidatorUpdate, len(vals))
+	for i, v := range vals {
+		updates[i] = abci.Ed25519ValidatorUpdate(v.priv.Public().(ed25519.PublicKey), powers[i])
+	}
+	app := NewPromiseApp(kv.NewMemory())
+	app.InitChain(abci.RequestInitChain{ChainId: testChainID, Validators: updates})
+	return app, vals
+}
+
+func (v testValidator) vote(t *testing.T, kind, pubKey string, power int64) []byte {
+	t.Helper()
+	return signTx(t, v.priv, kind, map[string]any{"type": kind, "pub_key": pubKey, "power": power, "voter": v.pubKey})
+}
+
+func validatorPower(t *testing.T, app *PromiseApp, pubKey string) (int64, bool) {
+	t.Helper()
+	var rec validatorRecord
+	var ok bool
+	if err := kv.View(app.db, func(r kv.Reader) error {
+		var err error
+		ok, err = getJSON(r, validatorKey(pubKey), &rec)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Power, ok
+}
+
+// TestValidatorVoteThreshold: изменение применяется, когда за него
+// проголосовало строго больше 2/3 мощности набора.
+func TestValidatorVoteThreshold(t *testing.T) {
+	cases := []struct {
+		name    string
+		powers  []int64
+		voters  []int
+		applied bool
+	}{
+		{"single validator", []int64{10}, []int{0}, true},
+		{"exactly two thirds", []int64{10, 10, 10}, []int{0, 1}, false},
+		{"all", []int64{10, 10, 10}, []int{0, 1, 2}, true},
+		{"just over two thirds", []int64{10, 10, 11}, []int{1, 2}, true},
+		{"heavy validator alone", []int64{70, 30}, []int{0}, true},
+		{"majority is not enough", []int64{60, 40}, []int{0}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			app, vals := validatorChain(t, tc.powers...)
+			target := newValidators(t, 1)[0]
+			txs := make([][]byte, len(tc.voters))
+			codes := make([]uint32, len(tc.voters))
+			for i, v := range tc.voters {
+				txs[i] = vals[v].vote(t, txAddValidator, target.pubKey, 5)
+			}
+			res, _ := deliverBlock(app, 1, txs...)
+			requireCodes(t, res, codes...)
+			power, ok := validatorPower(t, app, target.pubKey)
+			if ok != tc.applied || (ok && power != 5) {
+				t.Errorf("target: power %d, active %v; want active %v", power, ok, tc.applied)
+			}
+		})
+	}
+}
+
+func TestValidatorVoteCodes(t *testing.T) {
+	app, vals := validatorChain(t, 10)
+	outsider := newValidators(t, 2)
+	res, _ := deliverBlock(app, 1,
+		outsider[0].vote(t, txAddValidator, outsider[1].pubKey, 5),
+		vals[0].vote(t, txAddValidator, vals[0].pubKey, 5),
+		vals[0].vote(t, txRemoveValidator, outsider[1].pubKey, 0),
+		vals[0].vote(t, txSetPower, vals[0].pubKey, 10),
+		vals[0].vote(t, txRemoveValidator, vals[0].pubKey, 0),
+		vals[0].vote(t, txSetPower, vals[0].pubKey, 0),
+	)
+	requireCodes(t, res, 7, 3, 4, 2, 2, 1)
+
+	app, vals = validatorChain(t, 10, 10)
+	res, _ = deliverBlock(app, 1,
+		vals[0].vote(t, txSetPower, vals[1].pubKey, 20),
+		vals[0].vote(t, txSetPower, vals[1].pubKey, 20),
+	)
+	requireCodes(t, res, 0, 3)
+
+	// Та же пара байтов в неканонической записи — отдельный ключ в базе,
+	// поэтому такие голоса отвергаются.
+	app, vals = validatorChain(t, 10)
+	pub := vals[0].pubKey
+	last := strings.IndexByte(base64Alphabet, pub[len(pub)-2])
+	res, _ = deliverBlock(app, 1,
+		vals[0].vote(t, txSetPower, pub+" ", 20),
+		vals[0].vote(t, txSetPower, pub[:len(pub)-2]+string(base64Alphabet[last^1])+"=", 20),
+		signTx(t, vals[0].priv, txSetPower, map[string]any{"type": txSetPower, "pub_key": pub, "power": 20, "voter": "\n" + pub}),
+	)
+	requireCodes(t, res, 1, 1, 1)
+}
+
+const base64Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// TestInitChainKeepsValidators: повторный InitChain не затирает набор,
+// изменённый голосованием.
+func TestInitChainKeepsValidators(t *testing.T) {
+	app, vals := validatorChain(t, 10)
+	res, _ := deliverBlock(app, 1, vals[0].vote(t, txSetPower, vals[0].pubKey, 20))
+	requireCodes(t, res, 0)
+	app.InitChain(abci.RequestInitChain{ChainId: testChainID, Validators: []abci.ValidatorUpdate{
+		abci.Ed25519ValidatorUpdate(vals[0].priv.Public().(ed25519.PublicKey), 10),
+	}})
+	if power, _ := validatorPower(t, app, vals[0].pubKey); power != 20 {
+		t.Errorf("power %d after InitChain, want 20", power)
+	}
+}
+
+// TestMigrateValidators: база цепочки, начатой без сохранения набора,
+// получает набор из genesis; сохранённый набор миграция не трогает.
+func TestMigrateValidators(t *testing.T) {
+	vals := newValidators(t, 2)
+	genesis := &tmtypes.GenesisDoc{ChainID: testChainID}
+	for i, v := range vals {
+		genesis.Validators = append(genesis.Validators, tmtypes.GenesisValidator{
+			PubKey: tmed25519.PubKey(v.priv.Public().(ed25519.PublicKey)),
+			Power:  int64(10 * (i + 1)),
+		})
+	}
+
+	c := baselineDB(t)
+	if _, err := Migrate(c.db, MigrationEnv{Genesis: genesis}, false); err != nil {
+		t.Fatal(err)
+	}
+	app := NewPromiseApp(c.db)
+	for i, v := range vals {
+		if power, ok := validatorPower(t, app, v.pubKey); !ok || power != int64(10*(i+1)) {
+			t.Errorf("validator %d: power %d, active %v", i, power, ok)
+		}
+	}
+
+	app, own := validatorChain(t, 7)
+	if err := kv.Update(app.db, func(txn kv.Txn) error {
+		return txn.Set([]byte(schemaVersionKey), []byte("2"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Migrate(app.db, MigrationEnv{Genesis: genesis}, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := validatorPower(t, app, vals[0].pubKey); ok {
+		t.Error("migration added a genesis validator to an existing set")
+	}
+	if power, _ := validatorPower(t, app, own[0].pubKey); power != 7 {
+		t.Errorf("existing validator power %d, want 7", power)
+	}
+}
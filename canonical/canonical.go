@@ -0,0 +1,244 @@
+// Package canonical задаёт единственное каноническое JSON-кодирование, над
+// которым подписываются все транзакции lbc. Спецификация и эталонные векторы —
+// в docs/signing.md.
+//
+// Правила:
+//   - объекты: ключи отсортированы по байтам UTF-8, повторяющиеся ключи запрещены;
+//   - без пробелов между токенами;
+//   - строки: экранируются только `"`, `\` и управляющие символы < 0x20
+//     (\b \f \n \r \t коротко, остальные как \u00XX в нижнем регистре),
+//     всё прочее — как есть в UTF-8;
+//   - числа: только целые, без знака плюс, ведущих нулей, дробей и экспонент;
+//   - true, false, null — как есть.
+package canonical
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"unicode/utf8"
+)
+
+// Domain отделяет подписи lbc от подписей того же ключа в других протоколах.
+const Domain = "lbc/tx/v1"
+
+// Marshal кодирует Go-значение в канонический JSON.
+func Marshal(v any) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return Canonicalize(raw)
+}
+
+// Canonicalize переводит произвольный JSON-текст в каноническую форму.
+func Canonicalize(raw []byte) ([]byte, error) {
+	if !utf8.Valid(raw) {
+		return nil, errors.New("canonical: invalid UTF-8")
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var buf bytes.Buffer
+	if err := encodeValue(&buf, dec); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("canonical: trailing data after JSON value")
+	}
+	return buf.Bytes(), nil
+}
+
+// SignBytes — байты, которые подписывает клиент и проверяет узел:
+// канонический JSON объекта {"body", "chain_id", "domain", "type"}.
+func SignBytes(chainID, txType string, body []byte) ([]byte, error) {
+	if chainID == "" {
+		return nil, errors.New("canonical: empty chain id")
+	}
+	if txType == "" {
+		return nil, errors.New("canonical: empty tx type")
+	}
+	return Marshal(struct {
+		Body    json.RawMessage `json:"body"`
+		ChainID string          `json:"chain_id"`
+		Domain  string          `json:"domain"`
+		Type    string          `json:"type"`
+	}{body, chainID, Domain, txType})
+}
+
+// Sign подписывает body и возвращает подпись в base64.
+func Sign(priv ed25519.PrivateKey, chainID, txType string, body []byte) (string, error) {
+	msg, err := SignBytes(chainID, txType, body)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(priv, msg)), nil
+}
+
+// Verify проверяет base64-подпись sig ключа pubkey над body.
+func Verify(pubkey ed25519.PublicKey, sigB64, chainID, txType string, body []byte) error {
+	sig, err := base64.StdEncoding.DecodeString(sigB64)
+	if err != nil {
+		return errors.New("invalid signature base64")
+	}
+	if len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("invalid signature length: got %d, want %d", len(sig), ed25519.SignatureSize)
+	}
+	msg, err := SignBytes(chainID, txType, body)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pubkey, msg, sig) {
+		return errors.New("signature verification failed")
+	}
+	return nil
+}
+
+func encodeValue(buf *bytes.Buffer, dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("canonical: %w", err)
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			return encodeObject(buf, dec)
+		case '[':
+			return encodeArray(buf, dec)
+		}
+		return fmt.Errorf("canonical: unexpected %q", t)
+	case string:
+		encodeString(buf, t)
+	case json.Number:
+		return encodeNumber(buf, t)
+	case bool:
+		if t {
+			buf.WriteString("true")
+		} else {
+			buf.WriteString("false")
+		}
+	case nil:
+		buf.WriteString("null")
+	default:
+		return fmt.Errorf("canonical: unexpected token %v", tok)
+	}
+	return nil
+}
+
+func encodeObject(buf *bytes.Buffer, dec *json.Decoder) error {
+	members := map[string][]byte{}
+	var keys []string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("canonical: %w", err)
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return errors.New("canonical: object key is not a string")
+		}
+		if _, dup := members[key]; dup {
+			return fmt.Errorf("canonical: duplicate key %q", key)
+		}
+		var val bytes.Buffer
+		if err := encodeValue(&val, dec); err != nil {
+			return err
+		}
+		members[key] = val.Bytes()
+		keys = append(keys, key)
+	}
+	if _, err := dec.Token(); err != nil { // '}'
+		return fmt.Errorf("canonical: %w", err)
+	}
+
+	sort.Strings(keys)
+	buf.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		encodeString(buf, k)
+		buf.WriteByte(':')
+		buf.Write(members[k])
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func encodeArray(buf *bytes.Buffer, dec *json.Decoder) error {
+	buf.WriteByte('[')
+	for i := 0; dec.More(); i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := encodeValue(buf, dec); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil { // ']'
+		return fmt.Errorf("canonical: %w", err)
+	}
+	buf.WriteByte(']')
+	return nil
+}
+
+func encodeNumber(buf *bytes.Buffer, n json.Number) error {
+	s := n.String()
+	digits := s
+	if digits != "" && digits[0] == '-' {
+		digits = digits[1:]
+	}
+	if digits == "" {
+		return fmt.Errorf("canonical: invalid number %q", s)
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return fmt.Errorf("canonical: only integers are allowed, got %q", s)
+		}
+	}
+	if s == "-0" {
+		s = "0"
+	}
+	buf.WriteString(s)
+	return nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+func encodeString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if c < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hexDigits[c>>4])
+				buf.WriteByte(hexDigits[c&0xf])
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
@@ -0,0 +1,123 @@
+package canonical
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"os"
+	"testing"
+)
+
+const vectorsPath = "../docs/signing_vectors.json"
+
+// -update пересчитывает sign_bytes и подписи в docs/signing_vectors.json:
+// новый вектор добавляется в файл с name, chain_id, type и body.
+var update = flag.Bool("update", false, "rewrite "+vectorsPath)
+
+type vectorFile struct {
+	PubKey  string   `json:"pub_key"`
+	SeedHex string   `json:"seed_hex"`
+	Vectors []vector `json:"vectors"`
+}
+
+type vector struct {
+	Name      string          `json:"name"`
+	ChainID   string          `json:"chain_id"`
+	Type      string          `json:"type"`
+	Body      json.RawMessage `json:"body"`
+	SignBytes string          `json:"sign_bytes"`
+	Signature string          `json:"signature"`
+}
+
+func TestCanonicalize(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{`{"b": 1, "a": [true, null, "x"]}`, `{"a":[true,null,"x"],"b":1}`},
+		{`{"z": {"d": 0, "c": -0}}`, `{"z":{"c":0,"d":0}}`},
+		{`"<a & b>"`, `"<a & b>"`},
+		{`"\u00e9\n\u0001\"\\"`, "\"é\\n\\u0001\\\"\\\\\""},
+		{`-12`, `-12`},
+		{`1.5`, ``},
+		{`1e3`, ``},
+		{`{"a":1} {}`, ``},
+		{"\"\xff\"", ``},
+	}
+	for _, tc := range cases {
+		got, err := Canonicalize([]byte(tc.in))
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("%s: got %s, want error", tc.in, got)
+			}
+			continue
+		}
+		if err != nil || string(got) != tc.want {
+			t.Errorf("%s: got %s (%v), want %s", tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func TestSigningVectors(t *testing.T) {
+	data, err := os.ReadFile(vectorsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f vectorFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatal(err)
+	}
+	seed, err := hex.DecodeString(f.SeedHex)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		t.Fatalf("bad seed_hex %q", f.SeedHex)
+	}
+	priv := ed25519.NewKeyFromSeed(seed)
+	pub := priv.Public().(ed25519.PublicKey)
+	if got := base64.StdEncoding.EncodeToString(pub); got != f.PubKey {
+		t.Errorf("pub_key: got %s, want %s", got, f.PubKey)
+	}
+
+	for i := range f.Vectors {
+		v := &f.Vectors[i]
+		t.Run(v.Name, func(t *testing.T) {
+			msg, err := SignBytes(v.ChainID, v.Type, v.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sig, err := Sign(priv, v.ChainID, v.Type, v.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *update {
+				v.SignBytes, v.Signature = string(msg), sig
+			}
+			if string(msg) != v.SignBytes {
+				t.Errorf("sign_bytes:\n got %s\nwant %s", msg, v.SignBytes)
+			}
+			if sig != v.Signature {
+				t.Errorf("signature: got %s, want %s", sig, v.Signature)
+			}
+			if err := Verify(pub, v.Signature, v.ChainID, v.Type, v.Body); err != nil {
+				t.Errorf("verify: %v", err)
+			}
+			if err := Verify(pub, v.Signature, v.ChainID+"-other", v.Type, v.Body); err == nil {
+				t.Error("signature verified for another chain_id")
+			}
+		})
+	}
+
+	if *update {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(vectorsPath, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
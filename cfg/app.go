@@ -0,0 +1,41 @@
+package cfg
+
+import (
+	"fmt"
+
+	"github.com/gregorybednov/lbc/kv"
+
+	"github.com/spf13/viper"
+)
+
+// AppConfig — секция [app] конфигурации: настройки приложения lbc, а не
+// Tendermint (у того свой db_backend для хранилища блоков).
+type AppConfig struct {
+	// DBBackend — движок базы состояния: badger, badger4, memory или имя
+	// движка tm-db (goleveldb; boltdb и другие — при сборке с их тегами).
+	DBBackend string `mapstructure:"db_backend"`
+}
+
+// ReadApp читает секцию [app]; пустой db_backend — kv.DefaultBackend
+// (базы нод, созданных до появления секции, — Badger v1).
+func ReadApp(v *viper.Viper) (AppConfig, error) {
+	conf := AppConfig{DBBackend: kv.DefaultBackend}
+	if err := v.UnmarshalKey("app", &conf); err != nil {
+		return conf, fmt.Errorf("app: %w", err)
+	}
+	if conf.DBBackend == "" {
+		conf.DBBackend = kv.DefaultBackend
+	}
+	return conf, nil
+}
+
+// SetDBBackend записывает [app] db_backend в файл конфигурации
+// (lbc db upgrade).
+func SetDBBackend(configPath, backend string) error {
+	v, err := LoadViperConfig(configPath)
+	if err != nil {
+		return err
+	}
+	v.Set("app.db_backend", backend)
+	return v.WriteConfig()
+}
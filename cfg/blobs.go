@@ -0,0 +1,39 @@
+package cfg
+
+import (
+	"fmt"
+
+	"github.com/gregorybednov/lbc/blobstore"
+
+	"github.com/spf13/viper"
+)
+
+// BlobsConfig — секция [blobs] конфигурации: локальное хранилище вложений
+// обещаний и обмен ими с пирами через Yggdrasil.
+type BlobsConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Dir — каталог хранилища; пусто — blobs рядом с базой состояния.
+	Dir     string `mapstructure:"dir"`
+	Port    int    `mapstructure:"port"`     // одинаковый у всех нод сети
+	MaxSize int64  `mapstructure:"max_size"` // байт на одно вложение
+	Quota   int64  `mapstructure:"quota"`    // байт на всё хранилище; 0 — без ограничения
+}
+
+// ReadBlobs читает секцию [blobs]; отсутствующие поля получают значения по
+// умолчанию.
+func ReadBlobs(v *viper.Viper) (BlobsConfig, error) {
+	conf := BlobsConfig{Port: blobstore.DefaultPort, MaxSize: blobstore.DefaultMaxSize}
+	if err := v.UnmarshalKey("blobs", &conf); err != nil {
+		return conf, fmt.Errorf("blobs: %w", err)
+	}
+	if conf.Port <= 0 || conf.Port > 65535 {
+		return conf, fmt.Errorf("blobs: invalid port %d", conf.Port)
+	}
+	if conf.MaxSize <= 0 {
+		conf.MaxSize = blobstore.DefaultMaxSize
+	}
+	if conf.Quota < 0 {
+		return conf, fmt.Errorf("blobs: invalid quota %d", conf.Quota)
+	}
+	return conf, nil
+}
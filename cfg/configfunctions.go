@@ -11,6 +11,8 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/gregorybednov/lbc/blobstore"
+	"github.com/gregorybednov/lbc/kv"
 	"github.com/gregorybednov/lbc/yggdrasil"
 
 	"github.com/spf13/viper"
@@ -155,6 +157,45 @@ func WriteConfig(config *cfg.Config, configPath *string, nodeInfo p2p.NodeInfo)
 		"private_key_file":    *yggKeyPath,
 	})
 
+	v.Set("app", map[string]any{
+		"db_backend": kv.NewNodeBackend,
+	})
+
+	// mempool v1 упорядочивает транзакции по ResponseCheckTx.Priority
+	// (квоты коммитеров и доказательство работы, см. blockchain/antispam.go).
+	v.Set("mempool", map[string]any{
+		"version": "v1",
+		"recheck": true,
+	})
+
+	v.Set("webhooks", map[string]any{
+		"endpoints":              []map[string]any{},
+		"max_attempts":           DefaultWebhookMaxAttempts,
+		"overdue_check_interval": DefaultWebhookOverdueCheck.String(),
+	})
+
+	v.Set("blobs", map[string]any{
+		"enabled":  false,
+		"dir":      "",
+		"port":     blobstore.DefaultPort,
+		"max_size": blobstore.DefaultMaxSize,
+		"quota":    0,
+	})
+
+	leadTimes := make([]string, len(DefaultReminderLeadTimes))
+	for i, d := range DefaultReminderLeadTimes {
+		leadTimes[i] = d.String()
+	}
+	v.Set("reminders", map[string]any{
+		"enabled":        false,
+		"commiters":      []string{},
+		"lead_times":     leadTimes,
+		"check_interval": DefaultReminderCheckInterval.String(),
+		"senders":        []string{"log"},
+		"webhook":        map[string]any{"url": "", "secret": ""},
+		"mail":           map[string]any{"addr": "localhost:25", "from": "", "to": []string{}},
+	})
+
 	if a := ReadP2Peers(*configPath); a == "" {
 		//nodeId := nodeInfo.ID()
 		//myPeer := yggdrasil.GetYggdrasilAddress(v)
@@ -314,3 +355,25 @@ func InitJoiner(chainName, defaultConfigPath, path string) error {
 	pv.Save()
 	return nil
 }
+
+// SetGenesisAppState записывает app_state в genesis.json рядом с
+// конфигурацией, сохраняя остальные поля (в том числе p2peers).
+func SetGenesisAppState(defaultConfigDirectoryPath string, appState json.RawMessage) error {
+	genesisJsonPath := filepath.Join(defaultConfigDirectoryPath, "genesis.json")
+	file, err := os.ReadFile(genesisJsonPath)
+	if err != nil {
+		return err
+	}
+
+	var dat map[string]any
+	if err := json.Unmarshal(file, &dat); err != nil {
+		return fmt.Errorf("genesis.json: %w", err)
+	}
+	dat["app_state"] = appState
+
+	out, err := json.MarshalIndent(dat, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(genesisJsonPath, out, 0o644)
+}
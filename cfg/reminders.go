@@ -0,0 +1,89 @@
+package cfg
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	DefaultReminderLeadTimes     = []time.Duration{72 * time.Hour, 24 * time.Hour, time.Hour}
+	DefaultReminderCheckInterval = time.Minute
+)
+
+// ReminderWebhook — адрес, на который POST-ом уходят напоминания.
+type ReminderWebhook struct {
+	URL    string `mapstructure:"url"`
+	Secret string `mapstructure:"secret"`
+}
+
+// ReminderMail — отправка напоминаний через SMTP-сервер без авторизации
+// (обычно локальный MTA).
+type ReminderMail struct {
+	Addr string   `mapstructure:"addr"`
+	From string   `mapstructure:"from"`
+	To   []string `mapstructure:"to"`
+}
+
+// RemindersConfig — секция [reminders] конфигурации.
+type RemindersConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Commiters — ID коммитеров или имена ключей в keyring; пусто — все
+	// ключи keyring.
+	Commiters     []string        `mapstructure:"commiters"`
+	LeadTimes     []time.Duration `mapstructure:"lead_times"` // по убыванию
+	CheckInterval time.Duration   `mapstructure:"check_interval"`
+	Senders       []string        `mapstructure:"senders"` // log | webhook | mail
+	Webhook       ReminderWebhook `mapstructure:"webhook"`
+	Mail          ReminderMail    `mapstructure:"mail"`
+}
+
+// ReadReminders читает секцию [reminders]; отсутствующие поля получают
+// значения по умолчанию.
+func ReadReminders(v *viper.Viper) (RemindersConfig, error) {
+	// Списки не задаются заранее: mapstructure дописал бы значения поверх
+	// элементов по умолчанию.
+	conf := RemindersConfig{
+		CheckInterval: DefaultReminderCheckInterval,
+		Mail:          ReminderMail{Addr: "localhost:25"},
+	}
+	if err := v.UnmarshalKey("reminders", &conf); err != nil {
+		return conf, fmt.Errorf("reminders: %w", err)
+	}
+	if conf.LeadTimes == nil {
+		conf.LeadTimes = slices.Clone(DefaultReminderLeadTimes)
+	}
+	if conf.Senders == nil {
+		conf.Senders = []string{"log"}
+	}
+	for _, d := range conf.LeadTimes {
+		if d <= 0 {
+			return conf, fmt.Errorf("reminders: lead time must be positive, got %s", d)
+		}
+	}
+	sort.Slice(conf.LeadTimes, func(i, j int) bool { return conf.LeadTimes[i] > conf.LeadTimes[j] })
+	for _, s := range conf.Senders {
+		switch s {
+		case "log":
+		case "webhook":
+			u, err := url.Parse(conf.Webhook.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return conf, fmt.Errorf("reminders: invalid webhook url %q", conf.Webhook.URL)
+			}
+		case "mail":
+			if conf.Mail.Addr == "" || conf.Mail.From == "" || len(conf.Mail.To) == 0 {
+				return conf, fmt.Errorf("reminders: mail sender needs addr, from and to")
+			}
+		default:
+			return conf, fmt.Errorf("reminders: unknown sender %q", s)
+		}
+	}
+	if conf.CheckInterval <= 0 {
+		conf.CheckInterval = DefaultReminderCheckInterval
+	}
+	return conf, nil
+}
@@ -0,0 +1,53 @@
+package cfg
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	DefaultWebhookMaxAttempts  = 12
+	DefaultWebhookOverdueCheck = time.Minute
+)
+
+// WebhookEndpoint — один получатель из [[webhooks.endpoints]].
+type WebhookEndpoint struct {
+	URL    string   `mapstructure:"url"`
+	Secret string   `mapstructure:"secret"` // ключ HMAC-SHA256 подписи
+	Events []string `mapstructure:"events"` // пусто — все события
+}
+
+// WebhooksConfig — секция [webhooks] конфигурации.
+type WebhooksConfig struct {
+	Endpoints            []WebhookEndpoint `mapstructure:"endpoints"`
+	MaxAttempts          int               `mapstructure:"max_attempts"`
+	OverdueCheckInterval time.Duration     `mapstructure:"overdue_check_interval"`
+}
+
+// ReadWebhooks читает секцию [webhooks]; отсутствующие поля получают
+// значения по умолчанию.
+func ReadWebhooks(v *viper.Viper) (WebhooksConfig, error) {
+	conf := WebhooksConfig{
+		MaxAttempts:          DefaultWebhookMaxAttempts,
+		OverdueCheckInterval: DefaultWebhookOverdueCheck,
+	}
+	if err := v.UnmarshalKey("webhooks", &conf); err != nil {
+		return conf, fmt.Errorf("webhooks: %w", err)
+	}
+	for _, e := range conf.Endpoints {
+		u, err := url.Parse(e.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return conf, fmt.Errorf("webhooks: invalid endpoint url %q", e.URL)
+		}
+	}
+	if conf.MaxAttempts <= 0 {
+		conf.MaxAttempts = DefaultWebhookMaxAttempts
+	}
+	if conf.OverdueCheckInterval <= 0 {
+		conf.OverdueCheckInterval = DefaultWebhookOverdueCheck
+	}
+	return conf, nil
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gregorybednov/lbc/blobstore"
+	"github.com/gregorybednov/lbc/cfg"
+
+	"github.com/spf13/cobra"
+)
+
+var blobOut string
+
+var blobCmd = &cobra.Command{
+	Use:   "blob",
+	Short: "Вложения обещаний в локальном хранилище ноды",
+}
+
+var blobAddCmd = &cobra.Command{
+	Use:   "add <file>",
+	Short: "Положить файл в хранилище и вывести его хеш для --attach",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hash, err := addBlob(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), hash)
+		return nil
+	},
+}
+
+var blobGetCmd = &cobra.Command{
+	Use:   "get <sha256:hex>",
+	Short: "Выгрузить вложение из хранилища (чужие вложения — через REST ноды, GET /blobs)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := localBlobStore()
+		if err != nil {
+			return err
+		}
+		f, _, err := store.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if blobOut == "-" {
+			_, err = io.Copy(cmd.OutOrStdout(), f)
+			return err
+		}
+		out, err := os.Create(blobOut)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, f); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	},
+}
+
+// localBlobStore открывает хранилище вложений из секции [blobs] конфигурации
+// --config (без конфигурации — значения по умолчанию).
+func localBlobStore() (*blobstore.Store, error) {
+	conf := cfg.BlobsConfig{MaxSize: blobstore.DefaultMaxSize}
+	if v, err := cfg.LoadViperConfig(defaultConfigPath); err == nil {
+		if conf, err = cfg.ReadBlobs(v); err != nil {
+			return nil, err
+		}
+	}
+	return blobstore.Open(blobDir(conf), conf.MaxSize, conf.Quota)
+}
+
+// addBlob кладёт файл path в локальное хранилище и возвращает его хеш.
+func addBlob(path string) (string, error) {
+	store, err := localBlobStore()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return store.Put(f, "")
+}
+
+func init() {
+	blobGetCmd.Flags().StringVarP(&blobOut, "out", "O", "-", "Файл для содержимого (- для stdout)")
+
+	blobCmd.AddCommand(blobAddCmd, blobGetCmd)
+	rootCmd.AddCommand(blobCmd)
+}
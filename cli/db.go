@@ -0,0 +1,349 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/gregorybednov/lbc/blockchain"
+	"github.com/gregorybednov/lbc/cfg"
+	"github.com/gregorybednov/lbc/kv"
+
+	"github.com/spf13/cobra"
+	"github.com/tendermint/tendermint/store"
+	tmtypes "github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tm-db"
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Обслуживание базы данных ноды (нода должна быть остановлена)",
+}
+
+var dbMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Применить миграции схемы хранения",
+	Long: `Переводит базу на текущую версию схемы хранения. Нода делает это сама
+при запуске; команда позволяет выполнить миграции заранее или с --dry-run
+посмотреть, что изменится, не трогая базу.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		open := blockchain.OpenDB
+		if dryRun {
+			open = blockchain.OpenReadOnly
+		}
+		db, err := openNodeDB(open)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		from, err := blockchain.StoredSchemaVersion(db)
+		if err != nil {
+			return err
+		}
+		results, err := blockchain.Migrate(db, migrationEnv(), dryRun)
+		w := cmd.OutOrStdout()
+		fmt.Fprintf(w, "Версия схемы: %d, поддерживается: %d\n", from, blockchain.SchemaVersion())
+		for _, r := range results {
+			verb := "изменено"
+			if dryRun {
+				verb = "будет изменено"
+			}
+			fmt.Fprintf(w, "  %d: %s — %s ключей: %d\n", r.Version, r.Description, verb, r.Changed)
+		}
+		if err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			fmt.Fprintln(w, "Миграции не требуются.")
+		}
+		return nil
+	},
+}
+
+var dbGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Сжатие LSM и сборка мусора value log",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ratio, _ := cmd.Flags().GetFloat64("ratio")
+		if ratio <= 0 || ratio >= 1 {
+			return fmt.Errorf("--ratio должен быть в интервале (0, 1)")
+		}
+		_, lsm0, vlog0, err := blockchain.DirSize(dbPath)
+		if err != nil {
+			return err
+		}
+		db, err := openNodeDB(blockchain.OpenDB)
+		if err != nil {
+			return err
+		}
+		m, ok := db.(kv.Maintainer)
+		if !ok {
+			db.Close()
+			return fmt.Errorf("движок %s не поддерживает сборку мусора", dbBackend())
+		}
+		if err := m.Compact(); err != nil {
+			db.Close()
+			return fmt.Errorf("сжатие LSM: %w", err)
+		}
+		n, err := m.CollectGarbage(ratio)
+		if cerr := db.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("сборка мусора: %w", err)
+		}
+		_, lsm1, vlog1, err := blockchain.DirSize(dbPath)
+		if err != nil {
+			return err
+		}
+		w := cmd.OutOrStdout()
+		fmt.Fprintf(w, "Переписано файлов value log: %d\n", n)
+		fmt.Fprintf(w, "LSM: %s → %s, value log: %s → %s\n", byteSize(lsm0), byteSize(lsm1), byteSize(vlog0), byteSize(vlog1))
+		return nil
+	},
+}
+
+var dbStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Размер базы и число ключей по видам",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		total, lsm, vlog, err := blockchain.DirSize(dbPath)
+		if err != nil {
+			return err
+		}
+		db, err := openNodeDB(blockchain.OpenReadOnly)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		st, err := blockchain.NewPromiseApp(db).CollectStats()
+		if err != nil {
+			return err
+		}
+
+		w := cmd.OutOrStdout()
+		fmt.Fprintf(w, "Цепочка: %s, высота: %d, версия схемы: %d\n", st.ChainID, st.Height, st.SchemaVersion)
+		if backend := dbBackend(); backend == kv.Badger || backend == kv.Badger4 {
+			fmt.Fprintf(w, "LSM: %s (таблиц: %d), value log: %s\n\n", byteSize(lsm), st.Tables, byteSize(vlog))
+		} else {
+			fmt.Fprintf(w, "Движок: %s, на диске: %s\n\n", backend, byteSize(total))
+		}
+		kinds := make([]string, 0, len(st.Keys))
+		for k := range st.Keys {
+			kinds = append(kinds, k)
+		}
+		sort.Strings(kinds)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "KIND\tKEYS")
+		for _, k := range kinds {
+			fmt.Fprintf(tw, "%s\t%d\n", k, st.Keys[k])
+		}
+		return tw.Flush()
+	},
+}
+
+var dbVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Проверка записей по ER-модели и подписей транзакций из блоков",
+	Long: `Проверяет каждую запись: разбор, совпадение ID с ключом, обязательные
+поля и существование связанных записей. Затем, если доступно хранилище
+блоков Tendermint (по --config), перепроверяет подписи всех транзакций и
+сообщает о записях, для которых не нашлось подписанной транзакции.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := openNodeDB(blockchain.OpenReadOnly)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		app := blockchain.NewPromiseApp(db)
+		rep, err := app.VerifyRecords()
+		if err != nil {
+			return err
+		}
+
+		w := cmd.OutOrStdout()
+		blocks, _ := cmd.Flags().GetBool("blocks")
+		if blocks {
+			if err := verifyBlocks(app, rep); err != nil {
+				fmt.Fprintf(w, "Подписи не проверены: %v\n", err)
+			} else {
+				fmt.Fprintf(w, "Блоки %d..%d: транзакций %d, отклонено %d\n", rep.BlocksFrom, rep.BlocksTo, rep.Txs, rep.InvalidTxs)
+				fmt.Fprintf(w, "Записей без подписи (бенефициары): %d, из genesis: %d\n", rep.Unsigned, rep.Genesis)
+				fmt.Fprintf(w, "Обязательств по правилу повторения: %d\n", rep.Recurring)
+			}
+		}
+		fmt.Fprintf(w, "Записей проверено: %d, проблем: %d\n", rep.Records, len(rep.Problems))
+		for _, p := range rep.Problems {
+			fmt.Fprintf(w, "  %s\n", p)
+		}
+		if len(rep.Problems) > 0 {
+			return fmt.Errorf("найдено проблем: %d", len(rep.Problems))
+		}
+		return nil
+	},
+}
+
+func verifyBlocks(app *blockchain.PromiseApp, rep *blockchain.VerifyReport) error {
+	config, err := cfg.ReadConfig(defaultConfigPath)
+	if err != nil {
+		return err
+	}
+	bdb, err := dbm.NewDB("blockstore", dbm.BackendType(config.DBBackend), config.DBDir())
+	if err != nil {
+		return fmt.Errorf("хранилище блоков: %w", err)
+	}
+	defer bdb.Close()
+	return app.VerifyBlocks(store.NewBlockStore(bdb), rep)
+}
+
+var dbRepairCmd = &cobra.Command{
+	Use:   "repair",
+	Short: "Обрезать повреждённый хвост value log после аварийной остановки",
+	Long: `Открывает базу с обрезкой value log. Нужна, если нода не запускается с
+ошибкой о необходимости truncate. Записи после места повреждения теряются;
+после обрезки стоит выполнить lbc db verify.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := openNodeDB(blockchain.RepairDB)
+		if err != nil {
+			return err
+		}
+		if err := db.Close(); err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), "База открыта с обрезкой value log.")
+		return nil
+	},
+}
+
+var dbUpgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Перенести базу на другой движок (по умолчанию Badger v4) без пересинхронизации",
+	Long: `Копирует все ключи базы --badger в новую базу движка --to, сверяет число
+ключей по видам, контрольную сумму и state_hash записей, затем подменяет
+каталог: старая база остаётся рядом как <путь>.<движок> и её можно удалить
+после проверки. В конфигурации записывается [app] db_backend = <--to>.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		to, _ := cmd.Flags().GetString("to")
+		from := dbBackend()
+		if to == from {
+			return fmt.Errorf("база уже на движке %s", to)
+		}
+		if to == kv.Memory {
+			return fmt.Errorf("движок %s не сохраняет данные на диск", to)
+		}
+		if _, err := cfg.LoadViperConfig(defaultConfigPath); err != nil {
+			return fmt.Errorf("конфигурация %s: %w", defaultConfigPath, err)
+		}
+		tmpPath := dbPath + ".upgrade"
+		backupPath := dbPath + "." + from
+		for _, p := range []string{tmpPath, backupPath} {
+			if _, err := os.Stat(p); err == nil {
+				return fmt.Errorf("%s уже существует: удалите или переименуйте его", p)
+			}
+		}
+
+		src, err := openNodeDB(blockchain.OpenReadOnly)
+		if err != nil {
+			return err
+		}
+		dst, err := kv.Open(to, tmpPath, kv.Options{})
+		if err != nil {
+			src.Close()
+			return fmt.Errorf("новая база %s: %w", to, err)
+		}
+		sum, err := blockchain.CopyStore(dst, src)
+		if cerr := dst.Close(); err == nil {
+			err = cerr
+		}
+		src.Close()
+		if err != nil {
+			os.RemoveAll(tmpPath)
+			return fmt.Errorf("перенос не выполнен, база не изменена: %w", err)
+		}
+
+		if err := os.Rename(dbPath, backupPath); err != nil {
+			return err
+		}
+		if err := os.Rename(tmpPath, dbPath); err != nil {
+			return fmt.Errorf("%w; старая база в %s, новая — в %s", err, backupPath, tmpPath)
+		}
+		w := cmd.OutOrStdout()
+		fmt.Fprintf(w, "Перенесено ключей: %d, state_hash %s\n", sum.Keys, sum.StateHash)
+		if err := cfg.SetDBBackend(defaultConfigPath, to); err != nil {
+			return fmt.Errorf("база перенесена, но конфигурация не обновлена (задайте [app] db_backend = %q вручную): %w", to, err)
+		}
+		fmt.Fprintf(w, "База %s теперь на движке %s; старая сохранена в %s\n", dbPath, to, backupPath)
+		return nil
+	},
+}
+
+func byteSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// dbBackend — движок базы из [app] db_backend конфигурации --config;
+// без конфигурации — движок по умолчанию.
+func dbBackend() string {
+	v, err := cfg.LoadViperConfig(defaultConfigPath)
+	if err != nil {
+		return kv.DefaultBackend
+	}
+	app, err := cfg.ReadApp(v)
+	if err != nil {
+		return kv.DefaultBackend
+	}
+	return app.DBBackend
+}
+
+// migrationEnv читает genesis.json ноды для миграций. Без него миграции,
+// которым genesis не нужен, всё равно выполняются.
+func migrationEnv() blockchain.MigrationEnv {
+	config, err := cfg.ReadConfig(defaultConfigPath)
+	if err != nil {
+		return blockchain.MigrationEnv{}
+	}
+	genesis, err := tmtypes.GenesisDocFromFile(config.GenesisFile())
+	if err != nil {
+		return blockchain.MigrationEnv{}
+	}
+	return blockchain.MigrationEnv{Genesis: genesis}
+}
+
+// openNodeDB открывает базу из --badger с понятной ошибкой, если её держит
+// запущенная нода.
+func openNodeDB(open func(backend, path string) (kv.Store, error)) (kv.Store, error) {
+	db, err := open(dbBackend(), dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("база %s не открыта (нода остановлена?): %w", dbPath, err)
+	}
+	return db, nil
+}
+
+func init() {
+	dbMigrateCmd.Flags().Bool("dry-run", false, "Только показать, что будет изменено")
+
+	dbGCCmd.Flags().Float64("ratio", blockchain.GCDiscardRatio, "Доля мусора в файле value log, при которой он переписывается")
+	dbVerifyCmd.Flags().Bool("blocks", true, "Перепроверить подписи транзакций из хранилища блоков")
+	dbUpgradeCmd.Flags().String("to", kv.Badger4, "Движок новой базы")
+
+	dbCmd.AddCommand(dbMigrateCmd, dbGCCmd, dbStatsCmd, dbVerifyCmd, dbRepairCmd, dbUpgradeCmd)
+	rootCmd.AddCommand(dbCmd)
+}
@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/gregorybednov/lbc/blockchain"
+	"github.com/gregorybednov/lbc/cfg"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportHeight int64
+	exportFormat string
+	exportOut    string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Выгрузка всех записей реестра (нода должна быть остановлена)",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if exportFormat != "ndjson" && exportFormat != "json" {
+			return fmt.Errorf("неизвестный формат %q (ndjson|json)", exportFormat)
+		}
+		db, err := openNodeDB(blockchain.OpenReadOnly)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		dump, err := blockchain.NewPromiseApp(db).Export(exportHeight)
+		if err != nil {
+			return err
+		}
+
+		var w io.Writer = cmd.OutOrStdout()
+		if exportOut != "" && exportOut != "-" {
+			f, err := os.Create(exportOut)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		if exportFormat == "json" {
+			err = dump.WriteJSON(w)
+		} else {
+			err = dump.WriteNDJSON(w)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Выгружено записей: %d, высота %d, state_hash %s\n", dump.Count, dump.Height, dump.StateHash)
+		return nil
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <dump>",
+	Short: "Проверка выгрузки и запись её в app_state genesis.json новой цепочки",
+	Long: `Проверяет выгрузку (state_hash, число записей, связи между записями) и
+записывает её в app_state файла genesis.json рядом с --config. Записи
+попадут в базу при первом запуске ноды (InitChain), поэтому команду нужно
+выполнить до первого запуска, а полученный genesis.json раздать всем
+валидаторам новой цепочки.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		dump, err := blockchain.ReadDump(f)
+		if err != nil {
+			return err
+		}
+		if err := dump.Verify(); err != nil {
+			return fmt.Errorf("выгрузка не прошла проверку: %w", err)
+		}
+
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(dump); err != nil {
+			return err
+		}
+		if err := cfg.SetGenesisAppState(filepath.Dir(defaultConfigPath), bytes.TrimSpace(buf.Bytes())); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "В genesis.json записано %d записей (цепочка %s, высота %d, state_hash %s)\n",
+			dump.Count, dump.ChainID, dump.Height, dump.StateHash)
+		return nil
+	},
+}
+
+func init() {
+	exportCmd.Flags().Int64Var(&exportHeight, "height", 0, "Высота блока (0 — последний зафиксированный)")
+	exportCmd.Flags().StringVar(&exportFormat, "format", "ndjson", "Формат: ndjson или json")
+	exportCmd.Flags().StringVar(&exportOut, "out", "", "Файл выгрузки (по умолчанию stdout)")
+
+	rootCmd.AddCommand(exportCmd, importCmd)
+}
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -18,18 +19,32 @@ var initCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		switch args[0] {
 		case "genesis":
+			params, err := initParams()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return
+			}
 			config, viper, err := cfg.InitGenesis(chainName, defaultConfigPath)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v", err)
 				panic(err)
 			}
-			nodeinfo, err := blockchain.GetNodeInfo(config, dbPath)
+			app, err := cfg.ReadApp(viper)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v", err)
+				panic(err)
+			}
+			nodeinfo, err := blockchain.GetNodeInfo(config, app.DBBackend, dbPath)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v", err)
 				panic(err)
 			}
 
 			cfg.UpdateGenesisJson(nodeinfo, viper, filepath.Dir(defaultConfigPath))
+			if err := writeGenesisParams(params); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v", err)
+				panic(err)
+			}
 			fmt.Println("Genesis node initialized.")
 		case "join":
 			if len(args) < 2 {
@@ -46,6 +61,35 @@ var initCmd = &cobra.Command{
 	},
 }
 
+var (
+	initAdmission    string
+	initEndorsements int
+)
+
+// initParams собирает параметры приложения из флагов init genesis.
+func initParams() (blockchain.Params, error) {
+	p := blockchain.DefaultParams()
+	p.Admission = blockchain.AdmissionParams{Policy: initAdmission, Endorsements: initEndorsements}
+	return p, p.Validate()
+}
+
+// writeGenesisParams записывает параметры в app_state genesis.json;
+// параметры по умолчанию не пишутся.
+func writeGenesisParams(p blockchain.Params) error {
+	if p == blockchain.DefaultParams() {
+		return nil
+	}
+	appState, err := json.Marshal(struct {
+		Params blockchain.Params `json:"params"`
+	}{p})
+	if err != nil {
+		return err
+	}
+	return cfg.SetGenesisAppState(filepath.Dir(defaultConfigPath), appState)
+}
+
 func init() {
+	initCmd.Flags().StringVar(&initAdmission, "admission", blockchain.AdmissionOpen, "Политика приёма коммитеров (genesis): open, invite или vote")
+	initCmd.Flags().IntVar(&initEndorsements, "endorsements", 0, "Число поручительств действующих коммитеров для --admission vote")
 	rootCmd.AddCommand(initCmd)
 }
@@ -0,0 +1,257 @@
+package cli
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gregorybednov/lbc/keyring"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+const passphraseEnv = "LBC_KEYRING_PASSPHRASE"
+
+var keyringDir string
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Управление ключами коммитеров",
+}
+
+var keysAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Создать новый ключ",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		kr, err := keyring.Open(keyringDir)
+		if err != nil {
+			return err
+		}
+		pass, err := readNewPassphrase()
+		if err != nil {
+			return err
+		}
+		rec, err := kr.Add(args[0], pass)
+		if err != nil {
+			return err
+		}
+		return printKeyRecords(cmd, []*keyring.Record{rec})
+	},
+}
+
+var keysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Список ключей",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		kr, err := keyring.Open(keyringDir)
+		if err != nil {
+			return err
+		}
+		recs, err := kr.List()
+		if err != nil {
+			return err
+		}
+		return printKeyRecords(cmd, recs)
+	},
+}
+
+var keysShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Публичный ключ и ID коммитера",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		kr, err := keyring.Open(keyringDir)
+		if err != nil {
+			return err
+		}
+		rec, err := kr.Get(args[0])
+		if err != nil {
+			return err
+		}
+		return printKeyRecords(cmd, []*keyring.Record{rec})
+	},
+}
+
+var keysExportCmd = &cobra.Command{
+	Use:   "export <name>",
+	Short: "Экспорт ключа (зашифрованная запись или, с --unsafe-hex, открытый hex)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		unsafeHex, _ := cmd.Flags().GetBool("unsafe-hex")
+		kr, err := keyring.Open(keyringDir)
+		if err != nil {
+			return err
+		}
+		rec, err := kr.Get(args[0])
+		if err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+		if unsafeHex {
+			pass, err := readPassphrase("Пароль: ")
+			if err != nil {
+				return err
+			}
+			priv, err := rec.Decrypt(pass)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(out, hex.EncodeToString(priv))
+			return err
+		}
+		data, err := json.MarshalIndent(rec, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	},
+}
+
+var keysImportCmd = &cobra.Command{
+	Use:   "import <name> <file>",
+	Short: "Импорт ключа из экспортированной записи или hex-файла (--key)",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		kr, err := keyring.Open(keyringDir)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(args[1])
+		if err != nil {
+			return err
+		}
+
+		var rec *keyring.Record
+		var exported keyring.Record
+		if json.Unmarshal(data, &exported) == nil && exported.Ciphertext != "" {
+			pass, err := readPassphrase("Пароль экспортированного ключа: ")
+			if err != nil {
+				return err
+			}
+			rec, err = kr.ImportRecord(args[0], &exported, pass)
+			if err != nil {
+				return err
+			}
+		} else {
+			priv, err := loadCommiterKey(args[1])
+			if err != nil {
+				return err
+			}
+			pass, err := readNewPassphrase()
+			if err != nil {
+				return err
+			}
+			if rec, err = kr.Import(args[0], priv, pass); err != nil {
+				return err
+			}
+		}
+		return printKeyRecords(cmd, []*keyring.Record{rec})
+	},
+}
+
+var keysDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Удалить ключ",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		yes, _ := cmd.Flags().GetBool("yes")
+		kr, err := keyring.Open(keyringDir)
+		if err != nil {
+			return err
+		}
+		if _, err := kr.Get(args[0]); err != nil {
+			return err
+		}
+		if !yes {
+			fmt.Fprintf(os.Stderr, "Удалить ключ %q без возможности восстановления? [y/N]: ", args[0])
+			answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+				return errors.New("отменено")
+			}
+		}
+		return kr.Delete(args[0])
+	},
+}
+
+func printKeyRecords(cmd *cobra.Command, recs []*keyring.Record) error {
+	tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tPUBKEY\tCOMMITER ID")
+	for _, r := range recs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Name, r.PubKey, r.CommiterID)
+	}
+	return tw.Flush()
+}
+
+// readPassphrase берёт пароль из LBC_KEYRING_PASSPHRASE, с терминала без эха
+// или, если stdin не терминал, из первой строки stdin.
+func readPassphrase(prompt string) (string, error) {
+	if p, ok := os.LookupEnv(passphraseEnv); ok {
+		return p, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, prompt)
+		b, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(b), err
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("read passphrase: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func readNewPassphrase() (string, error) {
+	pass, err := readPassphrase("Новый пароль: ")
+	if err != nil {
+		return "", err
+	}
+	if _, ok := os.LookupEnv(passphraseEnv); ok || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return pass, nil
+	}
+	again, err := readPassphrase("Повторите пароль: ")
+	if err != nil {
+		return "", err
+	}
+	if pass != again {
+		return "", errors.New("пароли не совпадают")
+	}
+	return pass, nil
+}
+
+// unlockKey возвращает ключ коммитера из keyring (если задано имя) или из файла.
+func unlockKey(from, keyFile string) (ed25519.PrivateKey, error) {
+	if from == "" {
+		return loadCommiterKey(keyFile)
+	}
+	kr, err := keyring.Open(keyringDir)
+	if err != nil {
+		return nil, err
+	}
+	pass, err := readPassphrase(fmt.Sprintf("Пароль ключа %q: ", from))
+	if err != nil {
+		return nil, err
+	}
+	return kr.Unlock(from, pass)
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&keyringDir, "keyring", "./config/keyring", "Каталог с ключами коммитеров")
+
+	keysExportCmd.Flags().Bool("unsafe-hex", false, "Вывести приватный ключ в открытом виде (hex)")
+	keysDeleteCmd.Flags().BoolP("yes", "y", false, "Не спрашивать подтверждение")
+
+	keysCmd.AddCommand(keysAddCmd, keysListCmd, keysShowCmd, keysExportCmd, keysImportCmd, keysDeleteCmd)
+	rootCmd.AddCommand(keysCmd)
+}
@@ -0,0 +1,271 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	rpchttp "github.com/tendermint/tendermint/rpc/client/http"
+)
+
+var queryOutput string
+
+var queryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "Чтение состояния реестра через abci_query",
+}
+
+var queryListCmd = &cobra.Command{
+	Use:   "list <kind>",
+	Short: "Список записей: commiter, beneficiary, promise, commitment, fulfillment",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runQuery(cmd, "list/"+args[0])
+	},
+}
+
+var queryGetCmd = &cobra.Command{
+	Use:   "get <id>",
+	Short: "Одна запись по ID",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runQuery(cmd, "get/"+args[0])
+	},
+}
+
+var queryPromisesCmd = &cobra.Command{
+	Use:   "promises",
+	Short: "Обещания бенефициара (--beneficiary) или дочерние обещания (--parent)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		beneficiary, _ := cmd.Flags().GetString("beneficiary")
+		parent, _ := cmd.Flags().GetString("parent")
+		switch {
+		case beneficiary != "" && parent == "":
+			return runQuery(cmd, "rel/promises-of-beneficiary/"+beneficiary)
+		case parent != "" && beneficiary == "":
+			return runQuery(cmd, "rel/children/"+parent)
+		}
+		return errors.New("укажите ровно один из флагов --beneficiary или --parent")
+	},
+}
+
+var queryCommitmentsCmd = &cobra.Command{
+	Use:   "commitments",
+	Short: "Обязательства по обещанию (--promise) или коммитера (--commiter)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		promise, _ := cmd.Flags().GetString("promise")
+		commiter, _ := cmd.Flags().GetString("commiter")
+		switch {
+		case promise != "" && commiter == "":
+			return runQuery(cmd, "rel/commitments-of-promise/"+promise)
+		case commiter != "" && promise == "":
+			return runQuery(cmd, "rel/commitments-of-commiter/"+commiter)
+		}
+		return errors.New("укажите ровно один из флагов --promise или --commiter")
+	},
+}
+
+var queryFulfillmentsCmd = &cobra.Command{
+	Use:   "fulfillments",
+	Short: "Отметки о выполнении обязательства (--commitment)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		commitment, _ := cmd.Flags().GetString("commitment")
+		if commitment == "" {
+			return errors.New("укажите --commitment")
+		}
+		return runQuery(cmd, "rel/fulfillments-of-commitment/"+commitment)
+	},
+}
+
+var queryProgressCmd = &cobra.Command{
+	Use:   "progress <id>",
+	Short: "Выполнение обязательства или обещания: объём, выполнено, осталось",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runQuery(cmd, "progress/"+args[0])
+	},
+}
+
+var queryReputationCmd = &cobra.Command{
+	Use:   "reputation <commiter id>",
+	Short: "Исполнительность коммитера: выполненные, пропущенные и текущие обязательства",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runQuery(cmd, "reputation/"+args[0])
+	},
+}
+
+var queryParamsCmd = &cobra.Command{
+	Use:   "params",
+	Short: "Параметры приложения (политика приёма коммитеров)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		value, err := abciQuery("params")
+		if err != nil {
+			return err
+		}
+		var out bytes.Buffer
+		if err := json.Indent(&out, value, "", "  "); err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), out.String())
+		return nil
+	},
+}
+
+var queryValidatorsCmd = &cobra.Command{
+	Use:   "validators",
+	Short: "Действующий набор валидаторов",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runQuery(cmd, "validators")
+	},
+}
+
+var queryValproposalsCmd = &cobra.Command{
+	Use:   "valproposals",
+	Short: "Незавершённые голосования за изменение набора валидаторов",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runQuery(cmd, "valproposals")
+	},
+}
+
+func abciQuery(path string) ([]byte, error) {
+	client, err := rpchttp.New(nodeAddr, "/websocket")
+	if err != nil {
+		return nil, fmt.Errorf("rpc client: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	res, err := client.ABCIQuery(ctx, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("abci_query: %w", err)
+	}
+	if res.Response.Code != 0 {
+		return nil, fmt.Errorf("запрос %q не выполнен (code %d): %s", path, res.Response.Code, res.Response.Log)
+	}
+	return res.Response.Value, nil
+}
+
+func runQuery(cmd *cobra.Command, path string) error {
+	value, err := abciQuery(path)
+	if err != nil {
+		return err
+	}
+	return printRecords(cmd.OutOrStdout(), value, queryOutput)
+}
+
+// printRecords выводит JSON-массив записей (или одну запись) в выбранном формате.
+func printRecords(w io.Writer, value []byte, format string) error {
+	if format == "json" {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, value, "", "  "); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+		_, err := buf.WriteTo(w)
+		return err
+	}
+
+	var rows []map[string]any
+	trimmed := bytes.TrimSpace(value)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var one map[string]any
+		if err := json.Unmarshal(trimmed, &one); err != nil {
+			return err
+		}
+		rows = append(rows, one)
+	} else if err := json.Unmarshal(trimmed, &rows); err != nil {
+		return err
+	}
+	cols := recordColumns(rows)
+
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write(cols); err != nil {
+			return err
+		}
+		for _, r := range rows {
+			if err := cw.Write(recordCells(r, cols)); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(cols, "\t")))
+		for _, r := range rows {
+			fmt.Fprintln(tw, strings.Join(recordCells(r, cols), "\t"))
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("неизвестный формат вывода %q (table|json|csv)", format)
+}
+
+// recordColumns: сначала id и type, затем остальные поля по алфавиту.
+func recordColumns(rows []map[string]any) []string {
+	seen := map[string]bool{}
+	var rest []string
+	for _, r := range rows {
+		for k := range r {
+			if !seen[k] {
+				seen[k] = true
+				if k != "id" && k != "type" {
+					rest = append(rest, k)
+				}
+			}
+		}
+	}
+	sort.Strings(rest)
+	var cols []string
+	for _, k := range []string{"id", "type"} {
+		if seen[k] {
+			cols = append(cols, k)
+		}
+	}
+	return append(cols, rest...)
+}
+
+func recordCells(r map[string]any, cols []string) []string {
+	cells := make([]string, len(cols))
+	for i, c := range cols {
+		switch v := r[c].(type) {
+		case nil:
+		case string:
+			cells[i] = v
+		case float64:
+			cells[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			b, _ := json.Marshal(v)
+			cells[i] = string(b)
+		}
+	}
+	return cells
+}
+
+func init() {
+	queryCmd.PersistentFlags().StringVar(&nodeAddr, "node", "tcp://127.0.0.1:26657", "RPC-адрес ноды")
+	queryCmd.PersistentFlags().StringVarP(&queryOutput, "output", "o", "table", "Формат вывода: table, json или csv")
+
+	queryPromisesCmd.Flags().String("beneficiary", "", "ID бенефициара")
+	queryPromisesCmd.Flags().String("parent", "", "ID родительского обещания")
+	queryCommitmentsCmd.Flags().String("promise", "", "ID обещания")
+	queryCommitmentsCmd.Flags().String("commiter", "", "ID коммитера")
+	queryFulfillmentsCmd.Flags().String("commitment", "", "ID обязательства")
+
+	queryCmd.AddCommand(queryListCmd, queryGetCmd, queryPromisesCmd, queryCommitmentsCmd, queryFulfillmentsCmd, queryProgressCmd, queryReputationCmd, queryParamsCmd, queryValidatorsCmd, queryValproposalsCmd)
+	rootCmd.AddCommand(queryCmd)
+}
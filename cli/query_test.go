@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrintRecords(t *testing.T) {
+	list := `[{"type":"promise","id":"promise:1","text":"a, b","due":4000000000,"parent_promise_id":null},` +
+		`{"id":"promise:2","type":"promise","text":"c","due":1.5,"attachments":["sha256:00"]}]`
+	tests := []struct {
+		name    string
+		value   string
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{"json", `{"id":"x","n":1}`, "json", "{\n  \"id\": \"x\",\n  \"n\": 1\n}\n", false},
+		{"csv", list, "csv", "id,type,attachments,due,parent_promise_id,text\n" +
+			"promise:1,promise,,4000000000,,\"a, b\"\n" +
+			"promise:2,promise,\"[\"\"sha256:00\"\"]\",1.5,,c\n", false},
+		{"table", list, "table", "" +
+			"ID         TYPE     ATTACHMENTS    DUE         PARENT_PROMISE_ID  TEXT\n" +
+			"promise:1  promise                 4000000000                     a, b\n" +
+			"promise:2  promise  [\"sha256:00\"]  1.5                            c\n", false},
+		{"single record", `{"type":"beneficiary","id":"beneficiary:1","name":"B"}`, "csv", "id,type,name\nbeneficiary:1,beneficiary,B\n", false},
+		{"empty list", `[]`, "csv", "\n", false},
+		{"unknown format", list, "yaml", "", true},
+		{"invalid JSON", `[{`, "table", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := printRecords(&buf, []byte(tt.value), tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && buf.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
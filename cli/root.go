@@ -7,23 +7,42 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 
+	"github.com/gregorybednov/lbc/blobstore"
 	"github.com/gregorybednov/lbc/blockchain"
 	"github.com/gregorybednov/lbc/cfg"
+	"github.com/gregorybednov/lbc/gql"
+	"github.com/gregorybednov/lbc/keyring"
+	"github.com/gregorybednov/lbc/reminder"
+	"github.com/gregorybednov/lbc/rest"
+	"github.com/gregorybednov/lbc/stream"
+	"github.com/gregorybednov/lbc/webhook"
 	"github.com/gregorybednov/lbc/yggdrasil"
 
 	"github.com/spf13/cobra"
+	rpchttp "github.com/tendermint/tendermint/rpc/client/http"
 )
 
 var defaultConfigPath string
 var dbPath string
 var chainName string
+var restAddr string
+var restCORS string
+var graphqlAddr string
+var eventsAddr string
+var wsOrigins []string
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&defaultConfigPath, "config", "./config/config.toml", "Путь к конфигурационному файлу")
-	rootCmd.PersistentFlags().StringVar(&dbPath, "badger", "./badger", "Путь к базе данных BadgerDB")
+	rootCmd.PersistentFlags().StringVar(&dbPath, "badger", "./badger", "Путь к базе данных состояния (движок — [app] db_backend)")
 	rootCmd.PersistentFlags().StringVar(&chainName, "chainname", "lbc-chain", "Название цепочки блоков")
+	rootCmd.Flags().StringVar(&restAddr, "rest", "", "Адрес REST-шлюза, например 127.0.0.1:8080 (пусто — не запускать)")
+	rootCmd.Flags().StringVar(&restCORS, "rest-cors", "", "Значение Access-Control-Allow-Origin для REST-шлюза")
+	rootCmd.Flags().StringVar(&eventsAddr, "events", "", "Адрес потока событий (/events, SSE и WebSocket), например 127.0.0.1:8082 (пусто — не запускать)")
+	rootCmd.Flags().StringVar(&graphqlAddr, "graphql", "", "Адрес GraphQL-сервера (/graphql), например 127.0.0.1:8081 (пусто — не запускать)")
+	rootCmd.Flags().StringSliceVar(&wsOrigins, "ws-origins", nil, "Источники (Origin), с которых разрешён WebSocket к --graphql и --events, кроме своего хоста; * — любые")
 }
 
 var rootCmd = &cobra.Command{
@@ -55,7 +74,50 @@ var rootCmd = &cobra.Command{
 		ctx, cancel := context.WithCancel(context.Background())
 		laddrReturner := make(chan string, 3)
 		go yggdrasil.Yggdrasil(v, laddrReturner)
-		go blockchain.Run(ctx, dbPath, config, laddrReturner)
+		var services []blockchain.Service
+		if graphqlAddr != "" {
+			services = append(services, gql.Service(graphqlAddr, wsOrigins))
+		}
+		if eventsAddr != "" {
+			services = append(services, stream.Service(eventsAddr, wsOrigins))
+		}
+		if hooks, err := cfg.ReadWebhooks(v); err != nil {
+			fmt.Fprintf(os.Stderr, "вебхуки отключены: %v\n", err)
+		} else if len(hooks.Endpoints) > 0 {
+			services = append(services, webhook.Service(hooks, webhookStatusFile()))
+		}
+		if rem, err := cfg.ReadReminders(v); err != nil {
+			fmt.Fprintf(os.Stderr, "напоминания отключены: %v\n", err)
+		} else if rem.Enabled {
+			if svc, err := reminderService(rem); err != nil {
+				fmt.Fprintf(os.Stderr, "напоминания отключены: %v\n", err)
+			} else {
+				services = append(services, svc)
+			}
+		}
+		var blobs *blobstore.Fetcher
+		if conf, err := cfg.ReadBlobs(v); err != nil {
+			fmt.Fprintf(os.Stderr, "вложения отключены: %v\n", err)
+		} else if conf.Enabled {
+			if store, err := blobstore.Open(blobDir(conf), conf.MaxSize, conf.Quota); err != nil {
+				fmt.Fprintf(os.Stderr, "вложения отключены: %v\n", err)
+			} else {
+				blobs = blobstore.NewFetcher(store, conf.Port)
+				services = append(services, func(ctx context.Context, _ *blockchain.PromiseApp) error {
+					return blobstore.Serve(ctx, store, conf.Port)
+				})
+			}
+		}
+		app, err := cfg.ReadApp(v)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "конфигурация не прочитана: %v\n", err)
+			cancel()
+			return
+		}
+		go blockchain.Run(ctx, app.DBBackend, dbPath, config, laddrReturner, services...)
+		if restAddr != "" && config != nil {
+			startREST(ctx, config.RPC.ListenAddress, blobs)
+		}
 
 		if err != nil {
 			// TODO exitWithError("error creating node", err)
@@ -67,6 +129,73 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+// startREST поднимает REST-шлюз, который ходит в RPC этой же ноды.
+func startREST(ctx context.Context, rpcAddr string, blobs *blobstore.Fetcher) {
+	node, err := rpchttp.New(rpcAddr, "/websocket")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "REST-шлюз не запущен: %v\n", err)
+		return
+	}
+	go func() {
+		if err := rest.ListenAndServe(ctx, restAddr, rest.New(node, restCORS).WithBlobs(blobs)); err != nil {
+			fmt.Fprintf(os.Stderr, "REST-шлюз остановлен: %v\n", err)
+		}
+	}()
+}
+
+// blobDir — каталог хранилища вложений: [blobs] dir или blobs рядом с базой
+// состояния.
+// webhookStatusFile — сводка очереди вебхуков рядом с базой; её читает
+// `lbc webhooks status`.
+func webhookStatusFile() string {
+	return filepath.Join(filepath.Dir(dbPath), webhook.StatusFileName)
+}
+
+func blobDir(conf cfg.BlobsConfig) string {
+	if conf.Dir != "" {
+		return conf.Dir
+	}
+	return filepath.Join(filepath.Dir(dbPath), "blobs")
+}
+
+// reminderService переводит имена ключей из [reminders] commiters в ID
+// коммитеров; пустой список — все ключи keyring.
+func reminderService(conf cfg.RemindersConfig) (blockchain.Service, error) {
+	kr, err := keyring.Open(keyringDir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, c := range conf.Commiters {
+		if strings.HasPrefix(c, "commiter:") {
+			ids = append(ids, c)
+			continue
+		}
+		rec, err := kr.Get(c)
+		if err != nil {
+			return nil, fmt.Errorf("ключ %q: %w", c, err)
+		}
+		ids = append(ids, rec.CommiterID)
+	}
+	if len(conf.Commiters) == 0 {
+		recs, err := kr.List()
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range recs {
+			ids = append(ids, rec.CommiterID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("нет коммитеров: укажите [reminders] commiters или добавьте ключ (lbc keys add)")
+	}
+	senders, err := reminder.Senders(conf, os.Stdout)
+	if err != nil {
+		return nil, err
+	}
+	return reminder.Service(conf, ids, senders), nil
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "ошибка: %v\n", err)
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/gregorybednov/lbc/sealed"
+	types "github.com/gregorybednov/lbc_sdk"
+
+	"github.com/spf13/cobra"
+)
+
+var sealedOut string
+
+var sealedCmd = &cobra.Command{
+	Use:   "sealed",
+	Short: "Зашифрованный текст обещаний: чтение и выборочное раскрытие",
+}
+
+var sealedOpenCmd = &cobra.Command{
+	Use:   "open <promise id>",
+	Short: "Расшифровать текст обещания ключом получателя (--from или --key)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		d, err := openSealedPromise(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), d.Text)
+		return nil
+	},
+}
+
+var sealedDiscloseCmd = &cobra.Command{
+	Use:   "disclose <promise id>",
+	Short: "Записать раскрытие текста (текст и соль) для передачи третьей стороне",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		d, err := openSealedPromise(args[0])
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+		if sealedOut == "-" {
+			_, err = cmd.OutOrStdout().Write(data)
+			return err
+		}
+		return os.WriteFile(sealedOut, data, 0o600)
+	},
+}
+
+var sealedVerifyCmd = &cobra.Command{
+	Use:   "verify <disclosure.json>",
+	Short: "Сверить раскрытие с хешем зашифрованного текста на цепочке",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		var d sealed.Disclosure
+		if err := json.Unmarshal(data, &d); err != nil {
+			return fmt.Errorf("%s: %w", args[0], err)
+		}
+		p, err := queryPromise(d.PromiseID)
+		if err != nil {
+			return err
+		}
+		if err := d.Verify(p.Text); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Раскрытие совпадает с хешем обещания %s\n", d.PromiseID)
+		return nil
+	},
+}
+
+func queryPromise(id string) (*types.PromiseTxBody, error) {
+	value, err := abciQuery("get/" + id)
+	if err != nil {
+		return nil, err
+	}
+	var p types.PromiseTxBody
+	if err := json.Unmarshal(value, &p); err != nil {
+		return nil, err
+	}
+	if p.Type != "promise" {
+		return nil, fmt.Errorf("%s — не обещание", id)
+	}
+	return &p, nil
+}
+
+func openSealedPromise(id string) (*sealed.Disclosure, error) {
+	p, err := queryPromise(id)
+	if err != nil {
+		return nil, err
+	}
+	if !sealed.IsSealed(p.Text) {
+		return nil, errors.New("текст обещания не зашифрован")
+	}
+	priv, err := unlockKey(txFrom, txKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	d, err := sealed.Open(p.Text, priv)
+	if err != nil {
+		return nil, err
+	}
+	d.PromiseID = p.ID
+	return d, nil
+}
+
+func init() {
+	sealedCmd.PersistentFlags().StringVar(&nodeAddr, "node", "tcp://127.0.0.1:26657", "RPC-адрес ноды")
+	sealedCmd.PersistentFlags().StringVar(&txKeyFile, "key", "./config/commiter.key", "Файл с ключом коммитера (hex ed25519)")
+	sealedCmd.PersistentFlags().StringVar(&txFrom, "from", "", "Имя ключа в keyring (вместо --key)")
+	sealedDiscloseCmd.Flags().StringVarP(&sealedOut, "out", "O", "-", "Файл раскрытия (- для stdout)")
+
+	sealedCmd.AddCommand(sealedOpenCmd, sealedDiscloseCmd, sealedVerifyCmd)
+	rootCmd.AddCommand(sealedCmd)
+}
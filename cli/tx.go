@@ -0,0 +1,554 @@
+package cli
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gregorybednov/lbc/blobstore"
+	"github.com/gregorybednov/lbc/codec"
+	"github.com/gregorybednov/lbc/keyring"
+	"github.com/gregorybednov/lbc/sealed"
+	types "github.com/gregorybednov/lbc_sdk"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	rpchttp "github.com/tendermint/tendermint/rpc/client/http"
+)
+
+var (
+	nodeAddr        string
+	txBroadcastMode string
+	txKeyFile       string
+	txFrom          string
+	txEncoding      string
+)
+
+var txCmd = &cobra.Command{
+	Use:   "tx",
+	Short: "Сборка, подпись и отправка транзакций",
+}
+
+var txBuildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Собрать транзакцию в файл, не отправляя её (для офлайн-подписи)",
+}
+
+// txSpec описывает один вид транзакции: его флаги и сборку body. Из одного
+// описания получаются две команды: `tx <use>` (подписать и отправить) и
+// `tx build <use>` (записать в файл).
+type txSpec struct {
+	use   string
+	short string
+	kind  string // тип транзакции в домене подписи (canonical.SignBytes)
+	flags func(fs *pflag.FlagSet)
+	// build собирает body; signer — публичный ключ обязательного подписанта
+	// (nil, если подпись не требуется). Строки info печатаются пользователю.
+	build func(cmd *cobra.Command, signer ed25519.PublicKey) (body any, info []string, err error)
+	// genKey: если файла --key нет, создать новый ключ (register-commiter).
+	genKey bool
+	// optionalSigner: подпись не обязательна (register-beneficiary).
+	optionalSigner bool
+}
+
+var txSpecs = []txSpec{
+	{
+		use:    "register-commiter",
+		kind:   "commiter",
+		short:  "Зарегистрировать коммитера (ключ создаётся, если файла ещё нет)",
+		genKey: true,
+		flags: func(fs *pflag.FlagSet) {
+			fs.String("name", "", "Имя коммитера")
+		},
+		build: func(cmd *cobra.Command, signer ed25519.PublicKey) (any, []string, error) {
+			name, _ := cmd.Flags().GetString("name")
+			if strings.TrimSpace(name) == "" {
+				return nil, nil, errors.New("укажите --name")
+			}
+			pub := base64.StdEncoding.EncodeToString(signer)
+			body := types.CommiterTxBody{
+				Type:           "commiter",
+				ID:             keyring.CommiterID(signer),
+				Name:           name,
+				CommiterPubKey: pub,
+			}
+			return body, []string{"commiter id: " + body.ID}, nil
+		},
+	},
+	{
+		use:            "register-beneficiary",
+		kind:           "beneficiary",
+		short:          "Зарегистрировать бенефициара",
+		optionalSigner: true,
+		flags: func(fs *pflag.FlagSet) {
+			fs.String("name", "", "Имя бенефициара")
+			fs.String("id", "", "ID бенефициара (по умолчанию beneficiary:<uuid>)")
+		},
+		build: func(cmd *cobra.Command, _ ed25519.PublicKey) (any, []string, error) {
+			name, _ := cmd.Flags().GetString("name")
+			id, _ := cmd.Flags().GetString("id")
+			if strings.TrimSpace(name) == "" {
+				return nil, nil, errors.New("укажите --name")
+			}
+			if id == "" {
+				id = "beneficiary:" + newUUID()
+			}
+			body := types.BeneficiaryTxBody{Type: "beneficiary", ID: id, Name: name}
+			return body, []string{"beneficiary id: " + id}, nil
+		},
+	},
+	{
+		use:   "promise",
+		kind:  "promise",
+		short: "Дать обещание: promise и commitment одной транзакцией",
+		flags: func(fs *pflag.FlagSet) {
+			fs.String("text", "", "Текст обещания")
+			fs.String("beneficiary", "", "ID бенефициара")
+			fs.String("parent", "", "ID родительского обещания")
+			fs.String("due", "", "Срок обещания: unix-время, YYYY-MM-DD или RFC3339")
+			fs.String("commitment-due", "", "Срок обязательства (по умолчанию равен --due)")
+			fs.Bool("sealed", false, "Зашифровать текст для коммитера и получателей --recipient")
+			fs.StringArray("recipient", nil, "Получатель зашифрованного текста: имя в keyring, commiter:<base64> или base64 ed25519")
+			fs.StringArray("attach", nil, "Вложение: файл (кладётся в хранилище ноды) или хеш sha256:<hex>")
+			fs.Int64("quantity", 0, "Объём обещания в единицах --unit (выполняется частями: tx fulfill)")
+			fs.String("unit", "", "Единица объёма: kg, pcs, h...")
+			fs.Int64("commitment-quantity", 0, "Доля коммитера (по умолчанию весь --quantity)")
+			fs.String("every", "", "Повторять обещание с периодом: 24h, 7d, 2w (первый период — --commitment-due)")
+			fs.String("until", "", "Последний срок повторения: unix-время, YYYY-MM-DD или RFC3339 (по умолчанию без конца)")
+		},
+		build: func(cmd *cobra.Command, signer ed25519.PublicKey) (any, []string, error) {
+			text, _ := cmd.Flags().GetString("text")
+			beneficiary, _ := cmd.Flags().GetString("beneficiary")
+			parent, _ := cmd.Flags().GetString("parent")
+			dueStr, _ := cmd.Flags().GetString("due")
+			commitDueStr, _ := cmd.Flags().GetString("commitment-due")
+			seal, _ := cmd.Flags().GetBool("sealed")
+			recipients, _ := cmd.Flags().GetStringArray("recipient")
+			attach, _ := cmd.Flags().GetStringArray("attach")
+			quantity, _ := cmd.Flags().GetInt64("quantity")
+			unit, _ := cmd.Flags().GetString("unit")
+			commitQuantity, _ := cmd.Flags().GetInt64("commitment-quantity")
+			everyStr, _ := cmd.Flags().GetString("every")
+			untilStr, _ := cmd.Flags().GetString("until")
+
+			if strings.TrimSpace(text) == "" {
+				return nil, nil, errors.New("укажите --text")
+			}
+			if beneficiary == "" {
+				return nil, nil, errors.New("укажите --beneficiary")
+			}
+			due, err := parseDue(dueStr)
+			if err != nil {
+				return nil, nil, fmt.Errorf("--due: %w", err)
+			}
+			commitDue := due
+			if commitDueStr != "" {
+				if commitDue, err = parseDue(commitDueStr); err != nil {
+					return nil, nil, fmt.Errorf("--commitment-due: %w", err)
+				}
+			}
+			var info []string
+			if seal {
+				keys := []ed25519.PublicKey{signer}
+				for _, r := range recipients {
+					pub, err := resolveRecipient(r)
+					if err != nil {
+						return nil, nil, fmt.Errorf("--recipient %s: %w", r, err)
+					}
+					keys = append(keys, pub)
+				}
+				if text, err = sealed.Seal(text, keys); err != nil {
+					return nil, nil, err
+				}
+				info = append(info, fmt.Sprintf("text sealed for %d recipient(s)", len(keys)))
+			} else if len(recipients) > 0 {
+				return nil, nil, errors.New("--recipient имеет смысл только с --sealed")
+			}
+
+			promise := &codec.Promise{PromiseTxBody: types.PromiseTxBody{
+				Type:          "promise",
+				ID:            "promise:" + newUUID(),
+				Text:          text,
+				Due:           due,
+				BeneficiaryID: beneficiary,
+			}}
+			if everyStr != "" {
+				every, err := parseEvery(everyStr)
+				if err != nil {
+					return nil, nil, fmt.Errorf("--every: %w", err)
+				}
+				until, err := parseDue(untilStr)
+				if err != nil {
+					return nil, nil, fmt.Errorf("--until: %w", err)
+				}
+				if commitDue == 0 {
+					return nil, nil, errors.New("повторяющемуся обещанию нужен --due или --commitment-due")
+				}
+				promise.Recurrence = &codec.Recurrence{Period: int64(every / time.Second), Until: until}
+				info = append(info, "recurs every "+every.String())
+			} else if untilStr != "" {
+				return nil, nil, errors.New("--until имеет смысл только с --every")
+			}
+			for _, a := range attach {
+				hash := a
+				if _, err := blobstore.ParseHash(a); err != nil {
+					if hash, err = addBlob(a); err != nil {
+						return nil, nil, fmt.Errorf("--attach %s: %w", a, err)
+					}
+				}
+				promise.Attachments = append(promise.Attachments, hash)
+				info = append(info, "attachment: "+hash)
+			}
+			if parent != "" {
+				promise.ParentPromiseID = &parent
+			}
+			commitment := &codec.Commitment{CommitmentTxBody: types.CommitmentTxBody{
+				Type:       "commitment",
+				ID:         "commitment:" + newUUID(),
+				PromiseID:  promise.ID,
+				CommiterID: keyring.CommiterID(signer),
+				Due:        commitDue,
+			}}
+			if quantity > 0 {
+				promise.Quantity, promise.Unit = quantity, unit
+				commitment.Quantity, commitment.Unit = quantity, unit
+				if commitQuantity > 0 {
+					commitment.Quantity = commitQuantity
+				}
+			} else if unit != "" || commitQuantity > 0 {
+				return nil, nil, errors.New("--unit и --commitment-quantity имеют смысл только с --quantity")
+			}
+
+			compound := codec.Compound{Promise: promise, Commitment: commitment}
+			info = append(info, "promise id: "+promise.ID, "commitment id: "+commitment.ID)
+			return compound, info, nil
+		},
+	},
+	{
+		use:   "fulfill",
+		kind:  "fulfillment",
+		short: "Отметить выполнение обязательства (целиком или частью --quantity)",
+		flags: func(fs *pflag.FlagSet) {
+			fs.String("commitment", "", "ID обязательства")
+			fs.Int64("quantity", 0, "Выполненная часть в единицах обязательства (только для обязательства с объёмом)")
+		},
+		build: func(cmd *cobra.Command, _ ed25519.PublicKey) (any, []string, error) {
+			commitment, _ := cmd.Flags().GetString("commitment")
+			quantity, _ := cmd.Flags().GetInt64("quantity")
+			if commitment == "" {
+				return nil, nil, errors.New("укажите --commitment")
+			}
+			if quantity < 0 {
+				return nil, nil, errors.New("--quantity не может быть отрицательным")
+			}
+			body := &codec.Fulfillment{
+				Type:         "fulfillment",
+				ID:           "fulfillment:" + newUUID(),
+				CommitmentID: commitment,
+				Quantity:     quantity,
+			}
+			return body, []string{"fulfillment id: " + body.ID}, nil
+		},
+	},
+}
+
+// resolveRecipient находит открытый ключ получателя зашифрованного текста:
+// по имени в keyring, по ID коммитера вида commiter:<base64> или по base64.
+func resolveRecipient(s string) (ed25519.PublicKey, error) {
+	b64 := strings.TrimPrefix(s, "commiter:")
+	if pub, err := base64.StdEncoding.DecodeString(b64); err == nil && len(pub) == ed25519.PublicKeySize {
+		return pub, nil
+	}
+	kr, err := keyring.Open(keyringDir)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := kr.Get(s)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(rec.PubKey)
+}
+
+// resolveSigner находит ключ подписанта. Без needPriv достаточно публичного
+// ключа: из --pubkey, из открытой части записи keyring или из файла --key.
+func resolveSigner(cmd *cobra.Command, spec txSpec, needPriv bool) (ed25519.PrivateKey, ed25519.PublicKey, error) {
+	if !needPriv {
+		if s, _ := cmd.Flags().GetString("pubkey"); s != "" {
+			pub, err := base64.StdEncoding.DecodeString(s)
+			if err != nil || len(pub) != ed25519.PublicKeySize {
+				return nil, nil, errors.New("--pubkey: ожидается base64 ed25519")
+			}
+			return nil, pub, nil
+		}
+		if txFrom != "" {
+			kr, err := keyring.Open(keyringDir)
+			if err != nil {
+				return nil, nil, err
+			}
+			rec, err := kr.Get(txFrom)
+			if err != nil {
+				return nil, nil, err
+			}
+			pub, _ := base64.StdEncoding.DecodeString(rec.PubKey)
+			return nil, pub, nil
+		}
+	}
+
+	if txFrom == "" {
+		if _, err := os.Stat(txKeyFile); err != nil {
+			switch {
+			case spec.optionalSigner:
+				return nil, nil, nil
+			case spec.genKey && needPriv:
+				priv, err := genCommiterKey(txKeyFile)
+				if err != nil {
+					return nil, nil, err
+				}
+				return priv, priv.Public().(ed25519.PublicKey), nil
+			}
+		}
+	}
+	priv, err := unlockKey(txFrom, txKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return priv, priv.Public().(ed25519.PublicKey), nil
+}
+
+func newTxCmd(spec txSpec) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   spec.use,
+		Short: spec.short,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			priv, pub, err := resolveSigner(cmd, spec, true)
+			if err != nil {
+				return err
+			}
+			body, info, err := spec.build(cmd, pub)
+			if err != nil {
+				return err
+			}
+			f, err := newTxFile(chainName, spec.kind, body, pub)
+			if err != nil {
+				return err
+			}
+			if priv != nil {
+				if err := f.sign(priv); err != nil {
+					return err
+				}
+			}
+			for _, line := range info {
+				fmt.Fprintln(cmd.OutOrStdout(), line)
+			}
+			tx, err := f.envelope()
+			if err != nil {
+				return err
+			}
+			return broadcastTx(cmd, tx)
+		},
+	}
+	spec.flags(cmd.Flags())
+	return cmd
+}
+
+func newTxBuildCmd(spec txSpec) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   spec.use,
+		Short: spec.short,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			unsigned, _ := cmd.Flags().GetBool("unsigned")
+			out, _ := cmd.Flags().GetString("out")
+
+			priv, pub, err := resolveSigner(cmd, spec, !unsigned)
+			if err != nil {
+				return err
+			}
+			body, info, err := spec.build(cmd, pub)
+			if err != nil {
+				return err
+			}
+			f, err := newTxFile(chainName, spec.kind, body, pub)
+			if err != nil {
+				return err
+			}
+			if priv != nil {
+				if err := f.sign(priv); err != nil {
+					return err
+				}
+			}
+			for _, line := range info {
+				fmt.Fprintln(cmd.ErrOrStderr(), line)
+			}
+			return f.write(cmd, out)
+		},
+	}
+	spec.flags(cmd.Flags())
+	cmd.Flags().Bool("unsigned", false, "Не подписывать: достаточно публичного ключа (--pubkey, --from или --key)")
+	cmd.Flags().String("pubkey", "", "Публичный ключ подписанта (base64), только с --unsigned")
+	cmd.Flags().StringP("out", "O", "-", "Файл транзакции (- для stdout)")
+	return cmd
+}
+
+func broadcastTx(cmd *cobra.Command, tx []byte) error {
+	switch txEncoding {
+	case "json":
+	case "proto":
+		env, err := codec.FromJSON(tx)
+		if err != nil {
+			return err
+		}
+		if tx, err = codec.EncodeTx(env); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("неизвестное кодирование %q (json|proto)", txEncoding)
+	}
+
+	client, err := rpchttp.New(nodeAddr, "/websocket")
+	if err != nil {
+		return fmt.Errorf("rpc client: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	out := cmd.OutOrStdout()
+	switch txBroadcastMode {
+	case "async":
+		res, err := client.BroadcastTxAsync(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("broadcast: %w", err)
+		}
+		fmt.Fprintf(out, "tx hash: %s\n", res.Hash)
+	case "sync":
+		res, err := client.BroadcastTxSync(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("broadcast: %w", err)
+		}
+		fmt.Fprintf(out, "tx hash: %s\n", res.Hash)
+		if res.Code != 0 {
+			return fmt.Errorf("CheckTx отклонил транзакцию (code %d): %s", res.Code, res.Log)
+		}
+	case "commit":
+		res, err := client.BroadcastTxCommit(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("broadcast: %w", err)
+		}
+		fmt.Fprintf(out, "tx hash: %s\n", res.Hash)
+		if res.CheckTx.Code != 0 {
+			return fmt.Errorf("CheckTx отклонил транзакцию (code %d): %s", res.CheckTx.Code, res.CheckTx.Log)
+		}
+		if res.DeliverTx.Code != 0 {
+			return fmt.Errorf("DeliverTx отклонил транзакцию (code %d): %s", res.DeliverTx.Code, res.DeliverTx.Log)
+		}
+		fmt.Fprintf(out, "height: %d\n", res.Height)
+	default:
+		return fmt.Errorf("неизвестный режим отправки %q (sync|async|commit)", txBroadcastMode)
+	}
+	return nil
+}
+
+// Ключ коммитера хранится так же, как ключ Yggdrasil: hex приватного ed25519.
+func loadCommiterKey(path string) (ed25519.PrivateKey, error) {
+	if path == "" {
+		return nil, errors.New("укажите --key")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key: %w", err)
+	}
+	decoded, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("decode key: %w", err)
+	}
+	if len(decoded) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid private key size: %d", len(decoded))
+	}
+	return ed25519.PrivateKey(decoded), nil
+}
+
+func genCommiterKey(path string) (ed25519.PrivateKey, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(priv)), 0o600); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "Создан новый ключ коммитера: %s\n", path)
+	return priv, nil
+}
+
+// parseDue принимает unix-секунды, дату YYYY-MM-DD или RFC3339.
+func parseDue(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.Unix(), nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return 0, fmt.Errorf("ожидается unix-время, YYYY-MM-DD или RFC3339: %q", s)
+	}
+	return t.Unix(), nil
+}
+
+// parseEvery разбирает период повторения: длительность Go (24h) или число
+// дней (7d) и недель (2w).
+func parseEvery(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			k, err := strconv.Atoi(n)
+			if err != nil || k <= 0 {
+				return 0, fmt.Errorf("ожидается длительность вида 24h, 7d или 2w: %q", s)
+			}
+			return time.Duration(k) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("ожидается длительность вида 24h, 7d или 2w: %q", s)
+	}
+	return d, nil
+}
+
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func init() {
+	txCmd.PersistentFlags().StringVar(&nodeAddr, "node", "tcp://127.0.0.1:26657", "RPC-адрес ноды")
+	txCmd.PersistentFlags().StringVar(&txBroadcastMode, "broadcast-mode", "sync", "Режим отправки: sync, async или commit")
+	txCmd.PersistentFlags().StringVar(&txKeyFile, "key", "./config/commiter.key", "Файл с ключом коммитера (hex ed25519)")
+	txCmd.PersistentFlags().StringVar(&txFrom, "from", "", "Имя ключа в keyring (вместо --key)")
+	txCmd.PersistentFlags().StringVar(&txEncoding, "encoding", "json", "Кодирование транзакции: json или proto")
+
+	for _, spec := range txSpecs {
+		txCmd.AddCommand(newTxCmd(spec))
+		txBuildCmd.AddCommand(newTxBuildCmd(spec))
+	}
+	txCmd.AddCommand(txBuildCmd)
+	rootCmd.AddCommand(txCmd)
+}
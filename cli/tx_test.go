@@ -0,0 +1,203 @@
+package cli
+
+import (
+	"crypto/ed25519"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gregorybednov/lbc/codec"
+	"github.com/gregorybednov/lbc/keyring"
+)
+
+func TestParseDue(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"1700000000", 1700000000, false},
+		{"2024-01-02", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC).Unix(), false},
+		{"2024-01-02T03:04:05+03:00", time.Date(2024, 1, 2, 0, 4, 5, 0, time.UTC).Unix(), false},
+		{"tomorrow", 0, true},
+		{"2024-13-01", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseDue(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseDue(%q) = %d, %v; want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseEvery(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"24h", 24 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"7d", 7 * 24 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"0d", 0, true},
+		{"-1h", 0, true},
+		{"xd", 0, true},
+		{"weekly", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseEvery(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseEvery(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func findSpec(t *testing.T, use string) txSpec {
+	t.Helper()
+	for _, s := range txSpecs {
+		if s.use == use {
+			return s
+		}
+	}
+	t.Fatalf("no tx spec %q", use)
+	return txSpec{}
+}
+
+// buildTx собирает body транзакции use с флагами flags, как `tx build`.
+func buildTx(t *testing.T, use string, signer ed25519.PublicKey, flags map[string][]string) (any, error) {
+	t.Helper()
+	spec := findSpec(t, use)
+	cmd := newTxBuildCmd(spec)
+	for name, values := range flags {
+		for _, v := range values {
+			if err := cmd.Flags().Set(name, v); err != nil {
+				t.Fatalf("--%s %s: %v", name, v, err)
+			}
+		}
+	}
+	body, _, err := spec.build(cmd, signer)
+	return body, err
+}
+
+func TestBuildPromise(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := map[string][]string{"text": {"t"}, "beneficiary": {"beneficiary:1"}, "due": {"2030-01-01"}}
+	with := func(extra map[string][]string) map[string][]string {
+		out := map[string][]string{}
+		for k, v := range base {
+			out[k] = v
+		}
+		for k, v := range extra {
+			out[k] = v
+		}
+		return out
+	}
+	hash := "sha256:" + strings.Repeat("ab", 32)
+	due := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+
+	tests := []struct {
+		name    string
+		flags   map[string][]string
+		check   func(t *testing.T, c codec.Compound)
+		wantErr string
+	}{
+		{"plain", base, func(t *testing.T, c codec.Compound) {
+			if c.Promise.Due != due || c.Commitment.Due != due || c.Commitment.PromiseID != c.Promise.ID {
+				t.Errorf("promise %+v, commitment %+v", c.Promise, c.Commitment)
+			}
+			if c.Commitment.CommiterID != keyring.CommiterID(pub) || c.Promise.ParentPromiseID != nil {
+				t.Errorf("commitment %+v", c.Commitment)
+			}
+		}, ""},
+		{"parent and commitment due", with(map[string][]string{"parent": {"promise:0"}, "commitment-due": {"1900000000"}}), func(t *testing.T, c codec.Compound) {
+			if c.Promise.ParentPromiseID == nil || *c.Promise.ParentPromiseID != "promise:0" || c.Commitment.Due != 1900000000 {
+				t.Errorf("promise %+v, commitment %+v", c.Promise, c.Commitment)
+			}
+		}, ""},
+		{"quantity", with(map[string][]string{"quantity": {"100"}, "unit": {"kg"}, "commitment-quantity": {"40"}}), func(t *testing.T, c codec.Compound) {
+			if c.Promise.Quantity != 100 || c.Promise.Unit != "kg" || c.Commitment.Quantity != 40 || c.Commitment.Unit != "kg" {
+				t.Errorf("promise %+v, commitment %+v", c.Promise, c.Commitment)
+			}
+		}, ""},
+		{"recurrence", with(map[string][]string{"every": {"1w"}, "until": {"2031-01-01"}}), func(t *testing.T, c codec.Compound) {
+			want := codec.Recurrence{Period: 7 * 24 * 3600, Until: time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC).Unix()}
+			if c.Promise.Recurrence == nil || *c.Promise.Recurrence != want {
+				t.Errorf("recurrence %+v, want %+v", c.Promise.Recurrence, want)
+			}
+		}, ""},
+		{"attachment hash", with(map[string][]string{"attach": {hash}}), func(t *testing.T, c codec.Compound) {
+			if len(c.Promise.Attachments) != 1 || c.Promise.Attachments[0] != hash {
+				t.Errorf("attachments %v", c.Promise.Attachments)
+			}
+		}, ""},
+		{"no text", map[string][]string{"beneficiary": {"beneficiary:1"}}, nil, "--text"},
+		{"no beneficiary", map[string][]string{"text": {"t"}}, nil, "--beneficiary"},
+		{"bad due", with(map[string][]string{"due": {"soon"}}), nil, "--due"},
+		{"unit without quantity", with(map[string][]string{"unit": {"kg"}}), nil, "--quantity"},
+		{"until without every", with(map[string][]string{"until": {"2031-01-01"}}), nil, "--every"},
+		{"every without due", map[string][]string{"text": {"t"}, "beneficiary": {"beneficiary:1"}, "every": {"1d"}}, nil, "--due"},
+		{"recipient without sealed", with(map[string][]string{"recipient": {"commiter:x"}}), nil, "--sealed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := buildTx(t, "promise", pub, tt.flags)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %v, want error mentioning %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, body.(codec.Compound))
+		})
+	}
+}
+
+func TestBuildFulfill(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   map[string][]string
+		want    int64
+		wantErr bool
+	}{
+		{"whole", map[string][]string{"commitment": {"commitment:1"}}, 0, false},
+		{"part", map[string][]string{"commitment": {"commitment:1"}, "quantity": {"5"}}, 5, false},
+		{"negative", map[string][]string{"commitment": {"commitment:1"}, "quantity": {"-1"}}, 0, true},
+		{"no commitment", map[string][]string{}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := buildTx(t, "fulfill", nil, tt.flags)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			f := body.(*codec.Fulfillment)
+			if f.CommitmentID != "commitment:1" || f.Quantity != tt.want || !strings.HasPrefix(f.ID, "fulfillment:") {
+				t.Errorf("fulfillment %+v", f)
+			}
+		})
+	}
+}
+
+func TestBuildRegister(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := buildTx(t, "register-commiter", pub, nil); err == nil {
+		t.Error("register-commiter without --name accepted")
+	}
+	if _, err := buildTx(t, "register-beneficiary", nil, map[string][]string{"name": {"B"}, "id": {"beneficiary:1"}}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,243 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gregorybednov/lbc/canonical"
+	"github.com/gregorybednov/lbc/pow"
+
+	"github.com/spf13/cobra"
+)
+
+const txFileVersion = 2
+
+// txFile — формат файла транзакции для офлайн-подписи. Подписывается
+// canonical.SignBytes(chain_id, type, body), поэтому форматирование body в
+// файле на подпись не влияет.
+type txFile struct {
+	Version    int             `json:"version"`
+	ChainID    string          `json:"chain_id"`
+	Type       string          `json:"type"`
+	Signer     string          `json:"signer,omitempty"` // base64 pubkey обязательного подписанта
+	Body       json.RawMessage `json:"body"`
+	Signatures []txSignature   `json:"signatures"`
+}
+
+type txSignature struct {
+	PubKey    string `json:"pub_key"`
+	Signature string `json:"signature"`
+}
+
+func newTxFile(chainID, kind string, body any, signer ed25519.PublicKey) (*txFile, error) {
+	raw, err := canonical.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	f := &txFile{
+		Version:    txFileVersion,
+		ChainID:    chainID,
+		Type:       kind,
+		Body:       raw,
+		Signatures: []txSignature{},
+	}
+	if signer != nil {
+		f.Signer = base64.StdEncoding.EncodeToString(signer)
+	}
+	return f, nil
+}
+
+func readTxFile(path string) (*txFile, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f txFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: invalid tx file: %w", path, err)
+	}
+	if f.Version != txFileVersion {
+		return nil, fmt.Errorf("%s: unsupported tx file version %d", path, f.Version)
+	}
+	if err := f.verify(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &f, nil
+}
+
+func (f *txFile) write(cmd *cobra.Command, path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = cmd.OutOrStdout().Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// sign добавляет (или заменяет) подпись владельца priv.
+func (f *txFile) sign(priv ed25519.PrivateKey) error {
+	sig, err := canonical.Sign(priv, f.ChainID, f.Type, f.Body)
+	if err != nil {
+		return err
+	}
+	f.addSignature(txSignature{
+		PubKey:    base64.StdEncoding.EncodeToString(priv.Public().(ed25519.PublicKey)),
+		Signature: sig,
+	})
+	return nil
+}
+
+func (f *txFile) addSignature(s txSignature) {
+	for i := range f.Signatures {
+		if f.Signatures[i].PubKey == s.PubKey {
+			f.Signatures[i] = s
+			return
+		}
+	}
+	f.Signatures = append(f.Signatures, s)
+}
+
+// verify проверяет все подписи в файле над body.
+func (f *txFile) verify() error {
+	for _, s := range f.Signatures {
+		pub, err := base64.StdEncoding.DecodeString(s.PubKey)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			return fmt.Errorf("invalid pub_key %q", s.PubKey)
+		}
+		if err := canonical.Verify(pub, s.Signature, f.ChainID, f.Type, f.Body); err != nil {
+			return fmt.Errorf("signature of %s: %w", s.PubKey, err)
+		}
+	}
+	return nil
+}
+
+// envelope собирает транзакцию в том виде, который принимает узел: подпись
+// обязательного подписанта в signature, остальные — в cosignatures.
+func (f *txFile) envelope() ([]byte, error) {
+	env := struct {
+		Body         json.RawMessage `json:"body"`
+		Signature    string          `json:"signature"`
+		Cosignatures []txSignature   `json:"cosignatures,omitempty"`
+		PowNonce     uint64          `json:"pow_nonce,omitempty"`
+	}{Body: f.Body}
+
+	for _, s := range f.Signatures {
+		if env.Signature == "" && (f.Signer == "" || s.PubKey == f.Signer) {
+			env.Signature = s.Signature
+			continue
+		}
+		env.Cosignatures = append(env.Cosignatures, s)
+	}
+	if f.Signer != "" && env.Signature == "" {
+		return nil, fmt.Errorf("нет подписи обязательного подписанта %s", f.Signer)
+	}
+	// Регистрации сопровождаются доказательством работы; тело подписано,
+	// поэтому nonce подбирается при сборке конверта.
+	if pow.Required(f.Type) {
+		nonce, err := pow.Solve(f.ChainID, f.Type, f.Body, pow.Difficulty)
+		if err != nil {
+			return nil, err
+		}
+		env.PowNonce = nonce
+	}
+	return json.Marshal(env)
+}
+
+func sameTx(a, b *txFile) bool {
+	if a.ChainID != b.ChainID || a.Type != b.Type || a.Signer != b.Signer {
+		return false
+	}
+	ca, errA := canonical.Canonicalize(a.Body)
+	cb, errB := canonical.Canonicalize(b.Body)
+	return errA == nil && errB == nil && bytes.Equal(ca, cb)
+}
+
+var txSignCmd = &cobra.Command{
+	Use:   "sign <file>",
+	Short: "Подписать файл транзакции ключом --from или --key",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, _ := cmd.Flags().GetString("out")
+		f, err := readTxFile(args[0])
+		if err != nil {
+			return err
+		}
+		priv, err := unlockKey(txFrom, txKeyFile)
+		if err != nil {
+			return err
+		}
+		if err := f.sign(priv); err != nil {
+			return err
+		}
+		return f.write(cmd, out)
+	},
+}
+
+var txMultisignCmd = &cobra.Command{
+	Use:   "multisign <file> <signed-file>...",
+	Short: "Объединить подписи из нескольких подписанных копий одной транзакции",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, _ := cmd.Flags().GetString("out")
+		f, err := readTxFile(args[0])
+		if err != nil {
+			return err
+		}
+		for _, path := range args[1:] {
+			other, err := readTxFile(path)
+			if err != nil {
+				return err
+			}
+			if !sameTx(f, other) {
+				return fmt.Errorf("%s: другая транзакция", path)
+			}
+			for _, s := range other.Signatures {
+				f.addSignature(s)
+			}
+		}
+		return f.write(cmd, out)
+	},
+}
+
+var txBroadcastCmd = &cobra.Command{
+	Use:   "broadcast <file>",
+	Short: "Отправить подписанный файл транзакции",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := readTxFile(args[0])
+		if err != nil {
+			return err
+		}
+		if len(f.Signatures) == 0 && f.Signer != "" {
+			return errors.New("транзакция не подписана")
+		}
+		tx, err := f.envelope()
+		if err != nil {
+			return err
+		}
+		return broadcastTx(cmd, tx)
+	},
+}
+
+func init() {
+	txSignCmd.Flags().StringP("out", "O", "-", "Куда записать результат (- для stdout)")
+	txMultisignCmd.Flags().StringP("out", "O", "-", "Куда записать результат (- для stdout)")
+
+	txCmd.AddCommand(txSignCmd, txMultisignCmd, txBroadcastCmd)
+}
@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gregorybednov/lbc/canonical"
+	"github.com/gregorybednov/lbc/codec"
+
+	"github.com/spf13/cobra"
+)
+
+func newKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv
+}
+
+// writeTxFile пишет f в каталог теста и возвращает путь.
+func writeTxFile(t *testing.T, f *txFile, name string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := f.write(&cobra.Command{}, path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestTxFileMultisign: файл собирается без подписи, подписывается двумя
+// ключами в отдельных копиях, подписи объединяются, и конверт несёт подпись
+// подписанта в signature, а вторую — в cosignatures.
+func TestTxFileMultisign(t *testing.T) {
+	signer, cosigner := newKey(t), newKey(t)
+	body := &codec.Fulfillment{Type: "fulfillment", ID: "fulfillment:1", CommitmentID: "commitment:1"}
+	f, err := newTxFile("test", "fulfillment", body, signer.Public().(ed25519.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := writeTxFile(t, f, "unsigned.json")
+	if _, err := f.envelope(); err == nil {
+		t.Fatal("envelope without the signer's signature")
+	}
+
+	var copies []*txFile
+	for _, priv := range []ed25519.PrivateKey{cosigner, signer} {
+		c, err := readTxFile(unsigned)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.sign(priv); err != nil {
+			t.Fatal(err)
+		}
+		c, err = readTxFile(writeTxFile(t, c, "signed.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		copies = append(copies, c)
+	}
+	merged := copies[0]
+	if !sameTx(merged, copies[1]) {
+		t.Fatal("copies of one transaction differ")
+	}
+	for _, s := range copies[1].Signatures {
+		merged.addSignature(s)
+	}
+	merged.addSignature(copies[1].Signatures[0]) // повтор не дублирует подпись
+	if len(merged.Signatures) != 2 {
+		t.Fatalf("%d signatures, want 2", len(merged.Signatures))
+	}
+
+	tx, err := merged.envelope()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var env struct {
+		Body         json.RawMessage `json:"body"`
+		Signature    string          `json:"signature"`
+		Cosignatures []txSignature   `json:"cosignatures"`
+	}
+	if err := json.Unmarshal(tx, &env); err != nil {
+		t.Fatal(err)
+	}
+	if err := canonical.Verify(signer.Public().(ed25519.PublicKey), env.Signature, "test", "fulfillment", env.Body); err != nil {
+		t.Errorf("signature: %v", err)
+	}
+	cosignerKey := base64.StdEncoding.EncodeToString(cosigner.Public().(ed25519.PublicKey))
+	if len(env.Cosignatures) != 1 || env.Cosignatures[0].PubKey != cosignerKey {
+		t.Errorf("cosignatures %+v, want one by %s", env.Cosignatures, cosignerKey)
+	}
+	if _, err := codec.FromJSON(tx); err != nil {
+		t.Errorf("envelope is not a valid tx: %v", err)
+	}
+}
+
+func TestReadTxFileRejects(t *testing.T) {
+	priv := newKey(t)
+	f, err := newTxFile("test", "fulfillment", map[string]any{"type": "fulfillment", "id": "fulfillment:1", "commitment_id": "commitment:1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.sign(priv); err != nil {
+		t.Fatal(err)
+	}
+	good, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"tampered body", strings.Replace(string(good), "commitment:1", "commitment:2", 1), "signature"},
+		{"other chain", strings.Replace(string(good), `"chain_id":"test"`, `"chain_id":"other"`, 1), "signature"},
+		{"bad pub key", strings.Replace(string(good), `"pub_key":"`, `"pub_key":"!`, 1), "pub_key"},
+		{"old version", strings.Replace(string(good), `"version":2`, `"version":1`, 1), "version"},
+		{"not JSON", "{", "invalid tx file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tx.json")
+			if err := os.WriteFile(path, []byte(tt.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := readTxFile(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSameTx(t *testing.T) {
+	a := &txFile{ChainID: "test", Type: "fulfillment", Body: json.RawMessage(`{"id":"f:1","type":"fulfillment"}`)}
+	tests := []struct {
+		name string
+		b    txFile
+		want bool
+	}{
+		{"reformatted body", txFile{ChainID: "test", Type: "fulfillment", Body: json.RawMessage("{\n  \"type\": \"fulfillment\",\n  \"id\": \"f:1\"\n}")}, true},
+		{"other body", txFile{ChainID: "test", Type: "fulfillment", Body: json.RawMessage(`{"id":"f:2","type":"fulfillment"}`)}, false},
+		{"other chain", txFile{ChainID: "other", Type: "fulfillment", Body: a.Body}, false},
+		{"other signer", txFile{ChainID: "test", Type: "fulfillment", Signer: "x", Body: a.Body}, false},
+	}
+	for _, tt := range tests {
+		if got := sameTx(a, &tt.b); got != tt.want {
+			t.Errorf("%s: sameTx = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gregorybednov/lbc/webhook"
+
+	"github.com/spf13/cobra"
+)
+
+var webhooksCmd = &cobra.Command{
+	Use:   "webhooks",
+	Short: "Исходящие вебхуки ноды",
+}
+
+var webhooksStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Очередь доставок: ожидающие, доставленные, неудачные",
+	Long: `Очередь доставок локальной ноды. Сводку пишет работающая нода в файл
+webhooks.json рядом с базой; через RPC она не отдаётся, потому что содержит
+адреса получателей и ошибки доставки.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		path := webhookStatusFile()
+		st, err := webhook.ReadStatusFile(path, all)
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("сводка %s не найдена: нода не запущена или вебхуки не настроены", path)
+		}
+		if err != nil {
+			return err
+		}
+		if queryOutput == "json" {
+			value, err := json.Marshal(st)
+			if err != nil {
+				return err
+			}
+			return printRecords(cmd.OutOrStdout(), value, "json")
+		}
+		if queryOutput != "table" {
+			return fmt.Errorf("неизвестный формат вывода %q (table|json)", queryOutput)
+		}
+
+		w := cmd.OutOrStdout()
+		fmt.Fprintf(w, "Блок: %d, ожидают: %d, доставлено: %d, не доставлено: %d\n",
+			st.Height, st.Pending, st.Delivered, st.Failed)
+		if len(st.Deliveries) == 0 {
+			return nil
+		}
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join([]string{"id", "event", "url", "status", "attempts", "next", "error"}, "\t")))
+		for _, d := range st.Deliveries {
+			next := "-"
+			if d.Status == webhook.StatusPending {
+				next = time.Unix(d.NextAttempt, 0).Format(time.DateTime)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", d.ID, d.Event, d.URL, d.Status, d.Attempts, next, d.LastError)
+		}
+		return tw.Flush()
+	},
+}
+
+func init() {
+	webhooksCmd.PersistentFlags().StringVarP(&queryOutput, "output", "o", "table", "Формат вывода: table или json")
+	webhooksStatusCmd.Flags().Bool("all", false, "Показать и доставленные")
+
+	webhooksCmd.AddCommand(webhooksStatusCmd)
+	rootCmd.AddCommand(webhooksCmd)
+}
@@ -0,0 +1,348 @@
+// Package codec — компактное бинарное (protobuf) кодирование транзакций и
+// записей lbc по схеме lbc.proto.
+//
+// Первый байт отличает кодирования: VersionProto (0x01) — protobuf, '{' —
+// прежний JSON. Подпись в обоих случаях считается над каноническим JSON тела
+// (docs/signing.md): узел переводит бинарную транзакцию в JSON-конверт и
+// проверяет её тем же кодом, поэтому одну и ту же подпись можно отправить в
+// любом кодировании.
+package codec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gregorybednov/lbc/canonical"
+	types "github.com/gregorybednov/lbc_sdk"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// VersionProto — первый байт транзакции или записи в кодировании lbc.proto.
+const VersionProto byte = 0x01
+
+// ErrNotRepresentable — тело нельзя перевести в protobuf без потерь (например,
+// транзакции валидаторов или поля вне схемы); такую транзакцию надо
+// отправлять в JSON.
+var ErrNotRepresentable = errors.New("codec: body is not representable in protobuf")
+
+// IsProto сообщает, закодированы ли данные по lbc.proto.
+func IsProto(b []byte) bool { return len(b) > 0 && b[0] == VersionProto }
+
+// Promise — обещание: поля SDK и расширения lbc, которых в SDK нет. Пустые
+// расширения в JSON не пишутся, поэтому канонический JSON обещаний без них
+// (и подписи над ним) не меняется.
+type Promise struct {
+	types.PromiseTxBody
+	// Attachments — хеши вложений вида sha256:<hex> (пакет blobstore).
+	Attachments []string `json:"attachments,omitempty"`
+	// Recurrence — правило повторения; nil — обещание разовое.
+	Recurrence *Recurrence `json:"recurrence,omitempty"`
+	// Quantity и Unit — измеримый объём обещания ("100 kg"); 0 — обещание
+	// не количественное.
+	Quantity int64  `json:"quantity,omitempty"`
+	Unit     string `json:"unit,omitempty"`
+}
+
+// Commitment — обязательство: поля SDK и расширения lbc.
+type Commitment struct {
+	types.CommitmentTxBody
+	// Quantity — доля коммитера в единицах Unit обещания; 0 — обязательство
+	// не количественное и выполняется одной записью Fulfillment.
+	Quantity int64  `json:"quantity,omitempty"`
+	Unit     string `json:"unit,omitempty"`
+	// Cosigners — base64-ключи, чьи дополнительные подписи (cosignatures
+	// конверта) узел проверил при записи обязательства. В теле транзакции
+	// не задаётся.
+	Cosigners []string `json:"cosigners,omitempty"`
+}
+
+// Fulfillment — отметка коммитера о выполнении (частичном, если у
+// обязательства есть Quantity) обязательства CommitmentID.
+type Fulfillment struct {
+	Type         string `json:"type"`
+	ID           string `json:"id"`
+	CommitmentID string `json:"commitment_id"`
+	Quantity     int64  `json:"quantity,omitempty"`
+}
+
+// Recurrence — повторяющееся обещание: обязательство из транзакции — первый
+// период, следующие узел создаёт сам со сроком на Period секунд позже
+// предыдущего. Until — последний допустимый срок (0 — без конца).
+type Recurrence struct {
+	Period int64 `json:"period"`
+	Until  int64 `json:"until,omitempty"`
+}
+
+// Compound — тело композита promise+commitment.
+type Compound struct {
+	Promise    *Promise    `json:"promise"`
+	Commitment *Commitment `json:"commitment"`
+}
+
+type Cosignature struct {
+	PubKey    []byte
+	Signature []byte
+}
+
+// Envelope — транзакция: Body — *types.CommiterTxBody,
+// *types.BeneficiaryTxBody, *Compound или *Fulfillment. PowNonce — доказательство работы
+// (пакет pow) для регистраций.
+type Envelope struct {
+	Body         any
+	Signature    []byte
+	Cosignatures []Cosignature
+	PowNonce     uint64
+}
+
+// EncodeTx кодирует транзакцию как VersionProto || Envelope.
+func EncodeTx(env *Envelope) ([]byte, error) {
+	b := []byte{VersionProto}
+	switch body := env.Body.(type) {
+	case *types.CommiterTxBody:
+		m, err := marshalCommiter(body)
+		if err != nil {
+			return nil, err
+		}
+		b = appendMessage(b, 1, m)
+	case *types.BeneficiaryTxBody:
+		b = appendMessage(b, 2, marshalBeneficiary(body))
+	case *Compound:
+		b = appendMessage(b, 3, marshalCompound(body))
+	case *Fulfillment:
+		b = appendMessage(b, 4, marshalFulfillment(body))
+	default:
+		return nil, fmt.Errorf("%w: unsupported body %T", ErrNotRepresentable, env.Body)
+	}
+	b = appendBytes(b, 10, env.Signature)
+	for _, s := range env.Cosignatures {
+		b = appendMessage(b, 11, marshalCosignature(s))
+	}
+	b = appendUint64(b, 12, env.PowNonce)
+	return b, nil
+}
+
+// DecodeTx разбирает транзакцию, закодированную EncodeTx.
+func DecodeTx(tx []byte) (*Envelope, error) {
+	if !IsProto(tx) {
+		return nil, errors.New("codec: not a protobuf transaction")
+	}
+	env := &Envelope{}
+	err := fields(tx[1:], func(f field) (err error) {
+		switch f.num {
+		case 1, 2, 3, 4:
+			if env.Body != nil {
+				return errors.New("codec: more than one body")
+			}
+			if err := f.want(protowire.BytesType); err != nil {
+				return err
+			}
+			switch f.num {
+			case 1:
+				env.Body, err = unmarshalCommiter(f.b)
+			case 2:
+				env.Body, err = unmarshalBeneficiary(f.b)
+			case 3:
+				env.Body, err = unmarshalCompound(f.b)
+			case 4:
+				env.Body, err = unmarshalFulfillment(f.b)
+			}
+		case 10:
+			env.Signature, err = f.bytes()
+		case 11:
+			if err := f.want(protowire.BytesType); err != nil {
+				return err
+			}
+			var s Cosignature
+			if s, err = unmarshalCosignature(f.b); err == nil {
+				env.Cosignatures = append(env.Cosignatures, s)
+			}
+		case 12:
+			env.PowNonce, err = f.uint64()
+		default:
+			err = f.unknown()
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if env.Body == nil {
+		return nil, errors.New("codec: missing body")
+	}
+	return env, nil
+}
+
+type jsonCosignature struct {
+	PubKey    string `json:"pub_key"`
+	Signature string `json:"signature"`
+}
+
+type jsonEnvelope struct {
+	Body         json.RawMessage   `json:"body"`
+	Signature    string            `json:"signature"`
+	Cosignatures []jsonCosignature `json:"cosignatures,omitempty"`
+	PowNonce     uint64            `json:"pow_nonce,omitempty"`
+}
+
+// JSON возвращает тот же конверт в JSON-форме, которую проверяет узел.
+func (env *Envelope) JSON() ([]byte, error) {
+	body, err := json.Marshal(env.Body)
+	if err != nil {
+		return nil, err
+	}
+	out := jsonEnvelope{
+		Body:      body,
+		Signature: base64.StdEncoding.EncodeToString(env.Signature),
+		PowNonce:  env.PowNonce,
+	}
+	for _, s := range env.Cosignatures {
+		out.Cosignatures = append(out.Cosignatures, jsonCosignature{
+			PubKey:    base64.StdEncoding.EncodeToString(s.PubKey),
+			Signature: base64.StdEncoding.EncodeToString(s.Signature),
+		})
+	}
+	return json.Marshal(out)
+}
+
+// FromJSON переводит JSON-конверт в Envelope. Перевод допустим, только если
+// тело восстанавливается из Envelope в тот же канонический JSON — иначе
+// подпись перестала бы сходиться.
+func FromJSON(tx []byte) (*Envelope, error) {
+	var in jsonEnvelope
+	if err := json.Unmarshal(tx, &in); err != nil {
+		return nil, fmt.Errorf("codec: invalid tx JSON: %w", err)
+	}
+	var tiny struct {
+		Type       string          `json:"type"`
+		Commitment json.RawMessage `json:"commitment"`
+	}
+	if err := json.Unmarshal(in.Body, &tiny); err != nil {
+		return nil, fmt.Errorf("codec: invalid body JSON: %w", err)
+	}
+	env := &Envelope{PowNonce: in.PowNonce}
+	switch {
+	case tiny.Type == "commiter":
+		env.Body = &types.CommiterTxBody{}
+	case tiny.Type == "beneficiary":
+		env.Body = &types.BeneficiaryTxBody{}
+	case tiny.Type == "fulfillment":
+		env.Body = &Fulfillment{}
+	case tiny.Type == "" && tiny.Commitment != nil:
+		env.Body = &Compound{}
+	default:
+		return nil, fmt.Errorf("%w: tx type %q", ErrNotRepresentable, tiny.Type)
+	}
+	if err := json.Unmarshal(in.Body, env.Body); err != nil {
+		return nil, fmt.Errorf("codec: invalid body JSON: %w", err)
+	}
+
+	want, err := canonical.Canonicalize(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	got, err := canonical.Marshal(env.Body)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(want, got) {
+		return nil, fmt.Errorf("%w: body has fields outside the schema", ErrNotRepresentable)
+	}
+
+	if env.Signature, err = base64.StdEncoding.DecodeString(in.Signature); err != nil {
+		return nil, fmt.Errorf("codec: signature: %w", err)
+	}
+	for _, s := range in.Cosignatures {
+		var cs Cosignature
+		if cs.PubKey, err = base64.StdEncoding.DecodeString(s.PubKey); err != nil {
+			return nil, fmt.Errorf("codec: cosignature pub_key: %w", err)
+		}
+		if cs.Signature, err = base64.StdEncoding.DecodeString(s.Signature); err != nil {
+			return nil, fmt.Errorf("codec: cosignature: %w", err)
+		}
+		env.Cosignatures = append(env.Cosignatures, cs)
+	}
+	return env, nil
+}
+
+// EncodeRecord кодирует запись реестра как VersionProto || Record. Поле type
+// не хранится: оно следует из вида записи.
+func EncodeRecord(v any) ([]byte, error) {
+	b := []byte{VersionProto}
+	switch r := v.(type) {
+	case *types.CommiterTxBody:
+		m, err := marshalCommiter(r)
+		if err != nil {
+			return nil, err
+		}
+		return appendMessage(b, 1, m), nil
+	case *types.BeneficiaryTxBody:
+		return appendMessage(b, 2, marshalBeneficiary(r)), nil
+	case *Promise:
+		return appendMessage(b, 3, marshalPromise(r)), nil
+	case *types.PromiseTxBody:
+		return appendMessage(b, 3, marshalPromise(&Promise{PromiseTxBody: *r})), nil
+	case *Commitment:
+		return appendMessage(b, 4, marshalCommitment(r)), nil
+	case *types.CommitmentTxBody:
+		return appendMessage(b, 4, marshalCommitment(&Commitment{CommitmentTxBody: *r})), nil
+	case *Fulfillment:
+		return appendMessage(b, 5, marshalFulfillment(r)), nil
+	}
+	return nil, fmt.Errorf("%w: unsupported record %T", ErrNotRepresentable, v)
+}
+
+// DecodeRecord разбирает запись, закодированную EncodeRecord.
+func DecodeRecord(b []byte) (any, error) {
+	if !IsProto(b) {
+		return nil, errors.New("codec: not a protobuf record")
+	}
+	var rec any
+	err := fields(b[1:], func(f field) (err error) {
+		if rec != nil {
+			return errors.New("codec: more than one record")
+		}
+		if err := f.want(protowire.BytesType); err != nil {
+			return err
+		}
+		switch f.num {
+		case 1:
+			rec, err = unmarshalCommiter(f.b)
+		case 2:
+			rec, err = unmarshalBeneficiary(f.b)
+		case 3:
+			rec, err = unmarshalPromise(f.b)
+		case 4:
+			rec, err = unmarshalCommitment(f.b)
+		case 5:
+			rec, err = unmarshalFulfillment(f.b)
+		default:
+			err = f.unknown()
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, errors.New("codec: empty record")
+	}
+	return rec, nil
+}
+
+// RecordJSON возвращает запись в JSON независимо от того, как она хранится:
+// бинарная декодируется, JSON (записи до перехода на protobuf и служебные
+// записи) отдаётся как есть.
+func RecordJSON(b []byte) ([]byte, error) {
+	if !IsProto(b) {
+		return b, nil
+	}
+	rec, err := DecodeRecord(b)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(rec)
+}
@@ -0,0 +1,151 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gregorybednov/lbc/canonical"
+	types "github.com/gregorybednov/lbc_sdk"
+)
+
+const testPubKey = "11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo="
+
+// TestTxRoundTrip: JSON-конверт → Envelope → protobuf → Envelope → JSON
+// сохраняет канонический JSON тела, подпись, косигнатуры и nonce.
+func TestTxRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"commiter", `{"type":"commiter","id":"commiter:` + testPubKey + `","name":"A","commiter_pubkey":"` + testPubKey + `"}`},
+		{"beneficiary", `{"type":"beneficiary","id":"beneficiary:1","name":"Б"}`},
+		{"compound", `{"promise":{"type":"promise","id":"promise:1","text":"t","due":4000000000,"beneficiary_id":"beneficiary:1","parent_promise_id":null},` +
+			`"commitment":{"type":"commitment","id":"commitment:1","promise_id":"promise:1","commiter_id":"commiter:x","due":4000000000}}`},
+		{"compound with extensions", `{"promise":{"type":"promise","id":"promise:2","text":"t","due":4000000000,"beneficiary_id":"beneficiary:1","parent_promise_id":"promise:1",` +
+			`"attachments":["sha256:00"],"recurrence":{"period":604800,"until":4100000000},"quantity":100,"unit":"kg"},` +
+			`"commitment":{"type":"commitment","id":"commitment:2","promise_id":"promise:2","commiter_id":"commiter:x","due":4000000000,"quantity":40,"unit":"kg"}}`},
+		{"fulfillment", `{"type":"fulfillment","id":"fulfillment:1","commitment_id":"commitment:1"}`},
+		{"partial fulfillment", `{"type":"fulfillment","id":"fulfillment:2","commitment_id":"commitment:2","quantity":15}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := []byte(`{"body":` + tt.body + `,"signature":"c2ln","cosignatures":[{"pub_key":"cGs=","signature":"Y28="}],"pow_nonce":42}`)
+			env, err := FromJSON(tx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bin, err := EncodeTx(env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !IsProto(bin) {
+				t.Fatal("EncodeTx result is not protobuf")
+			}
+			dec, err := DecodeTx(bin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(dec, env) {
+				t.Errorf("decoded %+v, want %+v", dec, env)
+			}
+			out, err := dec.JSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got struct {
+				Body json.RawMessage `json:"body"`
+			}
+			if err := json.Unmarshal(out, &got); err != nil {
+				t.Fatal(err)
+			}
+			want, _ := canonical.Canonicalize([]byte(tt.body))
+			if body, _ := canonical.Canonicalize(got.Body); !bytes.Equal(body, want) {
+				t.Errorf("body %s, want %s", body, want)
+			}
+			if string(dec.Signature) != "sig" || dec.PowNonce != 42 || len(dec.Cosignatures) != 1 {
+				t.Errorf("envelope %+v", dec)
+			}
+		})
+	}
+}
+
+func TestFromJSONRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		tx      string
+		notRepr bool // ErrNotRepresentable: такую транзакцию шлют в JSON
+	}{
+		{"invalid JSON", `{"body":`, false},
+		{"validator tx", `{"body":{"type":"add_validator","pub_key":"x","power":1},"signature":""}`, true},
+		{"unknown type", `{"body":{"type":"promise","id":"promise:1"},"signature":""}`, true},
+		{"field outside schema", `{"body":{"type":"beneficiary","id":"beneficiary:1","name":"B","extra":1},"signature":""}`, true},
+		{"non-canonical pubkey", `{"body":{"type":"commiter","id":"commiter:x","name":"A","commiter_pubkey":"not base64"},"signature":""}`, true},
+		{"bad signature", `{"body":{"type":"beneficiary","id":"beneficiary:1","name":"B"},"signature":"!!"}`, false},
+		{"bad cosignature", `{"body":{"type":"beneficiary","id":"beneficiary:1","name":"B"},"signature":"","cosignatures":[{"pub_key":"!!","signature":""}]}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, err := FromJSON([]byte(tt.tx))
+			if err == nil {
+				// Ключ проверяется при кодировании, а не при разборе.
+				_, err = EncodeTx(env)
+			}
+			if err == nil {
+				t.Fatal("accepted")
+			}
+			if errors.Is(err, ErrNotRepresentable) != tt.notRepr {
+				t.Errorf("err %v: ErrNotRepresentable = %v, want %v", err, !tt.notRepr, tt.notRepr)
+			}
+		})
+	}
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	parent := "promise:0"
+	tests := []struct {
+		name string
+		rec  any
+	}{
+		{"commiter", &types.CommiterTxBody{Type: "commiter", ID: "commiter:" + testPubKey, Name: "A", CommiterPubKey: testPubKey}},
+		{"beneficiary", &types.BeneficiaryTxBody{Type: "beneficiary", ID: "beneficiary:1", Name: "B"}},
+		{"promise", &Promise{
+			PromiseTxBody: types.PromiseTxBody{Type: "promise", ID: "promise:1", Text: "t", Due: 4000000000, BeneficiaryID: "beneficiary:1", ParentPromiseID: &parent},
+			Attachments:   []string{"sha256:00"}, Recurrence: &Recurrence{Period: 60}, Quantity: 10, Unit: "kg",
+		}},
+		{"commitment", &Commitment{
+			CommitmentTxBody: types.CommitmentTxBody{Type: "commitment", ID: "commitment:1", PromiseID: "promise:1", CommiterID: "commiter:x", Due: 4000000000},
+			Quantity:         5, Unit: "kg", Cosigners: []string{testPubKey},
+		}},
+		{"fulfillment", &Fulfillment{Type: "fulfillment", ID: "fulfillment:1", CommitmentID: "commitment:1", Quantity: 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := EncodeRecord(tt.rec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := DecodeRecord(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.rec) {
+				t.Errorf("decoded %+v, want %+v", got, tt.rec)
+			}
+			js, err := RecordJSON(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := json.Marshal(tt.rec)
+			if !bytes.Equal(js, want) {
+				t.Errorf("RecordJSON %s, want %s", js, want)
+			}
+		})
+	}
+	legacy := []byte(`{"type":"beneficiary","id":"beneficiary:1","name":"B"}`)
+	if js, err := RecordJSON(legacy); err != nil || !bytes.Equal(js, legacy) {
+		t.Errorf("RecordJSON of a JSON record: %s, %v", js, err)
+	}
+}
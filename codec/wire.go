@@ -0,0 +1,402 @@
+package codec
+
+import (
+	"encoding/base64"
+	"fmt"
+	"unicode/utf8"
+
+	types "github.com/gregorybednov/lbc_sdk"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Кодирование сообщений lbc.proto. Поля со значением по умолчанию не пишутся
+// (как в proto3), кроме optional parent_promise_id и вложенных сообщений.
+// Неизвестные поля при разборе — ошибка: в подписанной транзакции их нечем
+// проверить, а в записи они означают более новую версию схемы.
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func appendInt64(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func appendUint64(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendMessage(b []byte, num protowire.Number, m []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+type field struct {
+	num protowire.Number
+	typ protowire.Type
+	v   uint64
+	b   []byte
+}
+
+// fields разбирает сообщение и вызывает fn для каждого поля по порядку.
+func fields(b []byte, fn func(f field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("codec: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+		f := field{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.v, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			f.b, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return fmt.Errorf("codec: field %d: %w", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f field) unknown() error {
+	return fmt.Errorf("codec: unknown field %d", f.num)
+}
+
+func (f field) want(typ protowire.Type) error {
+	if f.typ != typ {
+		return fmt.Errorf("codec: field %d: wrong wire type %d", f.num, f.typ)
+	}
+	return nil
+}
+
+func (f field) str() (string, error) {
+	if err := f.want(protowire.BytesType); err != nil {
+		return "", err
+	}
+	if !utf8.Valid(f.b) {
+		return "", fmt.Errorf("codec: field %d: invalid UTF-8", f.num)
+	}
+	return string(f.b), nil
+}
+
+func (f field) bytes() ([]byte, error) {
+	if err := f.want(protowire.BytesType); err != nil {
+		return nil, err
+	}
+	return append([]byte{}, f.b...), nil
+}
+
+func (f field) int64() (int64, error) {
+	if err := f.want(protowire.VarintType); err != nil {
+		return 0, err
+	}
+	return int64(f.v), nil
+}
+
+func (f field) uint64() (uint64, error) {
+	if err := f.want(protowire.VarintType); err != nil {
+		return 0, err
+	}
+	return f.v, nil
+}
+
+// pubKeyBytes переводит base64-ключ в байты; ключ, который не восстановится
+// той же строкой, в бинарную форму не переводится.
+func pubKeyBytes(b64 string) ([]byte, error) {
+	pk, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil || base64.StdEncoding.EncodeToString(pk) != b64 {
+		return nil, fmt.Errorf("%w: commiter_pubkey is not canonical base64", ErrNotRepresentable)
+	}
+	return pk, nil
+}
+
+func marshalCommiter(c *types.CommiterTxBody) ([]byte, error) {
+	pk, err := pubKeyBytes(c.CommiterPubKey)
+	if err != nil {
+		return nil, err
+	}
+	var b []byte
+	b = appendString(b, 1, c.ID)
+	b = appendString(b, 2, c.Name)
+	b = appendBytes(b, 3, pk)
+	return b, nil
+}
+
+func unmarshalCommiter(b []byte) (*types.CommiterTxBody, error) {
+	c := &types.CommiterTxBody{Type: "commiter"}
+	err := fields(b, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			c.ID, err = f.str()
+		case 2:
+			c.Name, err = f.str()
+		case 3:
+			var pk []byte
+			if pk, err = f.bytes(); err == nil {
+				c.CommiterPubKey = base64.StdEncoding.EncodeToString(pk)
+			}
+		default:
+			err = f.unknown()
+		}
+		return err
+	})
+	return c, err
+}
+
+func marshalBeneficiary(v *types.BeneficiaryTxBody) []byte {
+	var b []byte
+	b = appendString(b, 1, v.ID)
+	b = appendString(b, 2, v.Name)
+	return b
+}
+
+func unmarshalBeneficiary(b []byte) (*types.BeneficiaryTxBody, error) {
+	v := &types.BeneficiaryTxBody{Type: "beneficiary"}
+	err := fields(b, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			v.ID, err = f.str()
+		case 2:
+			v.Name, err = f.str()
+		default:
+			err = f.unknown()
+		}
+		return err
+	})
+	return v, err
+}
+
+func marshalPromise(p *Promise) []byte {
+	var b []byte
+	b = appendString(b, 1, p.ID)
+	b = appendString(b, 2, p.Text)
+	b = appendInt64(b, 3, p.Due)
+	b = appendString(b, 4, p.BeneficiaryID)
+	if p.ParentPromiseID != nil {
+		b = protowire.AppendTag(b, 5, protowire.BytesType)
+		b = protowire.AppendString(b, *p.ParentPromiseID)
+	}
+	for _, a := range p.Attachments {
+		b = protowire.AppendTag(b, 6, protowire.BytesType)
+		b = protowire.AppendString(b, a)
+	}
+	if r := p.Recurrence; r != nil {
+		b = appendMessage(b, 7, marshalRecurrence(r))
+	}
+	b = appendInt64(b, 8, p.Quantity)
+	b = appendString(b, 9, p.Unit)
+	return b
+}
+
+func marshalRecurrence(r *Recurrence) []byte {
+	var b []byte
+	b = appendInt64(b, 1, r.Period)
+	b = appendInt64(b, 2, r.Until)
+	return b
+}
+
+func unmarshalRecurrence(b []byte) (*Recurrence, error) {
+	r := &Recurrence{}
+	err := fields(b, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			r.Period, err = f.int64()
+		case 2:
+			r.Until, err = f.int64()
+		default:
+			err = f.unknown()
+		}
+		return err
+	})
+	return r, err
+}
+
+func unmarshalPromise(b []byte) (*Promise, error) {
+	p := &Promise{PromiseTxBody: types.PromiseTxBody{Type: "promise"}}
+	err := fields(b, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			p.ID, err = f.str()
+		case 2:
+			p.Text, err = f.str()
+		case 3:
+			p.Due, err = f.int64()
+		case 4:
+			p.BeneficiaryID, err = f.str()
+		case 5:
+			var parent string
+			if parent, err = f.str(); err == nil {
+				p.ParentPromiseID = &parent
+			}
+		case 6:
+			var a string
+			if a, err = f.str(); err == nil {
+				p.Attachments = append(p.Attachments, a)
+			}
+		case 7:
+			if err = f.want(protowire.BytesType); err == nil {
+				p.Recurrence, err = unmarshalRecurrence(f.b)
+			}
+		case 8:
+			p.Quantity, err = f.int64()
+		case 9:
+			p.Unit, err = f.str()
+		default:
+			err = f.unknown()
+		}
+		return err
+	})
+	return p, err
+}
+
+func marshalCommitment(c *Commitment) []byte {
+	var b []byte
+	b = appendString(b, 1, c.ID)
+	b = appendString(b, 2, c.PromiseID)
+	b = appendString(b, 3, c.CommiterID)
+	b = appendInt64(b, 4, c.Due)
+	b = appendInt64(b, 5, c.Quantity)
+	b = appendString(b, 6, c.Unit)
+	for _, s := range c.Cosigners {
+		b = protowire.AppendTag(b, 7, protowire.BytesType)
+		b = protowire.AppendString(b, s)
+	}
+	return b
+}
+
+func unmarshalCommitment(b []byte) (*Commitment, error) {
+	c := &Commitment{CommitmentTxBody: types.CommitmentTxBody{Type: "commitment"}}
+	err := fields(b, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			c.ID, err = f.str()
+		case 2:
+			c.PromiseID, err = f.str()
+		case 3:
+			c.CommiterID, err = f.str()
+		case 4:
+			c.Due, err = f.int64()
+		case 5:
+			c.Quantity, err = f.int64()
+		case 6:
+			c.Unit, err = f.str()
+		case 7:
+			var s string
+			if s, err = f.str(); err == nil {
+				c.Cosigners = append(c.Cosigners, s)
+			}
+		default:
+			err = f.unknown()
+		}
+		return err
+	})
+	return c, err
+}
+
+func marshalFulfillment(f *Fulfillment) []byte {
+	var b []byte
+	b = appendString(b, 1, f.ID)
+	b = appendString(b, 2, f.CommitmentID)
+	b = appendInt64(b, 3, f.Quantity)
+	return b
+}
+
+func unmarshalFulfillment(b []byte) (*Fulfillment, error) {
+	ff := &Fulfillment{Type: "fulfillment"}
+	err := fields(b, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			ff.ID, err = f.str()
+		case 2:
+			ff.CommitmentID, err = f.str()
+		case 3:
+			ff.Quantity, err = f.int64()
+		default:
+			err = f.unknown()
+		}
+		return err
+	})
+	return ff, err
+}
+
+func marshalCompound(c *Compound) []byte {
+	var b []byte
+	if c.Promise != nil {
+		b = appendMessage(b, 1, marshalPromise(c.Promise))
+	}
+	if c.Commitment != nil {
+		b = appendMessage(b, 2, marshalCommitment(c.Commitment))
+	}
+	return b
+}
+
+func unmarshalCompound(b []byte) (*Compound, error) {
+	c := &Compound{}
+	err := fields(b, func(f field) (err error) {
+		if err := f.want(protowire.BytesType); err != nil {
+			return err
+		}
+		switch f.num {
+		case 1:
+			c.Promise, err = unmarshalPromise(f.b)
+		case 2:
+			c.Commitment, err = unmarshalCommitment(f.b)
+		default:
+			err = f.unknown()
+		}
+		return err
+	})
+	return c, err
+}
+
+func marshalCosignature(s Cosignature) []byte {
+	var b []byte
+	b = appendBytes(b, 1, s.PubKey)
+	b = appendBytes(b, 2, s.Signature)
+	return b
+}
+
+func unmarshalCosignature(b []byte) (Cosignature, error) {
+	var s Cosignature
+	err := fields(b, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			s.PubKey, err = f.bytes()
+		case 2:
+			s.Signature, err = f.bytes()
+		default:
+			err = f.unknown()
+		}
+		return err
+	})
+	return s, err
+}
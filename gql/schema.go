@@ -0,0 +1,515 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/gregorybednov/lbc/blockchain"
+	"github.com/gregorybednov/lbc/codec"
+	types "github.com/gregorybednov/lbc_sdk"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// snapshotKey — ключ контекста со снимком, открытым на весь запрос.
+type snapshotKey struct{}
+
+type resolver struct {
+	app *blockchain.PromiseApp
+}
+
+// read выполняет fn над снимком запроса; вне запроса (поля событий
+// подписки) открывает отдельный снимок.
+func (r *resolver) read(ctx context.Context, fn func(s *blockchain.Snapshot) (any, error)) (any, error) {
+	if s, ok := ctx.Value(snapshotKey{}).(*blockchain.Snapshot); ok {
+		return fn(s)
+	}
+	var out any
+	err := r.app.View(func(s *blockchain.Snapshot) error {
+		var err error
+		out, err = fn(s)
+		return err
+	})
+	return out, err
+}
+
+// fullID допускает как полный ID (promise:<uuid>), так и часть после префикса.
+func fullID(kind, id string) string {
+	if strings.HasPrefix(id, kind+":") {
+		return id
+	}
+	return kind + ":" + id
+}
+
+func decode[T any](raw json.RawMessage) (*T, error) {
+	var v T
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// getOne читает запись вида kind; отсутствующая запись — null.
+func getOne[T any](s *blockchain.Snapshot, kind, id string) (any, error) {
+	raw, ok, err := s.Get(fullID(kind, id))
+	if err != nil || !ok {
+		return nil, err
+	}
+	return decode[T](raw)
+}
+
+// getAll читает все записи вида kind, отобранные match (nil — все).
+func getAll[T any](s *blockchain.Snapshot, kind string, match func(*T) bool) (any, error) {
+	result := []*T{}
+	err := s.Scan(kind+":", func(raw json.RawMessage) error {
+		v, err := decode[T](raw)
+		if err != nil {
+			return err
+		}
+		if match == nil || match(v) {
+			result = append(result, v)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// field — резолвер поля записи типа T.
+func field[T any](get func(v *T) any) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		v, ok := p.Source.(*T)
+		if !ok {
+			return nil, nil
+		}
+		return get(v), nil
+	}
+}
+
+func strArg(p graphql.ResolveParams, name string) string {
+	s, _ := p.Args[name].(string)
+	return s
+}
+
+var timestamp = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "Timestamp",
+	Description: "Unix-время в секундах (int64; Int в GraphQL 32-битный).",
+	Serialize: func(v any) any {
+		if n, ok := v.(int64); ok {
+			return n
+		}
+		return nil
+	},
+	ParseValue: func(v any) any {
+		switch n := v.(type) {
+		case int:
+			return int64(n)
+		case int64:
+			return n
+		case float64:
+			return int64(n)
+		}
+		return nil
+	},
+	ParseLiteral: func(v ast.Value) any {
+		if iv, ok := v.(*ast.IntValue); ok {
+			if n, err := strconv.ParseInt(iv.Value, 10, 64); err == nil {
+				return n
+			}
+		}
+		return nil
+	},
+})
+
+func nonNullList(t graphql.Type) graphql.Output {
+	return graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(t)))
+}
+
+var idArgs = graphql.FieldConfigArgument{
+	"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+}
+
+// newSchema строит схему по docs/database_schema.md: Commiter, Beneficiary,
+// Promise, Commitment, Fulfillment и связи между ними.
+func newSchema(r *resolver) (graphql.Schema, error) {
+	var commiterType, beneficiaryType, promiseType, commitmentType, fulfillmentType *graphql.Object
+
+	commitmentsWhere := func(match func(c *codec.Commitment) bool) graphql.FieldResolveFn {
+		return func(p graphql.ResolveParams) (any, error) {
+			return r.read(p.Context, func(s *blockchain.Snapshot) (any, error) {
+				return getAll(s, "commitment", match)
+			})
+		}
+	}
+	fulfillmentsWhere := func(match func(f *codec.Fulfillment) bool) graphql.FieldResolveFn {
+		return func(p graphql.ResolveParams) (any, error) {
+			return r.read(p.Context, func(s *blockchain.Snapshot) (any, error) {
+				return getAll(s, "fulfillment", match)
+			})
+		}
+	}
+	progress := func(id string) func(p graphql.ResolveParams) (any, error) {
+		return func(p graphql.ResolveParams) (any, error) {
+			return r.read(p.Context, func(s *blockchain.Snapshot) (any, error) {
+				pr, _, err := s.Progress(id)
+				return pr, err
+			})
+		}
+	}
+	reputation := func(p graphql.ResolveParams) (any, error) {
+		id := p.Source.(*types.CommiterTxBody).ID
+		return r.read(p.Context, func(s *blockchain.Snapshot) (any, error) {
+			rep, _, err := s.Reputation(id)
+			return rep, err
+		})
+	}
+	promisesWhere := func(match func(v *codec.Promise) bool) graphql.FieldResolveFn {
+		return func(p graphql.ResolveParams) (any, error) {
+			return r.read(p.Context, func(s *blockchain.Snapshot) (any, error) {
+				return getAll(s, "promise", match)
+			})
+		}
+	}
+	ref := func(kind string, getID func(p graphql.ResolveParams) string, get func(*blockchain.Snapshot, string, string) (any, error)) graphql.FieldResolveFn {
+		return func(p graphql.ResolveParams) (any, error) {
+			id := getID(p)
+			if id == "" {
+				return nil, nil
+			}
+			return r.read(p.Context, func(s *blockchain.Snapshot) (any, error) {
+				return get(s, kind, id)
+			})
+		}
+	}
+
+	reputationType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Reputation",
+		Description: "Исполнительность коммитера на время последнего блока; каждый период повторяющегося обещания учитывается отдельно.",
+		Fields: graphql.Fields{
+			"asOf":              {Type: timestamp, Resolve: field(func(v *blockchain.Reputation) any { return v.AsOf })},
+			"commitments":       {Type: graphql.NewNonNull(graphql.Int), Resolve: field(func(v *blockchain.Reputation) any { return v.Commitments })},
+			"fulfilled":         {Type: graphql.NewNonNull(graphql.Int), Resolve: field(func(v *blockchain.Reputation) any { return v.Fulfilled })},
+			"missed":            {Type: graphql.NewNonNull(graphql.Int), Resolve: field(func(v *blockchain.Reputation) any { return v.Missed })},
+			"pending":           {Type: graphql.NewNonNull(graphql.Int), Resolve: field(func(v *blockchain.Reputation) any { return v.Pending })},
+			"missedPeriods":     {Type: graphql.NewNonNull(graphql.Int), Resolve: field(func(v *blockchain.Reputation) any { return v.MissedPeriods })},
+			"missedCommitments": {Type: nonNullList(graphql.ID), Resolve: field(func(v *blockchain.Reputation) any { return append([]string{}, v.MissedCommitments...) })},
+		},
+	})
+
+	commiterType = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Commiter",
+		Description: "Участник, берущий на себя обязательства.",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":     {Type: graphql.NewNonNull(graphql.ID), Resolve: field(func(c *types.CommiterTxBody) any { return c.ID })},
+				"name":   {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(c *types.CommiterTxBody) any { return c.Name })},
+				"pubKey": {Type: graphql.NewNonNull(graphql.String), Description: "base64 ed25519", Resolve: field(func(c *types.CommiterTxBody) any { return c.CommiterPubKey })},
+				"commitments": {Type: nonNullList(commitmentType), Resolve: func(p graphql.ResolveParams) (any, error) {
+					id := p.Source.(*types.CommiterTxBody).ID
+					return commitmentsWhere(func(c *codec.Commitment) bool { return c.CommiterID == id })(p)
+				}},
+				"reputation": {Type: graphql.NewNonNull(reputationType), Resolve: reputation},
+			}
+		}),
+	})
+
+	beneficiaryType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Beneficiary",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":   {Type: graphql.NewNonNull(graphql.ID), Resolve: field(func(b *types.BeneficiaryTxBody) any { return b.ID })},
+				"name": {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(b *types.BeneficiaryTxBody) any { return b.Name })},
+				"promises": {Type: nonNullList(promiseType), Resolve: func(p graphql.ResolveParams) (any, error) {
+					id := p.Source.(*types.BeneficiaryTxBody).ID
+					return promisesWhere(func(v *codec.Promise) bool { return v.BeneficiaryID == id })(p)
+				}},
+			}
+		}),
+	})
+
+	recurrenceType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Recurrence",
+		Description: "Правило повторения: обязательства следующих периодов нода создаёт сама.",
+		Fields: graphql.Fields{
+			"period": {Type: graphql.NewNonNull(graphql.Int), Description: "секунды", Resolve: field(func(r *codec.Recurrence) any { return r.Period })},
+			"until":  {Type: timestamp, Resolve: field(func(r *codec.Recurrence) any { return r.Until })},
+		},
+	})
+
+	progressType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Progress",
+		Description: "Выполнение: у обещания — суммы по всем его обязательствам.",
+		Fields: graphql.Fields{
+			"quantity":     {Type: graphql.Int, Resolve: field(func(v *blockchain.Progress) any { return v.Quantity })},
+			"unit":         {Type: graphql.String, Resolve: field(func(v *blockchain.Progress) any { return v.Unit })},
+			"fulfilled":    {Type: graphql.NewNonNull(graphql.Int), Resolve: field(func(v *blockchain.Progress) any { return v.Fulfilled })},
+			"remaining":    {Type: graphql.NewNonNull(graphql.Int), Resolve: field(func(v *blockchain.Progress) any { return v.Remaining })},
+			"fulfillments": {Type: graphql.NewNonNull(graphql.Int), Resolve: field(func(v *blockchain.Progress) any { return v.Fulfillments })},
+			"complete":     {Type: graphql.NewNonNull(graphql.Boolean), Resolve: field(func(v *blockchain.Progress) any { return v.Complete })},
+		},
+	})
+
+	promiseType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Promise",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			parentID := func(v *codec.Promise) any {
+				if v.ParentPromiseID == nil {
+					return nil
+				}
+				return *v.ParentPromiseID
+			}
+			return graphql.Fields{
+				"id":            {Type: graphql.NewNonNull(graphql.ID), Resolve: field(func(v *codec.Promise) any { return v.ID })},
+				"text":          {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(v *codec.Promise) any { return v.Text })},
+				"due":           {Type: timestamp, Resolve: field(func(v *codec.Promise) any { return v.Due })},
+				"beneficiaryId": {Type: graphql.ID, Resolve: field(func(v *codec.Promise) any { return v.BeneficiaryID })},
+				"beneficiary": {Type: beneficiaryType, Resolve: ref("beneficiary", func(p graphql.ResolveParams) string {
+					return p.Source.(*codec.Promise).BeneficiaryID
+				}, getOne[types.BeneficiaryTxBody])},
+				"parentPromiseId": {Type: graphql.ID, Resolve: field(parentID)},
+				"quantity":        {Type: graphql.Int, Resolve: field(func(v *codec.Promise) any { return v.Quantity })},
+				"unit":            {Type: graphql.String, Resolve: field(func(v *codec.Promise) any { return v.Unit })},
+				"progress": {Type: graphql.NewNonNull(progressType), Resolve: func(p graphql.ResolveParams) (any, error) {
+					return progress(p.Source.(*codec.Promise).ID)(p)
+				}},
+				"recurrence": {Type: recurrenceType, Resolve: field(func(v *codec.Promise) any {
+					if v.Recurrence == nil {
+						return nil
+					}
+					return v.Recurrence
+				})},
+				"attachments": {Type: nonNullList(graphql.String), Resolve: field(func(v *codec.Promise) any {
+					if v.Attachments == nil {
+						return []string{}
+					}
+					return v.Attachments
+				})},
+				"parent": {Type: promiseType, Resolve: ref("promise", func(p graphql.ResolveParams) string {
+					id, _ := parentID(p.Source.(*codec.Promise)).(string)
+					return id
+				}, getOne[codec.Promise])},
+				"children": {Type: nonNullList(promiseType), Resolve: func(p graphql.ResolveParams) (any, error) {
+					id := p.Source.(*codec.Promise).ID
+					return promisesWhere(func(v *codec.Promise) bool {
+						return v.ParentPromiseID != nil && *v.ParentPromiseID == id
+					})(p)
+				}},
+				"commitments": {Type: nonNullList(commitmentType), Resolve: func(p graphql.ResolveParams) (any, error) {
+					id := p.Source.(*codec.Promise).ID
+					return commitmentsWhere(func(c *codec.Commitment) bool { return c.PromiseID == id })(p)
+				}},
+			}
+		}),
+	})
+
+	commitmentType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Commitment",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":        {Type: graphql.NewNonNull(graphql.ID), Resolve: field(func(c *codec.Commitment) any { return c.ID })},
+				"due":       {Type: timestamp, Resolve: field(func(c *codec.Commitment) any { return c.Due })},
+				"promiseId": {Type: graphql.ID, Resolve: field(func(c *codec.Commitment) any { return c.PromiseID })},
+				"promise": {Type: promiseType, Resolve: ref("promise", func(p graphql.ResolveParams) string {
+					return p.Source.(*codec.Commitment).PromiseID
+				}, getOne[codec.Promise])},
+				"commiterId": {Type: graphql.ID, Resolve: field(func(c *codec.Commitment) any { return c.CommiterID })},
+				"commiter": {Type: commiterType, Resolve: ref("commiter", func(p graphql.ResolveParams) string {
+					return p.Source.(*codec.Commitment).CommiterID
+				}, getOne[types.CommiterTxBody])},
+				"quantity": {Type: graphql.Int, Resolve: field(func(c *codec.Commitment) any { return c.Quantity })},
+				"unit":     {Type: graphql.String, Resolve: field(func(c *codec.Commitment) any { return c.Unit })},
+				"cosigners": {Type: nonNullList(graphql.String), Resolve: field(func(c *codec.Commitment) any {
+					if c.Cosigners == nil {
+						return []string{}
+					}
+					return c.Cosigners
+				})},
+				"progress": {Type: graphql.NewNonNull(progressType), Resolve: func(p graphql.ResolveParams) (any, error) {
+					return progress(p.Source.(*codec.Commitment).ID)(p)
+				}},
+				"fulfillments": {Type: nonNullList(fulfillmentType), Resolve: func(p graphql.ResolveParams) (any, error) {
+					id := p.Source.(*codec.Commitment).ID
+					return fulfillmentsWhere(func(f *codec.Fulfillment) bool { return f.CommitmentID == id })(p)
+				}},
+			}
+		}),
+	})
+
+	fulfillmentType = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Fulfillment",
+		Description: "Отметка коммитера о выполнении обязательства (частичном, если у обязательства есть объём).",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":           {Type: graphql.NewNonNull(graphql.ID), Resolve: field(func(f *codec.Fulfillment) any { return f.ID })},
+				"commitmentId": {Type: graphql.NewNonNull(graphql.ID), Resolve: field(func(f *codec.Fulfillment) any { return f.CommitmentID })},
+				"commitment": {Type: commitmentType, Resolve: ref("commitment", func(p graphql.ResolveParams) string {
+					return p.Source.(*codec.Fulfillment).CommitmentID
+				}, getOne[codec.Commitment])},
+				"quantity": {Type: graphql.Int, Resolve: field(func(f *codec.Fulfillment) any { return f.Quantity })},
+			}
+		}),
+	})
+
+	byID := func(kind string, get func(*blockchain.Snapshot, string, string) (any, error)) graphql.FieldResolveFn {
+		return ref(kind, func(p graphql.ResolveParams) string { return strArg(p, "id") }, get)
+	}
+	all := func(kind string, get func(*blockchain.Snapshot, string) (any, error)) graphql.FieldResolveFn {
+		return func(p graphql.ResolveParams) (any, error) {
+			return r.read(p.Context, func(s *blockchain.Snapshot) (any, error) { return get(s, kind) })
+		}
+	}
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"commiter":      {Type: commiterType, Args: idArgs, Resolve: byID("commiter", getOne[types.CommiterTxBody])},
+			"commiters":     {Type: nonNullList(commiterType), Resolve: all("commiter", func(s *blockchain.Snapshot, k string) (any, error) { return getAll[types.CommiterTxBody](s, k, nil) })},
+			"beneficiary":   {Type: beneficiaryType, Args: idArgs, Resolve: byID("beneficiary", getOne[types.BeneficiaryTxBody])},
+			"beneficiaries": {Type: nonNullList(beneficiaryType), Resolve: all("beneficiary", func(s *blockchain.Snapshot, k string) (any, error) { return getAll[types.BeneficiaryTxBody](s, k, nil) })},
+			"promise":       {Type: promiseType, Args: idArgs, Resolve: byID("promise", getOne[codec.Promise])},
+			"promises": {
+				Type:        nonNullList(promiseType),
+				Description: "Все обещания; beneficiary и parent сужают выборку.",
+				Args: graphql.FieldConfigArgument{
+					"beneficiary": {Type: graphql.ID},
+					"parent":      {Type: graphql.ID},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					beneficiary, parent := strArg(p, "beneficiary"), strArg(p, "parent")
+					return promisesWhere(func(v *codec.Promise) bool {
+						return (beneficiary == "" || v.BeneficiaryID == fullID("beneficiary", beneficiary)) &&
+							(parent == "" || v.ParentPromiseID != nil && *v.ParentPromiseID == fullID("promise", parent))
+					})(p)
+				},
+			},
+			"commitment": {Type: commitmentType, Args: idArgs, Resolve: byID("commitment", getOne[codec.Commitment])},
+			"commitments": {
+				Type:        nonNullList(commitmentType),
+				Description: "Все обязательства; promise и commiter сужают выборку.",
+				Args: graphql.FieldConfigArgument{
+					"promise":  {Type: graphql.ID},
+					"commiter": {Type: graphql.ID},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					promise, commiter := strArg(p, "promise"), strArg(p, "commiter")
+					return commitmentsWhere(func(c *codec.Commitment) bool {
+						return (promise == "" || c.PromiseID == fullID("promise", promise)) &&
+							(commiter == "" || c.CommiterID == fullID("commiter", commiter))
+					})(p)
+				},
+			},
+			"fulfillment": {Type: fulfillmentType, Args: idArgs, Resolve: byID("fulfillment", getOne[codec.Fulfillment])},
+			"fulfillments": {
+				Type:        nonNullList(fulfillmentType),
+				Description: "Все отметки о выполнении; commitment сужает выборку.",
+				Args: graphql.FieldConfigArgument{
+					"commitment": {Type: graphql.ID},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					commitment := strArg(p, "commitment")
+					return fulfillmentsWhere(func(f *codec.Fulfillment) bool {
+						return commitment == "" || f.CommitmentID == fullID("commitment", commitment)
+					})(p)
+				},
+			},
+		},
+	})
+
+	source := func(p graphql.ResolveParams) (any, error) { return p.Source, nil }
+	subscription := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Subscription",
+		Fields: graphql.Fields{
+			"commiterRegistered": {
+				Type:      graphql.NewNonNull(commiterType),
+				Subscribe: subscribeTo(r, "commiter", func(*types.CommiterTxBody, graphql.ResolveParams) bool { return true }),
+				Resolve:   source,
+			},
+			"beneficiaryRegistered": {
+				Type:      graphql.NewNonNull(beneficiaryType),
+				Subscribe: subscribeTo(r, "beneficiary", func(*types.BeneficiaryTxBody, graphql.ResolveParams) bool { return true }),
+				Resolve:   source,
+			},
+			"promiseCreated": {
+				Type: graphql.NewNonNull(promiseType),
+				Args: graphql.FieldConfigArgument{
+					"beneficiary": {Type: graphql.ID},
+				},
+				Subscribe: subscribeTo(r, "promise", func(v *codec.Promise, p graphql.ResolveParams) bool {
+					b := strArg(p, "beneficiary")
+					return b == "" || v.BeneficiaryID == fullID("beneficiary", b)
+				}),
+				Resolve: source,
+			},
+			"commitmentCreated": {
+				Type: graphql.NewNonNull(commitmentType),
+				Args: graphql.FieldConfigArgument{
+					"promise":  {Type: graphql.ID},
+					"commiter": {Type: graphql.ID},
+				},
+				Subscribe: subscribeTo(r, "commitment", func(c *codec.Commitment, p graphql.ResolveParams) bool {
+					promise, commiter := strArg(p, "promise"), strArg(p, "commiter")
+					return (promise == "" || c.PromiseID == fullID("promise", promise)) &&
+						(commiter == "" || c.CommiterID == fullID("commiter", commiter))
+				}),
+				Resolve: source,
+			},
+			"fulfillmentRecorded": {
+				Type: graphql.NewNonNull(fulfillmentType),
+				Args: graphql.FieldConfigArgument{
+					"commitment": {Type: graphql.ID},
+				},
+				Subscribe: subscribeTo(r, "fulfillment", func(f *codec.Fulfillment, p graphql.ResolveParams) bool {
+					c := strArg(p, "commitment")
+					return c == "" || f.CommitmentID == fullID("commitment", c)
+				}),
+				Resolve: source,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:        query,
+		Subscription: subscription,
+	})
+}
+
+// subscribeTo подписывает на записи вида kind из зафиксированных блоков.
+// Канал закрывается с концом подписки или если подписчик отстал (см.
+// PromiseApp.Subscribe).
+func subscribeTo[T any](r *resolver, kind string, match func(*T, graphql.ResolveParams) bool) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		events, cancel := r.app.Subscribe()
+		out := make(chan any)
+		go func() {
+			defer close(out)
+			defer cancel()
+			for {
+				select {
+				case <-p.Context.Done():
+					return
+				case ev, ok := <-events:
+					if !ok {
+						return
+					}
+					for _, rec := range ev.Records {
+						if rec.Kind != kind {
+							continue
+						}
+						v, err := decode[T](rec.Value)
+						if err != nil || !match(v, p) {
+							continue
+						}
+						select {
+						case out <- v:
+						case <-p.Context.Done():
+							return
+						}
+					}
+				}
+			}
+		}()
+		return out, nil
+	}
+}
@@ -0,0 +1,323 @@
+// Package gql — GraphQL-доступ к реестру: запросы разрешаются в одной
+// read-транзакции Badger (согласованный снимок), подписки питаются событиями
+// зафиксированных блоков (PromiseApp.Subscribe). Запросы — POST/GET /graphql,
+// подписки — WebSocket на том же пути по протоколу graphql-transport-ws.
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gregorybednov/lbc/blockchain"
+	"github.com/gregorybednov/lbc/stream"
+
+	"github.com/gorilla/websocket"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+type Server struct {
+	app      *blockchain.PromiseApp
+	schema   graphql.Schema
+	upgrader websocket.Upgrader
+}
+
+// New — GraphQL-сервер; подписки по WebSocket принимаются с того же хоста и
+// с источников origins (см. stream.CheckOrigin).
+func New(app *blockchain.PromiseApp, origins []string) (*Server, error) {
+	schema, err := newSchema(&resolver{app: app})
+	if err != nil {
+		return nil, err
+	}
+	return &Server{app: app, schema: schema, upgrader: websocket.Upgrader{
+		Subprotocols: []string{wsProtocol},
+		CheckOrigin:  stream.CheckOrigin(origins),
+	}}, nil
+}
+
+// Service — blockchain.Service, обслуживающий /graphql на addr.
+func Service(addr string, origins []string) blockchain.Service {
+	return func(ctx context.Context, app *blockchain.PromiseApp) error {
+		s, err := New(app, origins)
+		if err != nil {
+			return err
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/graphql", s)
+		srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			srv.Shutdown(shutdownCtx)
+		}()
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
+
+type request struct {
+	Query         string         `json:"query"`
+	Variables     map[string]any `json:"variables"`
+	OperationName string         `json:"operationName"`
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		s.serveWS(w, r)
+		return
+	}
+
+	var req request
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeResult(w, http.StatusBadRequest, errorResult(err))
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeResult(w, http.StatusBadRequest, errorResult(err))
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	op, err := operation(req)
+	if err != nil {
+		writeResult(w, http.StatusBadRequest, errorResult(err))
+		return
+	}
+	if op == ast.OperationTypeSubscription {
+		writeResult(w, http.StatusBadRequest, errorResult(errors.New("subscriptions require a WebSocket (graphql-transport-ws)")))
+		return
+	}
+	writeResult(w, http.StatusOK, s.execute(r.Context(), req))
+}
+
+// execute выполняет запрос над одним снимком реестра.
+func (s *Server) execute(ctx context.Context, req request) *graphql.Result {
+	var res *graphql.Result
+	err := s.app.View(func(snap *blockchain.Snapshot) error {
+		res = graphql.Do(graphql.Params{
+			Schema:         s.schema,
+			RequestString:  req.Query,
+			VariableValues: req.Variables,
+			OperationName:  req.OperationName,
+			Context:        context.WithValue(ctx, snapshotKey{}, snap),
+		})
+		return nil
+	})
+	if err != nil {
+		return errorResult(err)
+	}
+	return res
+}
+
+// operation возвращает тип выполняемой операции (query или subscription).
+func operation(req request) (string, error) {
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(req.Query)})})
+	if err != nil {
+		return "", err
+	}
+	var ops []*ast.OperationDefinition
+	for _, d := range doc.Definitions {
+		if op, ok := d.(*ast.OperationDefinition); ok {
+			if req.OperationName == "" || (op.Name != nil && op.Name.Value == req.OperationName) {
+				ops = append(ops, op)
+			}
+		}
+	}
+	if len(ops) != 1 {
+		return "", errors.New("operationName must select exactly one operation")
+	}
+	return ops[0].Operation, nil
+}
+
+func errorResult(err error) *graphql.Result {
+	return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(err)}}
+}
+
+func writeResult(w http.ResponseWriter, status int, res *graphql.Result) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
+
+// ---- graphql-transport-ws ----
+
+const wsProtocol = "graphql-transport-ws"
+
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type wsConn struct {
+	conn *websocket.Conn
+	mu   sync.Mutex // запись в соединение
+}
+
+func (c *wsConn) send(id, typ string, payload any) error {
+	msg := wsMessage{ID: id, Type: typ}
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		msg.Payload = raw
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteJSON(msg)
+}
+
+func (c *wsConn) close(code int, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	c.conn.Close()
+}
+
+func (s *Server) serveWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &wsConn{conn: conn}
+	if conn.Subprotocol() != wsProtocol {
+		c.close(4406, "subprotocol not acceptable")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	var (
+		mu     sync.Mutex
+		active = map[string]context.CancelFunc{}
+		acked  bool
+	)
+
+	for {
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				c.close(4400, "invalid message")
+			}
+			return
+		}
+		switch msg.Type {
+		case "connection_init":
+			if acked {
+				c.close(4429, "too many initialisation requests")
+				return
+			}
+			acked = true
+			c.send("", "connection_ack", nil)
+		case "ping":
+			c.send("", "pong", nil)
+		case "pong":
+		case "subscribe":
+			if !acked {
+				c.close(4401, "unauthorized")
+				return
+			}
+			var req request
+			if err := json.Unmarshal(msg.Payload, &req); err != nil || msg.ID == "" {
+				c.close(4400, "invalid subscribe message")
+				return
+			}
+			mu.Lock()
+			if _, dup := active[msg.ID]; dup {
+				mu.Unlock()
+				c.close(4409, "subscriber for "+msg.ID+" already exists")
+				return
+			}
+			subCtx, subCancel := context.WithCancel(ctx)
+			active[msg.ID] = subCancel
+			mu.Unlock()
+
+			go func(id string) {
+				failed := s.runOperation(subCtx, c, id, req)
+				mu.Lock()
+				if _, ok := active[id]; ok {
+					delete(active, id)
+					if !failed {
+						c.send(id, "complete", nil)
+					}
+				}
+				mu.Unlock()
+				subCancel()
+			}(msg.ID)
+		case "complete":
+			mu.Lock()
+			if stop, ok := active[msg.ID]; ok {
+				delete(active, msg.ID)
+				stop()
+			}
+			mu.Unlock()
+		default:
+			c.close(4400, "unknown message type "+msg.Type)
+			return
+		}
+	}
+}
+
+// runOperation выполняет операцию из subscribe: запрос — один next,
+// подписка — next на каждое событие до отмены ctx. Возвращает true, если
+// операция завершилась сообщением error (complete после него не шлётся).
+func (s *Server) runOperation(ctx context.Context, c *wsConn, id string, req request) bool {
+	op, err := operation(req)
+	if err != nil {
+		c.send(id, "error", []gqlerrors.FormattedError{gqlerrors.FormatError(err)})
+		return true
+	}
+	if op != ast.OperationTypeSubscription {
+		return sendResult(c, id, s.execute(ctx, req))
+	}
+	results := graphql.Subscribe(graphql.Params{
+		Schema:         s.schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        ctx,
+	})
+	// Канал дочитывается до закрытия, иначе горутина graphql-go зависнет.
+	failed := false
+	for res := range results {
+		if ctx.Err() == nil && !failed {
+			failed = sendResult(c, id, res)
+		}
+	}
+	return failed
+}
+
+// sendResult отправляет результат как next, а ошибку без данных (разбор,
+// валидация) — как error, как того требует протокол.
+func sendResult(c *wsConn, id string, res *graphql.Result) bool {
+	if res.Data == nil && len(res.Errors) > 0 {
+		c.send(id, "error", res.Errors)
+		return true
+	}
+	c.send(id, "next", res)
+	return false
+}
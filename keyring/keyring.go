@@ -0,0 +1,294 @@
+// Package keyring хранит ed25519-ключи коммитеров в каталоге, по файлу на ключ.
+// Приватный ключ шифруется XChaCha20-Poly1305 ключом, выведенным из пароля
+// через scrypt; имя, публичный ключ и ID коммитера лежат открыто, чтобы
+// list/show работали без пароля.
+package keyring
+
+import (
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	recordVersion = 1
+	fileSuffix    = ".json"
+
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+var (
+	ErrNotFound      = errors.New("key not found")
+	ErrExists        = errors.New("key already exists")
+	ErrBadPassphrase = errors.New("wrong passphrase or corrupted key")
+
+	namePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+)
+
+type kdfParams struct {
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+	Salt string `json:"salt"`
+}
+
+// Record — содержимое файла ключа. Этот же формат используется для экспорта.
+type Record struct {
+	Version    int       `json:"version"`
+	Name       string    `json:"name"`
+	CommiterID string    `json:"commiter_id"`
+	PubKey     string    `json:"pub_key"` // base64
+	KDF        kdfParams `json:"kdf"`
+	Nonce      string    `json:"nonce"`
+	Ciphertext string    `json:"ciphertext"`
+}
+
+type Keyring struct {
+	dir string
+}
+
+func Open(dir string) (*Keyring, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Keyring{dir: dir}, nil
+}
+
+// CommiterID — ID коммитера в цепочке для данного ключа.
+func CommiterID(pub ed25519.PublicKey) string {
+	return "commiter:" + base64.StdEncoding.EncodeToString(pub)
+}
+
+func (k *Keyring) path(name string) string {
+	return filepath.Join(k.dir, name+fileSuffix)
+}
+
+// Add создаёт новый ключ под именем name.
+func (k *Keyring) Add(name, passphrase string) (*Record, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return k.Import(name, priv, passphrase)
+}
+
+// Import сохраняет существующий приватный ключ под именем name.
+func (k *Keyring) Import(name string, priv ed25519.PrivateKey, passphrase string) (*Record, error) {
+	rec, err := seal(name, priv, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return rec, k.write(rec)
+}
+
+// ImportRecord сохраняет ранее экспортированную запись, при необходимости
+// переименовывая её. Пароль нужен, чтобы убедиться, что запись цела.
+// commiter_id не входит в AAD, поэтому сверяется с ключом отдельно: иначе
+// подменённый ID выдал бы ключ за чужого коммитера в list и --from.
+func (k *Keyring) ImportRecord(name string, rec *Record, passphrase string) (*Record, error) {
+	priv, err := rec.Decrypt(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if want := CommiterID(priv.Public().(ed25519.PublicKey)); rec.CommiterID != want {
+		return nil, fmt.Errorf("commiter_id %q does not match the key: want %q", rec.CommiterID, want)
+	}
+	if rec.Name != name {
+		// Имя входит в AAD, поэтому при переименовании ключ перешифровывается.
+		return k.Import(name, priv, passphrase)
+	}
+	return rec, k.write(rec)
+}
+
+func (k *Keyring) write(rec *Record) error {
+	if !namePattern.MatchString(rec.Name) {
+		return fmt.Errorf("invalid key name %q", rec.Name)
+	}
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(k.path(rec.Name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		return ErrExists
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Get читает запись без расшифровки.
+func (k *Keyring) Get(name string) (*Record, error) {
+	if !namePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid key name %q", name)
+	}
+	data, err := os.ReadFile(k.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rec Record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("corrupted key file %s: %w", name, err)
+	}
+	return &rec, nil
+}
+
+// List возвращает все записи, упорядоченные по имени.
+func (k *Keyring) List() ([]*Record, error) {
+	entries, err := os.ReadDir(k.dir)
+	if err != nil {
+		return nil, err
+	}
+	var result []*Record
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), fileSuffix) {
+			continue
+		}
+		rec, err := k.Get(strings.TrimSuffix(e.Name(), fileSuffix))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, rec)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// FindByCommiterID ищет локальный ключ по ID коммитера в цепочке.
+func (k *Keyring) FindByCommiterID(id string) (*Record, error) {
+	all, err := k.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range all {
+		if rec.CommiterID == id {
+			return rec, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (k *Keyring) Delete(name string) error {
+	if _, err := k.Get(name); err != nil {
+		return err
+	}
+	return os.Remove(k.path(name))
+}
+
+// Unlock расшифровывает приватный ключ.
+func (k *Keyring) Unlock(name, passphrase string) (ed25519.PrivateKey, error) {
+	rec, err := k.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return rec.Decrypt(passphrase)
+}
+
+func (r *Record) Decrypt(passphrase string) (ed25519.PrivateKey, error) {
+	if r.Version != recordVersion {
+		return nil, fmt.Errorf("unsupported key record version %d", r.Version)
+	}
+	salt, err := base64.StdEncoding.DecodeString(r.KDF.Salt)
+	if err != nil {
+		return nil, errors.New("invalid salt base64")
+	}
+	nonce, err := base64.StdEncoding.DecodeString(r.Nonce)
+	if err != nil {
+		return nil, errors.New("invalid nonce base64")
+	}
+	ct, err := base64.StdEncoding.DecodeString(r.Ciphertext)
+	if err != nil {
+		return nil, errors.New("invalid ciphertext base64")
+	}
+	aead, err := deriveAEAD(passphrase, salt, r.KDF.N, r.KDF.R, r.KDF.P)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid nonce length")
+	}
+	plain, err := aead.Open(nil, nonce, ct, r.aad())
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+	if len(plain) != ed25519.PrivateKeySize {
+		return nil, ErrBadPassphrase
+	}
+	priv := ed25519.PrivateKey(plain)
+	if base64.StdEncoding.EncodeToString(priv.Public().(ed25519.PublicKey)) != r.PubKey {
+		return nil, errors.New("public key does not match private key")
+	}
+	return priv, nil
+}
+
+// aad привязывает шифртекст к открытым полям записи.
+func (r *Record) aad() []byte {
+	return []byte(fmt.Sprintf("lbc-keyring/v%d\x00%s\x00%s", r.Version, r.Name, r.PubKey))
+}
+
+func seal(name string, priv ed25519.PrivateKey, passphrase string) (*Record, error) {
+	if len(priv) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid private key size: %d", len(priv))
+	}
+	if passphrase == "" {
+		return nil, errors.New("empty passphrase")
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := deriveAEAD(passphrase, salt, scryptN, scryptR, scryptP)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	pub := priv.Public().(ed25519.PublicKey)
+	rec := &Record{
+		Version:    recordVersion,
+		Name:       name,
+		CommiterID: CommiterID(pub),
+		PubKey:     base64.StdEncoding.EncodeToString(pub),
+		KDF: kdfParams{
+			N:    scryptN,
+			R:    scryptR,
+			P:    scryptP,
+			Salt: base64.StdEncoding.EncodeToString(salt),
+		},
+		Nonce: base64.StdEncoding.EncodeToString(nonce),
+	}
+	rec.Ciphertext = base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, priv, rec.aad()))
+	return rec, nil
+}
+
+func deriveAEAD(passphrase string, salt []byte, n, r, p int) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, n, r, p, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("scrypt: %w", err)
+	}
+	return chacha20poly1305.NewX(key)
+}
@@ -0,0 +1,67 @@
+package keyring
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestImportRecord(t *testing.T) {
+	src, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig, err := src.Add("alice", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := src.Add("bob", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		edit    func(r *Record)
+		as      string
+		pass    string
+		wantErr bool
+	}{
+		{name: "same name", as: "alice", pass: "pass"},
+		{name: "renamed", as: "alice2", pass: "pass"},
+		{name: "wrong passphrase", as: "alice", pass: "nope", wantErr: true},
+		{name: "foreign commiter id", edit: func(r *Record) { r.CommiterID = other.CommiterID }, as: "alice", pass: "pass", wantErr: true},
+		{name: "foreign commiter id, renamed", edit: func(r *Record) { r.CommiterID = other.CommiterID }, as: "alice2", pass: "pass", wantErr: true},
+		{name: "foreign public key", edit: func(r *Record) { r.PubKey = other.PubKey }, as: "alice", pass: "pass", wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dst, err := Open(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec := *orig
+			if tc.edit != nil {
+				tc.edit(&rec)
+			}
+			got, err := dst.ImportRecord(tc.as, &rec, tc.pass)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("imported a bad record")
+				}
+				if _, err := dst.Get(tc.as); !errors.Is(err, ErrNotFound) {
+					t.Errorf("bad record was written: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Name != tc.as || got.CommiterID != orig.CommiterID {
+				t.Errorf("got %s/%s, want %s/%s", got.Name, got.CommiterID, tc.as, orig.CommiterID)
+			}
+			if _, err := dst.Unlock(tc.as, tc.pass); err != nil {
+				t.Errorf("unlock imported key: %v", err)
+			}
+		})
+	}
+}
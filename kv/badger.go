@@ -0,0 +1,129 @@
+package kv
+
+import (
+	"errors"
+
+	"github.com/dgraph-io/badger"
+)
+
+// BadgerStore — хранилище на Badger, движок по умолчанию.
+type BadgerStore struct {
+	db *badger.DB
+}
+
+// NewBadger оборачивает открытую базу Badger.
+func NewBadger(db *badger.DB) *BadgerStore { return &BadgerStore{db: db} }
+
+// Tables — число таблиц LSM.
+func (s *BadgerStore) Tables() int { return len(s.db.Tables(false)) }
+
+func (s *BadgerStore) Snapshot() (Snapshot, error) {
+	return badgerTxn{s.db.NewTransaction(false)}, nil
+}
+
+func (s *BadgerStore) NewTxn() Txn { return badgerTxn{s.db.NewTransaction(true)} }
+
+// NewBatch возвращает атомарный батч: WriteBatch Badger идёт через журнал
+// (см. journalKey).
+func (s *BadgerStore) NewBatch() Batch { return &journalBatch{engine: s.journal()} }
+
+func (s *BadgerStore) journal() journalEngine {
+	return journalEngine{
+		get: func() ([]byte, error) {
+			var v []byte
+			err := s.db.View(func(txn *badger.Txn) error {
+				var err error
+				v, err = badgerTxn{txn}.Get([]byte(journalKey))
+				return err
+			})
+			return v, err
+		},
+		set: func(data []byte) error {
+			return s.db.Update(func(txn *badger.Txn) error { return txn.Set([]byte(journalKey), data) })
+		},
+		del: func() error {
+			return s.db.Update(func(txn *badger.Txn) error { return txn.Delete([]byte(journalKey)) })
+		},
+		batch: func() Batch { return &badgerBatch{wb: s.db.NewWriteBatch()} },
+	}
+}
+
+func (s *BadgerStore) Close() error { return s.db.Close() }
+
+// Compact сводит LSM-дерево к одному уровню.
+func (s *BadgerStore) Compact() error { return s.db.Flatten(2) }
+
+// CollectGarbage переписывает файлы value log, пока находятся файлы с долей
+// мусора не меньше ratio, и возвращает число переписанных файлов.
+func (s *BadgerStore) CollectGarbage(ratio float64) (int, error) {
+	n := 0
+	for {
+		err := s.db.RunValueLogGC(ratio)
+		if errors.Is(err, badger.ErrNoRewrite) || errors.Is(err, badger.ErrRejected) {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+type badgerTxn struct {
+	txn *badger.Txn
+}
+
+func (t badgerTxn) Get(key []byte) ([]byte, error) {
+	item, err := t.txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
+func (t badgerTxn) Iterate(prefix, from []byte, fn func(key, value []byte) error) error {
+	it := t.txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+	for it.Seek(iterStart(prefix, from)); it.ValidForPrefix(prefix); it.Next() {
+		v, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if err := fn(it.Item().KeyCopy(nil), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t badgerTxn) Set(key, value []byte) error { return t.txn.Set(key, value) }
+func (t badgerTxn) Delete(key []byte) error     { return t.txn.Delete(key) }
+func (t badgerTxn) Commit() error               { return t.txn.Commit() }
+func (t badgerTxn) Discard()                    { t.txn.Discard() }
+func (t badgerTxn) Release()                    { t.txn.Discard() }
+
+// badgerBatch — WriteBatch Badger. Он сам делит большой батч на несколько
+// транзакций, поэтому Write не атомарен при аварийной остановке; снаружи
+// он используется только через journalBatch.
+type badgerBatch struct {
+	wb   *badger.WriteBatch
+	done bool // Flush или Cancel уже вызваны; повторный вызов паникует
+}
+
+func (b *badgerBatch) Set(key, value []byte) error { return b.wb.Set(key, value) }
+func (b *badgerBatch) Delete(key []byte) error     { return b.wb.Delete(key) }
+
+func (b *badgerBatch) Write() error {
+	b.done = true
+	return b.wb.Flush()
+}
+
+func (b *badgerBatch) Close() {
+	if !b.done {
+		b.done = true
+		b.wb.Cancel()
+	}
+}
@@ -0,0 +1,122 @@
+package kv
+
+import (
+	"errors"
+
+	badger4 "github.com/dgraph-io/badger/v4"
+)
+
+// Badger4Store — хранилище на Badger v4, сопровождаемом выпуске Badger. Формат
+// файлов несовместим с v1: базу переводят командой `lbc db upgrade`.
+type Badger4Store struct {
+	db *badger4.DB
+}
+
+// NewBadger4 оборачивает открытую базу Badger v4.
+func NewBadger4(db *badger4.DB) *Badger4Store { return &Badger4Store{db: db} }
+
+func (s *Badger4Store) Snapshot() (Snapshot, error) {
+	return badger4Txn{s.db.NewTransaction(false)}, nil
+}
+
+func (s *Badger4Store) NewTxn() Txn { return badger4Txn{s.db.NewTransaction(true)} }
+
+func (s *Badger4Store) NewBatch() Batch { return &journalBatch{engine: s.journal()} }
+
+func (s *Badger4Store) journal() journalEngine {
+	return journalEngine{
+		get: func() ([]byte, error) {
+			var v []byte
+			err := s.db.View(func(txn *badger4.Txn) error {
+				var err error
+				v, err = badger4Txn{txn}.Get([]byte(journalKey))
+				return err
+			})
+			return v, err
+		},
+		set: func(data []byte) error {
+			return s.db.Update(func(txn *badger4.Txn) error { return txn.Set([]byte(journalKey), data) })
+		},
+		del: func() error {
+			return s.db.Update(func(txn *badger4.Txn) error { return txn.Delete([]byte(journalKey)) })
+		},
+		batch: func() Batch { return &badger4Batch{wb: s.db.NewWriteBatch()} },
+	}
+}
+
+func (s *Badger4Store) Close() error { return s.db.Close() }
+
+// Tables — число таблиц LSM.
+func (s *Badger4Store) Tables() int { return len(s.db.Tables()) }
+
+func (s *Badger4Store) Compact() error { return s.db.Flatten(2) }
+
+func (s *Badger4Store) CollectGarbage(ratio float64) (int, error) {
+	n := 0
+	for {
+		err := s.db.RunValueLogGC(ratio)
+		if errors.Is(err, badger4.ErrNoRewrite) || errors.Is(err, badger4.ErrRejected) {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+type badger4Txn struct {
+	txn *badger4.Txn
+}
+
+func (t badger4Txn) Get(key []byte) ([]byte, error) {
+	item, err := t.txn.Get(key)
+	if err == badger4.ErrKeyNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
+func (t badger4Txn) Iterate(prefix, from []byte, fn func(key, value []byte) error) error {
+	it := t.txn.NewIterator(badger4.DefaultIteratorOptions)
+	defer it.Close()
+	for it.Seek(iterStart(prefix, from)); it.ValidForPrefix(prefix); it.Next() {
+		v, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if err := fn(it.Item().KeyCopy(nil), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t badger4Txn) Set(key, value []byte) error { return t.txn.Set(key, value) }
+func (t badger4Txn) Delete(key []byte) error     { return t.txn.Delete(key) }
+func (t badger4Txn) Commit() error               { return t.txn.Commit() }
+func (t badger4Txn) Discard()                    { t.txn.Discard() }
+func (t badger4Txn) Release()                    { t.txn.Discard() }
+
+type badger4Batch struct {
+	wb   *badger4.WriteBatch
+	done bool
+}
+
+func (b *badger4Batch) Set(key, value []byte) error { return b.wb.Set(key, value) }
+func (b *badger4Batch) Delete(key []byte) error     { return b.wb.Delete(key) }
+
+func (b *badger4Batch) Write() error {
+	b.done = true
+	return b.wb.Flush()
+}
+
+func (b *badger4Batch) Close() {
+	if !b.done {
+		b.done = true
+		b.wb.Cancel()
+	}
+}
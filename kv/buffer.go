@@ -0,0 +1,26 @@
+package kv
+
+// NewWriteBuffer возвращает буфер записей блока поверх s: записи копятся в
+// памяти, чтения видят их поверх базы, а Commit применяет всё одним
+// батчем. В отличие от NewTxn, у буфера нет ограничений движка на размер
+// транзакции и конфликтов с другими записями, поэтому результат блока не
+// зависит от настроек ноды.
+func NewWriteBuffer(s Store) Txn {
+	var base Snapshot
+	var err error
+	if b, ok := s.(bufferBaser); ok {
+		base, err = b.bufferBase()
+	} else {
+		base, err = s.Snapshot()
+	}
+	if err != nil {
+		return errTxn{err}
+	}
+	return newOverlayTxn(base, s.NewBatch)
+}
+
+// bufferBaser — хранилище, у которого буферу нужна не обычная основа:
+// снимок, держащий блокировку, остановил бы запись на всё время блока.
+type bufferBaser interface {
+	bufferBase() (Snapshot, error)
+}
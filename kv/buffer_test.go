@@ -0,0 +1,109 @@
+package kv
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestWriteBufferReads проверяет, что чтения буфера видят его незаписанные
+// Set и Delete поверх базы, а база их не видит до Commit.
+func TestWriteBufferReads(t *testing.T) {
+	base := map[string]string{"a:1": "1", "a:2": "2", "a:3": "3", "b:1": "x"}
+	tests := []struct {
+		name   string
+		set    map[string]string
+		delete []string
+		prefix string
+		from   string
+		want   []string // пары key=value в порядке обхода
+	}{
+		{"untouched", nil, nil, "a:", "", []string{"a:1=1", "a:2=2", "a:3=3"}},
+		{"delete middle", nil, []string{"a:2"}, "a:", "", []string{"a:1=1", "a:3=3"}},
+		{"delete all", nil, []string{"a:1", "a:2", "a:3"}, "a:", "", nil},
+		{"delete then set", map[string]string{"a:2": "new"}, []string{"a:1"}, "a:", "", []string{"a:2=new", "a:3=3"}},
+		{"insert between", map[string]string{"a:15": "n", "a:4": "m"}, nil, "a:", "", []string{"a:1=1", "a:15=n", "a:2=2", "a:3=3", "a:4=m"}},
+		{"from", map[string]string{"a:0": "z"}, []string{"a:3"}, "a:", "a:2", []string{"a:2=2"}},
+		{"other prefix", map[string]string{"c:1": "c"}, []string{"b:1"}, "", "", []string{"a:1=1", "a:2=2", "a:3=3", "c:1=c"}},
+	}
+	for _, backend := range []string{Memory, Badger, Badger4, "goleveldb"} {
+		for _, tt := range tests {
+			t.Run(backend+"/"+tt.name, func(t *testing.T) {
+				s, err := Open(backend, t.TempDir(), Options{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer s.Close()
+				if err := Update(s, func(txn Txn) error {
+					for k, v := range base {
+						if err := txn.Set([]byte(k), []byte(v)); err != nil {
+							return err
+						}
+					}
+					return nil
+				}); err != nil {
+					t.Fatal(err)
+				}
+
+				buf := NewWriteBuffer(s)
+				defer buf.Discard()
+				for _, k := range tt.delete {
+					if err := buf.Delete([]byte(k)); err != nil {
+						t.Fatal(err)
+					}
+				}
+				for k, v := range tt.set {
+					if err := buf.Set([]byte(k), []byte(v)); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if got := dump(t, buf, tt.prefix, tt.from); !slices.Equal(got, tt.want) {
+					t.Errorf("buffer: got %v, want %v", got, tt.want)
+				}
+				for _, k := range tt.delete {
+					if _, ok := tt.set[k]; ok {
+						continue
+					}
+					if _, err := buf.Get([]byte(k)); err != ErrNotFound {
+						t.Errorf("Get(%s) after Delete: %v, want ErrNotFound", k, err)
+					}
+				}
+				if err := View(s, func(r Reader) error {
+					if v, err := r.Get([]byte("a:1")); err != nil || string(v) != "1" {
+						t.Errorf("base a:1 = %q, %v before Commit", v, err)
+					}
+					return nil
+				}); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := buf.Commit(); err != nil {
+					t.Fatal(err)
+				}
+				if err := View(s, func(r Reader) error {
+					if got := dump(t, r, tt.prefix, tt.from); !slices.Equal(got, tt.want) {
+						t.Errorf("after Commit: got %v, want %v", got, tt.want)
+					}
+					return nil
+				}); err != nil {
+					t.Fatal(err)
+				}
+			})
+		}
+	}
+}
+
+func dump(t *testing.T, r Reader, prefix, from string) []string {
+	t.Helper()
+	var out []string
+	var fromKey []byte
+	if from != "" {
+		fromKey = []byte(from)
+	}
+	if err := r.Iterate([]byte(prefix), fromKey, func(key, value []byte) error {
+		out = append(out, string(key)+"="+string(value))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
@@ -0,0 +1,31 @@
+package kv
+
+// copyChunk — сколько ключей пишется одним батчем при Copy.
+const copyChunk = 10000
+
+// Copy переносит все ключи из src в dst батчами и возвращает их число.
+// Копия не атомарна: при ошибке dst нужно выбросить.
+func Copy(dst Store, src Reader) (int, error) {
+	n := 0
+	b := dst.NewBatch()
+	err := src.Iterate(nil, nil, func(key, value []byte) error {
+		if err := b.Set(key, value); err != nil {
+			return err
+		}
+		n++
+		if n%copyChunk != 0 {
+			return nil
+		}
+		err := b.Write()
+		b.Close()
+		b = dst.NewBatch()
+		return err
+	})
+	if err != nil {
+		b.Close()
+		return n, err
+	}
+	err = b.Write()
+	b.Close()
+	return n, err
+}
@@ -0,0 +1,170 @@
+package kv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// journalKey — незавершённый батч движка, чей батч не атомарен (Badger
+// WriteBatch сам делится на несколько транзакций). Перед записью батч
+// целиком кладётся под этот ключ одной транзакцией, после записи ключ
+// удаляется. Если нода остановилась посередине, Open дописывает батч из
+// журнала, так что после открытия в базе либо весь батч, либо ничего.
+const journalKey = "\x00kv:journal"
+
+// ErrJournalPending — база открыта только для чтения, а в ней остался
+// недописанный батч: состояние неполное, пока базу не откроют для записи.
+var ErrJournalPending = errors.New("database has an unfinished batch; open it for writing once to complete it")
+
+type journalOp struct {
+	key, value []byte
+	deleted    bool
+}
+
+// journalEngine — то, что журналу нужно от движка.
+type journalEngine struct {
+	// get читает ключ журнала; ErrNotFound — журнала нет.
+	get func() ([]byte, error)
+	// set и del пишут и удаляют ключ журнала, каждый одной транзакцией.
+	set func(data []byte) error
+	del func() error
+	// batch — собственный (неатомарный) батч движка.
+	batch func() Batch
+}
+
+// journalBatch копит операции в памяти и применяет их через журнал.
+type journalBatch struct {
+	engine journalEngine
+	ops    []journalOp
+}
+
+func (b *journalBatch) Set(key, value []byte) error {
+	b.ops = append(b.ops, journalOp{key: bytes.Clone(key), value: bytes.Clone(value)})
+	return nil
+}
+
+func (b *journalBatch) Delete(key []byte) error {
+	b.ops = append(b.ops, journalOp{key: bytes.Clone(key), deleted: true})
+	return nil
+}
+
+func (b *journalBatch) Write() error {
+	ops := b.ops
+	b.ops = nil
+	if len(ops) == 0 {
+		return nil
+	}
+	if err := b.engine.set(encodeJournal(ops)); err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+	return b.engine.apply(ops)
+}
+
+func (b *journalBatch) Close() { b.ops = nil }
+
+// apply пишет операции батчем движка и удаляет журнал.
+func (e journalEngine) apply(ops []journalOp) error {
+	wb := e.batch()
+	defer wb.Close()
+	for _, op := range ops {
+		var err error
+		if op.deleted {
+			err = wb.Delete(op.key)
+		} else {
+			err = wb.Set(op.key, op.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := wb.Write(); err != nil {
+		return err
+	}
+	return e.del()
+}
+
+// recover дописывает батч, оставшийся в журнале после аварийной остановки.
+func (e journalEngine) recover() error {
+	data, err := e.get()
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ops, err := decodeJournal(data)
+	if err != nil {
+		return fmt.Errorf("read journal: %w", err)
+	}
+	return e.apply(ops)
+}
+
+// open проверяет журнал при открытии базы: дописывает недописанный батч
+// или, если база открыта только для чтения, сообщает о нём.
+func (e journalEngine) open(readOnly bool) error {
+	if !readOnly {
+		return e.recover()
+	}
+	_, err := e.get()
+	switch err {
+	case ErrNotFound:
+		return nil
+	case nil:
+		return ErrJournalPending
+	}
+	return err
+}
+
+// Журнал — последовательность операций: байт флага (1 — удаление), затем
+// uvarint-длина и байты ключа, для записи — ещё длина и байты значения.
+func encodeJournal(ops []journalOp) []byte {
+	var b []byte
+	for _, op := range ops {
+		if op.deleted {
+			b = append(b, 1)
+			b = binary.AppendUvarint(b, uint64(len(op.key)))
+			b = append(b, op.key...)
+			continue
+		}
+		b = append(b, 0)
+		b = binary.AppendUvarint(b, uint64(len(op.key)))
+		b = append(b, op.key...)
+		b = binary.AppendUvarint(b, uint64(len(op.value)))
+		b = append(b, op.value...)
+	}
+	return b
+}
+
+func decodeJournal(b []byte) ([]journalOp, error) {
+	var ops []journalOp
+	next := func() ([]byte, error) {
+		n, k := binary.Uvarint(b)
+		if k <= 0 || uint64(len(b)-k) < n {
+			return nil, errors.New("truncated journal")
+		}
+		v := b[k : k+int(n)]
+		b = b[k+int(n):]
+		return v, nil
+	}
+	for len(b) > 0 {
+		flag := b[0]
+		b = b[1:]
+		if flag > 1 {
+			return nil, fmt.Errorf("bad journal op %d", flag)
+		}
+		key, err := next()
+		if err != nil {
+			return nil, err
+		}
+		op := journalOp{key: key, deleted: flag == 1}
+		if !op.deleted {
+			if op.value, err = next(); err != nil {
+				return nil, err
+			}
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
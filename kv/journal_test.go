@@ -0,0 +1,123 @@
+package kv
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestJournalRoundTrip(t *testing.T) {
+	ops := []journalOp{
+		{key: []byte("a"), value: []byte("1")},
+		{key: []byte("b"), deleted: true},
+		{key: []byte("c"), value: []byte{}},
+		{key: bytes.Repeat([]byte{0xff}, 300), value: bytes.Repeat([]byte{7}, 70000)},
+	}
+	got, err := decodeJournal(encodeJournal(ops))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(ops) {
+		t.Fatalf("got %d ops, want %d", len(got), len(ops))
+	}
+	for i := range ops {
+		if !bytes.Equal(got[i].key, ops[i].key) || !bytes.Equal(got[i].value, ops[i].value) || got[i].deleted != ops[i].deleted {
+			t.Errorf("op %d: got %+v, want %+v", i, got[i], ops[i])
+		}
+	}
+	if _, err := decodeJournal(encodeJournal(ops)[:10]); err == nil {
+		t.Error("truncated journal decoded without error")
+	}
+}
+
+// TestJournalRecovery имитирует остановку после записи журнала, но до
+// записи самого батча: Open должен дописать батч целиком.
+func TestJournalRecovery(t *testing.T) {
+	for _, backend := range []string{Badger, Badger4} {
+		t.Run(backend, func(t *testing.T) {
+			dir := t.TempDir()
+			s, err := Open(backend, dir, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Update(s, func(txn Txn) error { return txn.Set([]byte("old"), []byte("x")) }); err != nil {
+				t.Fatal(err)
+			}
+			var engine journalEngine
+			switch st := s.(type) {
+			case *BadgerStore:
+				engine = st.journal()
+			case *Badger4Store:
+				engine = st.journal()
+			}
+			ops := []journalOp{
+				{key: []byte("meta:height"), value: []byte("7")},
+				{key: []byte("new"), value: []byte("y")},
+				{key: []byte("old"), deleted: true},
+			}
+			if err := engine.set(encodeJournal(ops)); err != nil {
+				t.Fatal(err)
+			}
+			s.Close()
+
+			if _, err := Open(backend, dir, Options{ReadOnly: true}); !errors.Is(err, ErrJournalPending) {
+				t.Fatalf("read-only open: got %v, want ErrJournalPending", err)
+			}
+
+			s, err = Open(backend, dir, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			err = View(s, func(r Reader) error {
+				for key, want := range map[string]string{"meta:height": "7", "new": "y"} {
+					v, err := r.Get([]byte(key))
+					if err != nil || string(v) != want {
+						t.Errorf("%s = %q, %v; want %q", key, v, err, want)
+					}
+				}
+				if _, err := r.Get([]byte("old")); err != ErrNotFound {
+					t.Errorf("old: got %v, want ErrNotFound", err)
+				}
+				if _, err := r.Get([]byte(journalKey)); err != ErrNotFound {
+					t.Errorf("journal left after recovery: %v", err)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestBatchLeavesNoJournal(t *testing.T) {
+	s, err := Open(Badger4, t.TempDir(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	b := s.NewBatch()
+	for i := 0; i < 1000; i++ {
+		if err := b.Set([]byte{byte(i >> 8), byte(i)}, bytes.Repeat([]byte{1}, 100)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Write(); err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+	n := 0
+	err = View(s, func(r Reader) error {
+		return r.Iterate(nil, nil, func(key, _ []byte) error {
+			if string(key) == journalKey {
+				t.Error("journal key left after Write")
+			}
+			n++
+			return nil
+		})
+	})
+	if err != nil || n != 1000 {
+		t.Fatalf("got %d keys, %v", n, err)
+	}
+}
@@ -0,0 +1,114 @@
+// Package kv — небольшой интерфейс хранилища ключ-значение, за которым
+// прячется база состояния ноды: чтение, запись, обход по префиксу,
+// атомарный батч и согласованный снимок. По умолчанию используется Badger;
+// для тестов есть хранилище в памяти, а goleveldb, boltdb и другие движки
+// подключаются через tm-db.
+package kv
+
+import (
+	"bytes"
+	"errors"
+)
+
+// ErrNotFound возвращает Get, если ключа нет.
+var ErrNotFound = errors.New("key not found")
+
+// Reader — чтение из хранилища.
+type Reader interface {
+	// Get возвращает копию значения или ErrNotFound.
+	Get(key []byte) ([]byte, error)
+	// Iterate вызывает fn для ключей с префиксом prefix по возрастанию,
+	// начиная с from (nil — с начала префикса). key и value — копии; ошибка
+	// fn прерывает обход и возвращается из Iterate.
+	Iterate(prefix, from []byte, fn func(key, value []byte) error) error
+}
+
+// Writer — запись в хранилище.
+type Writer interface {
+	Set(key, value []byte) error
+	Delete(key []byte) error
+}
+
+// Snapshot — согласованный снимок зафиксированного состояния. Должен быть
+// освобождён через Release.
+type Snapshot interface {
+	Reader
+	Release()
+}
+
+// Batch накапливает записи и применяет их разом в Write. После Write или
+// Close батч использовать нельзя.
+type Batch interface {
+	Writer
+	Write() error
+	Close()
+}
+
+// Txn — транзакция чтения-записи: чтения видят её собственные записи, а
+// записи становятся видны другим только после Commit.
+type Txn interface {
+	Reader
+	Writer
+	Commit() error
+	Discard()
+}
+
+// Store — хранилище состояния ноды.
+type Store interface {
+	Snapshot() (Snapshot, error)
+	NewTxn() Txn
+	NewBatch() Batch
+	Close() error
+}
+
+// Maintainer — обслуживание, которое есть не у каждого движка (сейчас
+// только у Badger).
+type Maintainer interface {
+	// Compact сжимает файлы базы.
+	Compact() error
+	// CollectGarbage освобождает место, занятое перезаписанными значениями;
+	// возвращает число переписанных файлов.
+	CollectGarbage(ratio float64) (int, error)
+}
+
+// View выполняет fn над снимком хранилища.
+func View(s Store, fn func(r Reader) error) error {
+	snap, err := s.Snapshot()
+	if err != nil {
+		return err
+	}
+	defer snap.Release()
+	return fn(snap)
+}
+
+// Update выполняет fn в транзакции и фиксирует её, если fn не вернула
+// ошибку.
+func Update(s Store, fn func(t Txn) error) error {
+	txn := s.NewTxn()
+	defer txn.Discard()
+	if err := fn(txn); err != nil {
+		return err
+	}
+	return txn.Commit()
+}
+
+// prefixEnd — первый ключ после всех ключей с префиксом prefix; nil, если
+// такого нет (префикс пуст или состоит из 0xff).
+func prefixEnd(prefix []byte) []byte {
+	end := bytes.Clone(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+// iterStart — начало обхода: from, если он не раньше prefix.
+func iterStart(prefix, from []byte) []byte {
+	if from != nil && bytes.Compare(from, prefix) > 0 {
+		return from
+	}
+	return prefix
+}
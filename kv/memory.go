@@ -0,0 +1,107 @@
+package kv
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/google/btree"
+)
+
+type memItem struct {
+	key, value []byte
+}
+
+func memLess(a, b memItem) bool { return bytes.Compare(a.key, b.key) < 0 }
+
+// MemStore — хранилище в памяти для тестов и временных нод: всё
+// состояние теряется при закрытии. Снимок — ленивая копия B-дерева, поэтому
+// он не мешает записи.
+type MemStore struct {
+	mu   sync.Mutex
+	tree *btree.BTreeG[memItem]
+}
+
+// NewMemory создаёт пустое хранилище в памяти.
+func NewMemory() *MemStore {
+	return &MemStore{tree: btree.NewG(32, memLess)}
+}
+
+func (s *MemStore) Snapshot() (Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return memSnapshot{s.tree.Clone()}, nil
+}
+
+func (s *MemStore) NewTxn() Txn {
+	snap, _ := s.Snapshot()
+	return newOverlayTxn(snap, s.NewBatch)
+}
+
+func (s *MemStore) NewBatch() Batch { return &memBatch{store: s} }
+
+func (s *MemStore) Close() error { return nil }
+
+type memSnapshot struct {
+	tree *btree.BTreeG[memItem]
+}
+
+func (s memSnapshot) Get(key []byte) ([]byte, error) {
+	item, ok := s.tree.Get(memItem{key: key})
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return bytes.Clone(item.value), nil
+}
+
+func (s memSnapshot) Iterate(prefix, from []byte, fn func(key, value []byte) error) error {
+	var err error
+	visit := func(item memItem) bool {
+		err = fn(bytes.Clone(item.key), bytes.Clone(item.value))
+		return err == nil
+	}
+	start := memItem{key: iterStart(prefix, from)}
+	if end := prefixEnd(prefix); end != nil {
+		s.tree.AscendRange(start, memItem{key: end}, visit)
+	} else {
+		s.tree.AscendGreaterOrEqual(start, visit)
+	}
+	return err
+}
+
+func (s memSnapshot) Release() {}
+
+type memOp struct {
+	key, value []byte
+	deleted    bool
+}
+
+type memBatch struct {
+	store *MemStore
+	ops   []memOp
+}
+
+func (b *memBatch) Set(key, value []byte) error {
+	b.ops = append(b.ops, memOp{key: bytes.Clone(key), value: bytes.Clone(value)})
+	return nil
+}
+
+func (b *memBatch) Delete(key []byte) error {
+	b.ops = append(b.ops, memOp{key: bytes.Clone(key), deleted: true})
+	return nil
+}
+
+func (b *memBatch) Write() error {
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+	for _, op := range b.ops {
+		if op.deleted {
+			b.store.tree.Delete(memItem{key: op.key})
+		} else {
+			b.store.tree.ReplaceOrInsert(memItem{key: op.key, value: op.value})
+		}
+	}
+	b.ops = nil
+	return nil
+}
+
+func (b *memBatch) Close() { b.ops = nil }
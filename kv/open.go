@@ -0,0 +1,88 @@
+package kv
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+	badger4 "github.com/dgraph-io/badger/v4"
+	dbm "github.com/tendermint/tm-db"
+)
+
+// Имена движков для [app] db_backend. Любое другое имя передаётся tm-db
+// как есть: goleveldb, а при сборке с соответствующими тегами — boltdb,
+// cleveldb, rocksdb.
+const (
+	Badger  = "badger"  // Badger v1
+	Badger4 = "badger4" // Badger v4
+	Memory  = "memory"
+)
+
+// DefaultBackend — движок, если db_backend не задан: так открываются базы
+// нод, созданных до появления [app] db_backend.
+const DefaultBackend = Badger
+
+// NewNodeBackend — движок, который lbc init записывает в конфигурацию
+// новой ноды.
+const NewNodeBackend = Badger4
+
+// Options — параметры открытия.
+type Options struct {
+	// ReadOnly открывает базу только для чтения; учитывается Badger,
+	// остальные движки открываются как обычно.
+	ReadOnly bool
+	// Truncate разрешает Badger v1 отрезать повреждённый хвост value log.
+	Truncate bool
+}
+
+// ErrTruncateNeeded — база Badger v1 открыта без Truncate, но хвост value log
+// повреждён.
+var ErrTruncateNeeded = errors.New("value log truncate required")
+
+// Open открывает хранилище движка backend в каталоге path.
+func Open(backend, path string, opts Options) (Store, error) {
+	switch backend {
+	case "", Badger:
+		bo := badger.DefaultOptions(path).WithTruncate(opts.Truncate)
+		if opts.ReadOnly {
+			bo = bo.WithReadOnly(true).WithLogger(nil)
+		}
+		db, err := badger.Open(bo)
+		if errors.Is(err, badger.ErrTruncateNeeded) {
+			return nil, fmt.Errorf("%w: %v", ErrTruncateNeeded, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		s := NewBadger(db)
+		if err := s.journal().open(opts.ReadOnly); err != nil {
+			db.Close()
+			return nil, err
+		}
+		return s, nil
+	case Badger4:
+		// Badger v4 сам отрезает повреждённый хвост при открытии: Truncate
+		// ему не нужен.
+		bo := badger4.DefaultOptions(path)
+		if opts.ReadOnly {
+			bo = bo.WithReadOnly(true).WithLogger(nil)
+		}
+		db, err := badger4.Open(bo)
+		if err != nil {
+			return nil, err
+		}
+		s := NewBadger4(db)
+		if err := s.journal().open(opts.ReadOnly); err != nil {
+			db.Close()
+			return nil, err
+		}
+		return s, nil
+	case Memory:
+		return NewMemory(), nil
+	}
+	db, err := dbm.NewDB("state", dbm.BackendType(backend), path)
+	if err != nil {
+		return nil, fmt.Errorf("db_backend %s: %w", backend, err)
+	}
+	return NewTMDB(db), nil
+}
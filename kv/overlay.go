@@ -0,0 +1,139 @@
+package kv
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+)
+
+type overlayEntry struct {
+	value   []byte
+	deleted bool
+}
+
+// overlayTxn — транзакция для движков без своих транзакций: чтения идут из
+// снимка, записи копятся в памяти и применяются одним батчем в Commit.
+// Конфликты не отслеживаются — побеждает последний Commit.
+type overlayTxn struct {
+	base   Snapshot
+	writes map[string]overlayEntry
+	batch  func() Batch
+}
+
+func newOverlayTxn(base Snapshot, batch func() Batch) *overlayTxn {
+	return &overlayTxn{base: base, writes: map[string]overlayEntry{}, batch: batch}
+}
+
+func (t *overlayTxn) Get(key []byte) ([]byte, error) {
+	if e, ok := t.writes[string(key)]; ok {
+		if e.deleted {
+			return nil, ErrNotFound
+		}
+		return bytes.Clone(e.value), nil
+	}
+	return t.base.Get(key)
+}
+
+func (t *overlayTxn) Iterate(prefix, from []byte, fn func(key, value []byte) error) error {
+	start := string(iterStart(prefix, from))
+	var keys []string
+	for k := range t.writes {
+		if k >= start && bytes.HasPrefix([]byte(k), prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	i := 0
+	emit := func(k string) error {
+		if e := t.writes[k]; !e.deleted {
+			return fn([]byte(k), bytes.Clone(e.value))
+		}
+		return nil
+	}
+	err := t.base.Iterate(prefix, from, func(key, value []byte) error {
+		for ; i < len(keys) && keys[i] < string(key); i++ {
+			if err := emit(keys[i]); err != nil {
+				return err
+			}
+		}
+		if i < len(keys) && keys[i] == string(key) {
+			i++
+			return emit(string(key))
+		}
+		return fn(key, value)
+	})
+	if err != nil {
+		return err
+	}
+	for ; i < len(keys); i++ {
+		if err := emit(keys[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *overlayTxn) Set(key, value []byte) error {
+	t.writes[string(key)] = overlayEntry{value: bytes.Clone(value)}
+	return nil
+}
+
+func (t *overlayTxn) Delete(key []byte) error {
+	t.writes[string(key)] = overlayEntry{deleted: true}
+	return nil
+}
+
+// Commit пишет ключи по возрастанию: содержимое батча не зависит от
+// порядка обхода map.
+func (t *overlayTxn) Commit() error {
+	if t.writes == nil {
+		return errTxnDone
+	}
+	keys := make([]string, 0, len(t.writes))
+	for k := range t.writes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b := t.batch()
+	defer b.Close()
+	for _, k := range keys {
+		e := t.writes[k]
+		var err error
+		if e.deleted {
+			err = b.Delete([]byte(k))
+		} else {
+			err = b.Set([]byte(k), e.value)
+		}
+		if err != nil {
+			t.Discard()
+			return err
+		}
+	}
+	// Снимок отпускается до записи: у движков без снимков он держит
+	// блокировку чтения, и Write иначе её не дождётся.
+	t.Discard()
+	return b.Write()
+}
+
+func (t *overlayTxn) Discard() {
+	if t.base != nil {
+		t.base.Release()
+		t.base = nil
+	}
+	t.writes = nil
+}
+
+// errTxnDone — Commit после Commit или Discard.
+var errTxnDone = errors.New("transaction already committed or discarded")
+
+// errTxn — транзакция, которую не удалось начать: все операции возвращают
+// исходную ошибку.
+type errTxn struct{ err error }
+
+func (t errTxn) Get([]byte) ([]byte, error)                                 { return nil, t.err }
+func (t errTxn) Iterate(_, _ []byte, _ func(key, value []byte) error) error { return t.err }
+func (t errTxn) Set(_, _ []byte) error                                      { return t.err }
+func (t errTxn) Delete([]byte) error                                        { return t.err }
+func (t errTxn) Commit() error                                              { return t.err }
+func (t errTxn) Discard()                                                   {}
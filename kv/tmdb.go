@@ -0,0 +1,157 @@
+package kv
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	dbm "github.com/tendermint/tm-db"
+)
+
+// TMStore — хранилище на движке tm-db (goleveldb, boltdb и т. д.). У tm-db
+// нет снимков: для goleveldb берутся его собственные, для остальных снимок
+// держит блокировку чтения, и запись ждёт, пока снимки не отпустят.
+// Вложенные снимки в одной горутине поэтому запрещены.
+type TMStore struct {
+	db  dbm.DB
+	ldb *leveldb.DB // только для goleveldb
+
+	mu sync.RWMutex
+}
+
+// NewTMDB оборачивает открытую базу tm-db.
+func NewTMDB(db dbm.DB) *TMStore {
+	s := &TMStore{db: db}
+	if gl, ok := db.(*dbm.GoLevelDB); ok {
+		s.ldb = gl.DB()
+	}
+	return s
+}
+
+func (s *TMStore) Snapshot() (Snapshot, error) {
+	if s.ldb != nil {
+		snap, err := s.ldb.GetSnapshot()
+		if err != nil {
+			return nil, err
+		}
+		return levelSnapshot{snap}, nil
+	}
+	s.mu.RLock()
+	return &lockedSnapshot{liveReader: liveReader{s}}, nil
+}
+
+// NewTxn для движков без снимков читает базу напрямую, без блокировки:
+// транзакция блока живёт до Commit, и блокировка остановила бы все
+// остальные записи. Такая транзакция видит чужие записи, сделанные после её
+// начала.
+func (s *TMStore) NewTxn() Txn {
+	base, err := s.bufferBase()
+	if err != nil {
+		return errTxn{err}
+	}
+	return newOverlayTxn(base, s.NewBatch)
+}
+
+func (s *TMStore) bufferBase() (Snapshot, error) {
+	if s.ldb == nil {
+		return liveReader{s}, nil
+	}
+	return s.Snapshot()
+}
+
+func (s *TMStore) NewBatch() Batch { return &tmBatch{store: s, batch: s.db.NewBatch()} }
+
+func (s *TMStore) Close() error { return s.db.Close() }
+
+// liveReader читает базу напрямую.
+type liveReader struct {
+	store *TMStore
+}
+
+func (s liveReader) Get(key []byte) ([]byte, error) {
+	v, err := s.store.db.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, ErrNotFound
+	}
+	return bytes.Clone(v), nil
+}
+
+func (s liveReader) Iterate(prefix, from []byte, fn func(key, value []byte) error) error {
+	start := iterStart(prefix, from)
+	if len(start) == 0 {
+		start = nil // tm-db не принимает пустой ключ, только nil
+	}
+	it, err := s.store.db.Iterator(start, prefixEnd(prefix))
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+	for ; it.Valid(); it.Next() {
+		if err := fn(bytes.Clone(it.Key()), bytes.Clone(it.Value())); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+func (s liveReader) Release() {}
+
+// lockedSnapshot читает базу напрямую, пока держит блокировку чтения.
+type lockedSnapshot struct {
+	liveReader
+	once sync.Once
+}
+
+func (s *lockedSnapshot) Release() { s.once.Do(s.store.mu.RUnlock) }
+
+type levelSnapshot struct {
+	snap *leveldb.Snapshot
+}
+
+func (s levelSnapshot) Get(key []byte) ([]byte, error) {
+	v, err := s.snap.Get(key, nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	return v, err
+}
+
+func (s levelSnapshot) Iterate(prefix, from []byte, fn func(key, value []byte) error) error {
+	it := s.snap.NewIterator(&util.Range{Start: iterStart(prefix, from), Limit: prefixEnd(prefix)}, nil)
+	defer it.Release()
+	for it.Next() {
+		if err := fn(bytes.Clone(it.Key()), bytes.Clone(it.Value())); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+func (s levelSnapshot) Release() { s.snap.Release() }
+
+type tmBatch struct {
+	store *TMStore
+	batch dbm.Batch
+}
+
+func (b *tmBatch) Set(key, value []byte) error {
+	if value == nil {
+		value = []byte{} // tm-db не принимает nil
+	}
+	return b.batch.Set(key, value)
+}
+
+func (b *tmBatch) Delete(key []byte) error { return b.batch.Delete(key) }
+
+func (b *tmBatch) Write() error {
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+	return b.batch.WriteSync()
+}
+
+func (b *tmBatch) Close() { b.batch.Close() }
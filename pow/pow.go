@@ -0,0 +1,81 @@
+// Package pow — доказательство работы для транзакций, подписант которых ещё
+// не зарегистрирован в реестре (регистрация коммитера и бенефициара). Токена
+// и комиссий в lbc нет, поэтому такую транзакцию нельзя ограничить квотой
+// подписанта; вместо этого клиент перебирает nonce, пока хеш не наберёт
+// Difficulty ведущих нулевых бит. Спецификация — в docs/signing.md.
+package pow
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+
+	"github.com/gregorybednov/lbc/canonical"
+)
+
+// Difficulty — число ведущих нулевых бит хеша, которое требует узел.
+// Это правило консенсуса: все узлы сети должны собираться с одним значением.
+const Difficulty = 18
+
+// Required сообщает, нужна ли работа транзакции типа kind.
+func Required(kind string) bool {
+	return kind == "commiter" || kind == "beneficiary"
+}
+
+// Hash — sha256(sign_bytes || nonce), nonce — 8 байт big-endian. sign_bytes
+// те же, что подписываются (canonical.SignBytes), поэтому работа привязана
+// к цепочке и к телу транзакции.
+func Hash(chainID, kind string, body []byte, nonce uint64) ([32]byte, error) {
+	msg, err := canonical.SignBytes(chainID, kind, body)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return hashMsg(msg, nonce), nil
+}
+
+func hashMsg(msg []byte, nonce uint64) [32]byte {
+	buf := make([]byte, len(msg)+8)
+	copy(buf, msg)
+	binary.BigEndian.PutUint64(buf[len(msg):], nonce)
+	return sha256.Sum256(buf)
+}
+
+// LeadingZeros — число ведущих нулевых бит хеша.
+func LeadingZeros(h [32]byte) int {
+	n := 0
+	for _, b := range h {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+// Check проверяет nonce и возвращает число ведущих нулевых бит (оно
+// используется как приоритет в mempool: лишняя работа поднимает транзакцию).
+func Check(chainID, kind string, body []byte, nonce uint64) (int, error) {
+	h, err := Hash(chainID, kind, body, nonce)
+	if err != nil {
+		return 0, err
+	}
+	zeros := LeadingZeros(h)
+	if zeros < Difficulty {
+		return zeros, fmt.Errorf("insufficient proof of work: %d leading zero bits, want %d", zeros, Difficulty)
+	}
+	return zeros, nil
+}
+
+// Solve перебирает nonce, пока хеш не наберёт difficulty ведущих нулевых бит.
+func Solve(chainID, kind string, body []byte, difficulty int) (uint64, error) {
+	msg, err := canonical.SignBytes(chainID, kind, body)
+	if err != nil {
+		return 0, err
+	}
+	for nonce := uint64(0); ; nonce++ {
+		if LeadingZeros(hashMsg(msg, nonce)) >= difficulty {
+			return nonce, nil
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Package reminder напоминает локальным коммитерам о приближении срока их
+// обязательств. Сервис периодически просматривает обязательства заданных
+// коммитеров и, когда до due остаётся меньше очередного lead time,
+// передаёт напоминание всем отправителям (Sender). Отметки об отправке
+// хранятся в базе ноды (local:reminder:...), поэтому перезапуск ноды не
+// повторяет уже отправленное.
+package reminder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gregorybednov/lbc/blockchain"
+	"github.com/gregorybednov/lbc/cfg"
+	"github.com/gregorybednov/lbc/kv"
+	types "github.com/gregorybednov/lbc_sdk"
+)
+
+// markTTL — сколько после due хранится отметка об отправке; более старые
+// удаляет check.
+const markTTL = 24 * time.Hour
+
+var sentPrefix = blockchain.LocalPrefix("reminder") + "sent:"
+
+// Reminder — одно напоминание о сроке обязательства.
+type Reminder struct {
+	CommitmentID string        `json:"commitment_id"`
+	CommiterID   string        `json:"commiter_id"`
+	PromiseID    string        `json:"promise_id"`
+	PromiseText  string        `json:"promise_text,omitempty"`
+	Due          time.Time     `json:"due"`
+	Lead         time.Duration `json:"-"` // сработавший lead time
+}
+
+func (r Reminder) MarshalJSON() ([]byte, error) {
+	type plain Reminder
+	return json.Marshal(struct {
+		plain
+		Lead string `json:"lead"`
+	}{plain(r), r.Lead.String()})
+}
+
+// Subject — короткая строка для темы письма.
+func (r Reminder) Subject() string {
+	return fmt.Sprintf("Срок обязательства %s — %s", r.CommitmentID, r.Due.Local().Format(time.DateTime))
+}
+
+// Text — текст напоминания для человека.
+func (r Reminder) Text() string {
+	text := r.PromiseText
+	if text == "" {
+		text = r.PromiseID
+	}
+	return fmt.Sprintf("%s: обещание «%s», срок %s (осталось %s)",
+		r.CommitmentID, text, r.Due.Local().Format(time.DateTime), time.Until(r.Due).Truncate(time.Minute))
+}
+
+type service struct {
+	app       *blockchain.PromiseApp
+	commiters []string
+	leads     []time.Duration
+	senders   []Sender
+}
+
+// Service — blockchain.Service напоминаний для коммитеров commiters (ID в
+// цепочке) по расписанию из conf.
+func Service(conf cfg.RemindersConfig, commiters []string, senders []Sender) blockchain.Service {
+	return func(ctx context.Context, app *blockchain.PromiseApp) error {
+		s := &service{app: app, commiters: commiters, leads: conf.LeadTimes, senders: senders}
+		ticker := time.NewTicker(conf.CheckInterval)
+		defer ticker.Stop()
+		for {
+			if err := s.check(ctx, time.Now()); err != nil {
+				fmt.Printf("reminders: %v\n", err)
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+// Отметка хранит срок хранения (unix) в значении.
+func sentKey(commitmentID string, lead time.Duration, sender string) []byte {
+	return []byte(fmt.Sprintf("%s%s:%d:%s", sentPrefix, commitmentID, int64(lead/time.Second), sender))
+}
+
+// due возвращает напоминания, срок которых наступил к now: для каждого
+// невыполненного обязательства — по наименьшему из пройденных lead time.
+func (s *service) due(now time.Time) ([]Reminder, error) {
+	var out []Reminder
+	err := s.app.View(func(snap *blockchain.Snapshot) error {
+		return snap.Scan("commitment:", func(v json.RawMessage) error {
+			var c types.CommitmentTxBody
+			if json.Unmarshal(v, &c) != nil || c.Due <= 0 || !slices.Contains(s.commiters, c.CommiterID) {
+				return nil
+			}
+			due := time.Unix(c.Due, 0)
+			if !now.Before(due) {
+				return nil
+			}
+			var lead time.Duration
+			for _, l := range s.leads {
+				if !now.Before(due.Add(-l)) {
+					lead = l
+				}
+			}
+			if lead == 0 {
+				return nil
+			}
+			// О выполненном обязательстве не напоминаем.
+			if p, ok, err := snap.Progress(c.ID); err != nil {
+				return err
+			} else if ok && p.Complete {
+				return nil
+			}
+			r := Reminder{CommitmentID: c.ID, CommiterID: c.CommiterID, PromiseID: c.PromiseID, Due: due, Lead: lead}
+			if raw, ok, err := snap.Get(c.PromiseID); err == nil && ok {
+				var p types.PromiseTxBody
+				if json.Unmarshal(raw, &p) == nil {
+					r.PromiseText = p.Text
+				}
+			}
+			out = append(out, r)
+			return nil
+		})
+	})
+	return out, err
+}
+
+// check отправляет наступившие напоминания. Более ранние lead time, которые
+// пришлись на простой ноды или на момент создания обязательства, не
+// догоняются: отправляется только самое близкое к сроку.
+func (s *service) check(ctx context.Context, now time.Time) error {
+	if err := s.prune(now); err != nil {
+		return err
+	}
+	reminders, err := s.due(now)
+	if err != nil {
+		return err
+	}
+	for _, r := range reminders {
+		expires := []byte(strconv.FormatInt(r.Due.Add(markTTL).Unix(), 10))
+		for _, snd := range s.senders {
+			var sent bool
+			if err := s.app.LocalView(func(txn kv.Reader) error {
+				_, err := txn.Get(sentKey(r.CommitmentID, r.Lead, snd.Name()))
+				if err == kv.ErrNotFound {
+					return nil
+				}
+				sent = err == nil
+				return err
+			}); err != nil {
+				return err
+			}
+			if sent {
+				continue
+			}
+			if err := snd.Send(ctx, r); err != nil {
+				fmt.Printf("reminders: %s sender: %s: %v\n", snd.Name(), r.CommitmentID, err)
+				continue
+			}
+			if err := s.app.LocalUpdate(func(txn kv.Txn) error {
+				return txn.Set(sentKey(r.CommitmentID, r.Lead, snd.Name()), expires)
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// prune удаляет отметки, срок хранения которых истёк.
+func (s *service) prune(now time.Time) error {
+	return s.app.LocalUpdate(func(txn kv.Txn) error {
+		var old [][]byte
+		if err := txn.Iterate([]byte(sentPrefix), nil, func(key, v []byte) error {
+			if exp, err := strconv.ParseInt(string(v), 10, 64); err != nil || exp <= now.Unix() {
+				old = append(old, key)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, key := range old {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
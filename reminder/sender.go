@@ -0,0 +1,121 @@
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/gregorybednov/lbc/cfg"
+	"github.com/gregorybednov/lbc/webhook"
+)
+
+// Sender доставляет напоминание. Name различает отправителей в отметках об
+// отправке: неудачная отправка одним из них повторяется, не дублируя
+// остальные.
+type Sender interface {
+	Name() string
+	Send(ctx context.Context, r Reminder) error
+}
+
+// Senders создаёт отправителей, перечисленных в conf.Senders.
+func Senders(conf cfg.RemindersConfig, out io.Writer) ([]Sender, error) {
+	var senders []Sender
+	for _, name := range conf.Senders {
+		switch name {
+		case "log":
+			senders = append(senders, LogSender{Out: out})
+		case "webhook":
+			senders = append(senders, &WebhookSender{URL: conf.Webhook.URL, Secret: conf.Webhook.Secret})
+		case "mail":
+			senders = append(senders, &MailSender{Addr: conf.Mail.Addr, From: conf.Mail.From, To: conf.Mail.To})
+		default:
+			return nil, fmt.Errorf("unknown reminder sender %q", name)
+		}
+	}
+	return senders, nil
+}
+
+// LogSender печатает напоминание строкой в Out.
+type LogSender struct {
+	Out io.Writer
+}
+
+func (LogSender) Name() string { return "log" }
+
+func (s LogSender) Send(_ context.Context, r Reminder) error {
+	_, err := fmt.Fprintf(s.Out, "reminder: %s\n", r.Text())
+	return err
+}
+
+// EventReminder — значение X-LBC-Event у запросов WebhookSender.
+const EventReminder = "commitment.reminder"
+
+// WebhookSender отправляет напоминание JSON-ом, подписанным так же, как
+// вебхуки ноды (см. webhook.Sign).
+type WebhookSender struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+func (*WebhookSender) Name() string { return "webhook" }
+
+func (s *WebhookSender) Send(ctx context.Context, r Reminder) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-LBC-Event", EventReminder)
+	if s.Secret != "" {
+		req.Header.Set("X-LBC-Signature", webhook.Sign(s.Secret, time.Now().Unix(), body))
+	}
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// MailSender отправляет письмо через SMTP-сервер Addr без авторизации.
+type MailSender struct {
+	Addr string
+	From string
+	To   []string
+}
+
+func (*MailSender) Name() string { return "mail" }
+
+func (s *MailSender) Send(_ context.Context, r Reminder) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", r.Subject()))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(r.Text())
+	msg.WriteString("\r\n")
+	return smtp.SendMail(s.Addr, nil, s.From, s.To, msg.Bytes())
+}
@@ -0,0 +1,309 @@
+// Package rest — HTTP/JSON-шлюз к реестру обещаний поверх RPC ноды: GET-запросы
+// переводятся в abci_query (PromiseApp.Query), POST /txs — в broadcast_tx,
+// /blobs — к хранилищу вложений ноды (blobstore).
+// Описание API — openapi.json, отдаётся по /openapi.json.
+package rest
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gregorybednov/lbc/blobstore"
+
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
+)
+
+//go:embed openapi.json
+var openAPI []byte
+
+// maxTxBytes — предел тела POST /txs, как max_tx_bytes Tendermint по умолчанию.
+const maxTxBytes = 1 << 20
+
+type Server struct {
+	node  rpcclient.ABCIClient
+	cors  string
+	mux   *http.ServeMux
+	blobs *blobstore.Fetcher
+}
+
+// New создаёт шлюз к ноде node. cors — значение Access-Control-Allow-Origin
+// (пусто — заголовок не ставится).
+func New(node rpcclient.ABCIClient, cors string) *Server {
+	s := &Server{node: node, cors: cors, mux: http.NewServeMux()}
+
+	s.mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPI)
+	})
+
+	s.mux.HandleFunc("GET /commiters", s.list("commiter"))
+	s.mux.HandleFunc("GET /commiters/{id...}", s.get("commiter"))
+	s.mux.HandleFunc("GET /beneficiaries", s.list("beneficiary"))
+	s.mux.HandleFunc("GET /beneficiaries/{id...}", s.get("beneficiary"))
+	s.mux.HandleFunc("GET /promises", s.listPromises)
+	s.mux.HandleFunc("GET /promises/{id...}", s.get("promise"))
+	s.mux.HandleFunc("GET /commitments", s.listCommitments)
+	s.mux.HandleFunc("GET /commitments/{id...}", s.get("commitment"))
+	s.mux.HandleFunc("GET /fulfillments", s.listFulfillments)
+	s.mux.HandleFunc("GET /fulfillments/{id...}", s.get("fulfillment"))
+	s.mux.HandleFunc("GET /progress/{id...}", s.getProgress)
+	s.mux.HandleFunc("GET /reputation/{id...}", s.getReputation)
+	s.mux.HandleFunc("GET /validators", s.validators)
+	s.mux.HandleFunc("POST /txs", s.postTx)
+	s.mux.HandleFunc("GET /blobs/{hash}", s.getBlob)
+	s.mux.HandleFunc("POST /blobs", s.postBlob)
+	return s
+}
+
+// WithBlobs включает GET и POST /blobs — вложения обещаний из хранилища
+// ноды (без него эти запросы отвечают 404).
+func (s *Server) WithBlobs(f *blobstore.Fetcher) *Server {
+	s.blobs = f
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.cors != "" {
+		w.Header().Set("Access-Control-Allow-Origin", s.cors)
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe обслуживает addr до отмены ctx.
+func ListenAndServe(ctx context.Context, addr string, s *Server) error {
+	srv := &http.Server{Addr: addr, Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// fullID допускает как полный ID (promise:<uuid>), так и его часть после префикса.
+func fullID(kind, id string) string {
+	if strings.HasPrefix(id, kind+":") {
+		return id
+	}
+	return kind + ":" + id
+}
+
+func (s *Server) list(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.query(w, r, "list/"+kind)
+	}
+}
+
+func (s *Server) get(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.query(w, r, "get/"+fullID(kind, r.PathValue("id")))
+	}
+}
+
+func (s *Server) listPromises(w http.ResponseWriter, r *http.Request) {
+	beneficiary := r.URL.Query().Get("beneficiary")
+	parent := r.URL.Query().Get("parent")
+	switch {
+	case beneficiary == "" && parent == "":
+		s.query(w, r, "list/promise")
+	case parent == "":
+		s.query(w, r, "rel/promises-of-beneficiary/"+fullID("beneficiary", beneficiary))
+	case beneficiary == "":
+		s.query(w, r, "rel/children/"+fullID("promise", parent))
+	default:
+		writeError(w, http.StatusBadRequest, "use either beneficiary or parent, not both")
+	}
+}
+
+func (s *Server) listCommitments(w http.ResponseWriter, r *http.Request) {
+	promise := r.URL.Query().Get("promise")
+	commiter := r.URL.Query().Get("commiter")
+	switch {
+	case promise == "" && commiter == "":
+		s.query(w, r, "list/commitment")
+	case commiter == "":
+		s.query(w, r, "rel/commitments-of-promise/"+fullID("promise", promise))
+	case promise == "":
+		s.query(w, r, "rel/commitments-of-commiter/"+fullID("commiter", commiter))
+	default:
+		writeError(w, http.StatusBadRequest, "use either promise or commiter, not both")
+	}
+}
+
+func (s *Server) listFulfillments(w http.ResponseWriter, r *http.Request) {
+	if commitment := r.URL.Query().Get("commitment"); commitment != "" {
+		s.query(w, r, "rel/fulfillments-of-commitment/"+fullID("commitment", commitment))
+		return
+	}
+	s.query(w, r, "list/fulfillment")
+}
+
+// validators отдаёт действующий набор валидаторов.
+func (s *Server) validators(w http.ResponseWriter, r *http.Request) {
+	s.query(w, r, "validators")
+}
+
+// getProgress отдаёт выполнение обязательства или обещания; ID здесь
+// полный, с префиксом: по нему и различаются виды.
+func (s *Server) getProgress(w http.ResponseWriter, r *http.Request) {
+	s.query(w, r, "progress/"+r.PathValue("id"))
+}
+
+// getReputation отдаёт исполнительность коммитера.
+func (s *Server) getReputation(w http.ResponseWriter, r *http.Request) {
+	s.query(w, r, "reputation/"+fullID("commiter", r.PathValue("id")))
+}
+
+func (s *Server) query(w http.ResponseWriter, r *http.Request, path string) {
+	res, err := s.node.ABCIQuery(r.Context(), path, nil)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	switch res.Response.Code {
+	case 0:
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(res.Response.Value)
+	case 2: // см. PromiseApp.Query
+		writeError(w, http.StatusNotFound, res.Response.Log)
+	default:
+		writeError(w, http.StatusBadRequest, res.Response.Log)
+	}
+}
+
+type txResult struct {
+	Hash   string `json:"hash"`
+	Code   uint32 `json:"code"`
+	Log    string `json:"log,omitempty"`
+	Height int64  `json:"height,omitempty"`
+}
+
+// postTx принимает транзакцию как есть — JSON-конверт или бинарную (codec) —
+// и отправляет её в режиме ?mode=sync|async|commit (по умолчанию sync).
+func (s *Server) postTx(w http.ResponseWriter, r *http.Request) {
+	tx, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxTxBytes))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	if len(tx) == 0 {
+		writeError(w, http.StatusBadRequest, "empty transaction")
+		return
+	}
+
+	var out txResult
+	status := http.StatusAccepted
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "", "sync":
+		res, err := s.node.BroadcastTxSync(r.Context(), tx)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		out = txResult{Hash: res.Hash.String(), Code: res.Code, Log: res.Log}
+	case "async":
+		res, err := s.node.BroadcastTxAsync(r.Context(), tx)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		out = txResult{Hash: res.Hash.String()}
+	case "commit":
+		res, err := s.node.BroadcastTxCommit(r.Context(), tx)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		out = txResult{Hash: res.Hash.String(), Code: res.CheckTx.Code, Log: res.CheckTx.Log}
+		if res.CheckTx.Code == 0 {
+			out = txResult{Hash: res.Hash.String(), Code: res.DeliverTx.Code, Log: res.DeliverTx.Log, Height: res.Height}
+		}
+		status = http.StatusOK
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown mode %q", mode))
+		return
+	}
+	if out.Code != 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	writeJSON(w, status, out)
+}
+
+// getBlob отдаёт вложение; если у ноды его нет, оно запрашивается у пиров.
+func (s *Server) getBlob(w http.ResponseWriter, r *http.Request) {
+	if s.blobs == nil {
+		writeError(w, http.StatusNotFound, "blob store is disabled")
+		return
+	}
+	hash := r.PathValue("hash")
+	if _, err := blobstore.ParseHash(hash); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	body, size, err := s.blobs.Get(r.Context(), hash)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	defer body.Close()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	io.Copy(w, body)
+}
+
+// postBlob сохраняет тело запроса в хранилище ноды и возвращает его хеш
+// для поля attachments обещания.
+func (s *Server) postBlob(w http.ResponseWriter, r *http.Request) {
+	if s.blobs == nil {
+		writeError(w, http.StatusNotFound, "blob store is disabled")
+		return
+	}
+	// Store.Put и сам не читает больше предела, но MaxBytesReader ещё и
+	// закрывает соединение, не дочитывая тело.
+	hash, err := s.blobs.Put(http.MaxBytesReader(w, r.Body, s.blobs.MaxSize()))
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge) || errors.Is(err, blobstore.ErrTooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	case errors.Is(err, blobstore.ErrQuota):
+		writeError(w, http.StatusInsufficientStorage, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"hash": hash})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,193 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gregorybednov/lbc/blobstore"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmbytes "github.com/tendermint/tendermint/libs/bytes"
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+)
+
+func TestPostBlob(t *testing.T) {
+	tests := []struct {
+		name   string
+		quota  int64
+		bodies []string
+		want   []int
+	}{
+		{"stored", 0, []string{"1234567890"}, []int{http.StatusCreated}},
+		{"too large", 0, []string{"12345678901"}, []int{http.StatusRequestEntityTooLarge}},
+		{"quota", 15, []string{"1234567890", "abcdefghij"}, []int{http.StatusCreated, http.StatusInsufficientStorage}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := blobstore.Open(t.TempDir(), 10, tt.quota)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := New(nil, "").WithBlobs(blobstore.NewFetcher(store, 0))
+			for i, body := range tt.bodies {
+				rec := httptest.NewRecorder()
+				s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/blobs", strings.NewReader(body)))
+				if rec.Code != tt.want[i] {
+					t.Errorf("POST %q: %d %s, want %d", body, rec.Code, rec.Body, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPostBlobDisabled(t *testing.T) {
+	rec := httptest.NewRecorder()
+	New(nil, "").ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/blobs", strings.NewReader("x")))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("got %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+// fakeNode — нода, отвечающая заданным abci_query и результатом broadcast;
+// запоминает, какие пути и в каком режиме к ней обращались.
+type fakeNode struct {
+	rpcclient.ABCIClient
+	query  abci.ResponseQuery
+	check  abci.ResponseCheckTx
+	result abci.ResponseDeliverTx
+	paths  []string
+	mode   string
+}
+
+func (n *fakeNode) ABCIQuery(_ context.Context, path string, _ tmbytes.HexBytes) (*ctypes.ResultABCIQuery, error) {
+	n.paths = append(n.paths, path)
+	return &ctypes.ResultABCIQuery{Response: n.query}, nil
+}
+
+func (n *fakeNode) BroadcastTxSync(_ context.Context, tx tmtypes.Tx) (*ctypes.ResultBroadcastTx, error) {
+	n.mode = "sync"
+	return &ctypes.ResultBroadcastTx{Hash: tx.Hash(), Code: n.check.Code, Log: n.check.Log}, nil
+}
+
+func (n *fakeNode) BroadcastTxAsync(_ context.Context, tx tmtypes.Tx) (*ctypes.ResultBroadcastTx, error) {
+	n.mode = "async"
+	return &ctypes.ResultBroadcastTx{Hash: tx.Hash()}, nil
+}
+
+func (n *fakeNode) BroadcastTxCommit(_ context.Context, tx tmtypes.Tx) (*ctypes.ResultBroadcastTxCommit, error) {
+	n.mode = "commit"
+	return &ctypes.ResultBroadcastTxCommit{Hash: tx.Hash(), CheckTx: n.check, DeliverTx: n.result, Height: 7}, nil
+}
+
+func TestListPromisesQuery(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string // путь abci_query; пусто — запроса быть не должно
+		code int
+	}{
+		{"/promises", "list/promise", http.StatusOK},
+		{"/promises?beneficiary=1", "rel/promises-of-beneficiary/beneficiary:1", http.StatusOK},
+		{"/promises?beneficiary=beneficiary:1", "rel/promises-of-beneficiary/beneficiary:1", http.StatusOK},
+		{"/promises?parent=2", "rel/children/promise:2", http.StatusOK},
+		{"/promises?parent=promise:2", "rel/children/promise:2", http.StatusOK},
+		{"/promises?beneficiary=1&parent=2", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			node := &fakeNode{query: abci.ResponseQuery{Value: []byte("[]")}}
+			rec := httptest.NewRecorder()
+			New(node, "").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if rec.Code != tt.code {
+				t.Errorf("got %d %s, want %d", rec.Code, rec.Body, tt.code)
+			}
+			var want []string
+			if tt.want != "" {
+				want = []string{tt.want}
+			}
+			if !reflect.DeepEqual(node.paths, want) {
+				t.Errorf("queried %q, want %q", node.paths, want)
+			}
+		})
+	}
+}
+
+func TestQueryStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		res  abci.ResponseQuery
+		code int
+		body string
+	}{
+		{"found", abci.ResponseQuery{Value: []byte(`{"id":"promise:1"}`)}, http.StatusOK, `{"id":"promise:1"}`},
+		{"not found", abci.ResponseQuery{Code: 2, Log: "not found"}, http.StatusNotFound, "not found"},
+		{"error", abci.ResponseQuery{Code: 1, Log: "unsupported query"}, http.StatusBadRequest, "unsupported query"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &fakeNode{query: tt.res}
+			rec := httptest.NewRecorder()
+			New(node, "").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/promises/1", nil))
+			if rec.Code != tt.code || !strings.Contains(rec.Body.String(), tt.body) {
+				t.Errorf("got %d %s, want %d %s", rec.Code, rec.Body, tt.code, tt.body)
+			}
+			if want := []string{"get/promise:1"}; !reflect.DeepEqual(node.paths, want) {
+				t.Errorf("queried %q, want %q", node.paths, want)
+			}
+		})
+	}
+}
+
+func TestPostTx(t *testing.T) {
+	rejected := abci.ResponseCheckTx{Code: 8, Log: "rate limit"}
+	tests := []struct {
+		name     string
+		mode     string
+		check    abci.ResponseCheckTx
+		result   abci.ResponseDeliverTx
+		wantMode string
+		status   int
+		code     uint32
+	}{
+		{"default", "", abci.ResponseCheckTx{}, abci.ResponseDeliverTx{}, "sync", http.StatusAccepted, 0},
+		{"sync", "sync", abci.ResponseCheckTx{}, abci.ResponseDeliverTx{}, "sync", http.StatusAccepted, 0},
+		{"sync rejected", "sync", rejected, abci.ResponseDeliverTx{}, "sync", http.StatusUnprocessableEntity, 8},
+		{"async", "async", rejected, abci.ResponseDeliverTx{}, "async", http.StatusAccepted, 0},
+		{"commit", "commit", abci.ResponseCheckTx{}, abci.ResponseDeliverTx{}, "commit", http.StatusOK, 0},
+		{"commit check rejected", "commit", rejected, abci.ResponseDeliverTx{}, "commit", http.StatusUnprocessableEntity, 8},
+		{"commit deliver rejected", "commit", abci.ResponseCheckTx{}, abci.ResponseDeliverTx{Code: 2, Log: "bad"}, "commit", http.StatusUnprocessableEntity, 2},
+		{"unknown mode", "later", abci.ResponseCheckTx{}, abci.ResponseDeliverTx{}, "", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &fakeNode{check: tt.check, result: tt.result}
+			rec := httptest.NewRecorder()
+			New(node, "").ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/txs?mode="+tt.mode, strings.NewReader(`{"body":{}}`)))
+			if rec.Code != tt.status {
+				t.Fatalf("got %d %s, want %d", rec.Code, rec.Body, tt.status)
+			}
+			if node.mode != tt.wantMode {
+				t.Errorf("broadcast mode %q, want %q", node.mode, tt.wantMode)
+			}
+			if tt.status == http.StatusBadRequest {
+				return
+			}
+			var out txResult
+			if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+				t.Fatal(err)
+			}
+			if out.Code != tt.code || out.Hash == "" {
+				t.Errorf("result %+v, want code %d", out, tt.code)
+			}
+			if tt.wantMode == "commit" && tt.code == 0 && out.Height != 7 {
+				t.Errorf("height %d, want 7", out.Height)
+			}
+		})
+	}
+}
@@ -0,0 +1,351 @@
+// Package sealed — зашифрованный текст обещания. Вместо открытого text в
+// транзакцию кладётся строка Prefix + base64(JSON Envelope): текст
+// шифруется случайным ключом содержимого (XChaCha20-Poly1305), а ключ —
+// для каждого получателя через X25519 с его ed25519-ключом коммитера.
+// На цепочке остаётся хеш sha256(salt || text): соль известна только
+// получателям, поэтому хеш не перебирается по словарю, а раскрытие
+// (Disclosure) можно проверить по нему, не раскрывая ключей.
+//
+// Узел ключей не знает и проверяет только форму конверта (Parse).
+package sealed
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+)
+
+// Prefix отличает зашифрованный текст от открытого.
+const Prefix = "lbc-sealed:v1:"
+
+const (
+	keySize  = chacha20poly1305.KeySize
+	saltSize = 16
+	// MaxRecipients ограничивает размер конверта в транзакции.
+	MaxRecipients = 32
+)
+
+// Envelope — зашифрованный текст. Все двоичные поля — base64.
+type Envelope struct {
+	// Hash — hex(sha256(salt || text)), см. Disclosure.Verify.
+	Hash string `json:"hash"`
+	// Ephemeral — разовый открытый ключ X25519 отправителя.
+	Ephemeral  string      `json:"ephemeral"`
+	Nonce      string      `json:"nonce"`
+	Ciphertext string      `json:"ciphertext"`
+	Recipients []Recipient `json:"recipients"`
+}
+
+// Recipient — ключ содержимого, зашифрованный для одного получателя.
+type Recipient struct {
+	PubKey  string `json:"pub_key"` // base64 ed25519 получателя
+	Wrapped string `json:"wrapped"`
+}
+
+// payload — то, что шифруется ключом содержимого.
+type payload struct {
+	Salt []byte `json:"salt"`
+	Text string `json:"text"`
+}
+
+// Disclosure — раскрытый текст: его можно передать третьей стороне, и она
+// сверит его с хешем на цепочке.
+type Disclosure struct {
+	PromiseID string `json:"promise_id"`
+	Text      string `json:"text"`
+	Salt      string `json:"salt"` // base64
+}
+
+// IsSealed сообщает, зашифрован ли текст обещания.
+func IsSealed(text string) bool { return strings.HasPrefix(text, Prefix) }
+
+// Seal шифрует text для получателей.
+func Seal(text string, recipients []ed25519.PublicKey) (string, error) {
+	if len(recipients) == 0 {
+		return "", errors.New("sealed: no recipients")
+	}
+	if len(recipients) > MaxRecipients {
+		return "", fmt.Errorf("sealed: more than %d recipients", MaxRecipients)
+	}
+	salt := make([]byte, saltSize)
+	contentKey := make([]byte, keySize)
+	ephPriv := make([]byte, curve25519.ScalarSize)
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	for _, b := range [][]byte{salt, contentKey, ephPriv, nonce} {
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+	}
+	ephPub, err := curve25519.X25519(ephPriv, curve25519.Basepoint)
+	if err != nil {
+		return "", err
+	}
+
+	hash := contentHash(salt, text)
+	env := Envelope{
+		Hash:      hex.EncodeToString(hash),
+		Ephemeral: base64.StdEncoding.EncodeToString(ephPub),
+		Nonce:     base64.StdEncoding.EncodeToString(nonce),
+	}
+	seen := map[string]bool{}
+	for _, pub := range recipients {
+		b64 := base64.StdEncoding.EncodeToString(pub)
+		if seen[b64] {
+			continue
+		}
+		seen[b64] = true
+		xpub, err := x25519Public(pub)
+		if err != nil {
+			return "", err
+		}
+		shared, err := curve25519.X25519(ephPriv, xpub)
+		if err != nil {
+			return "", err
+		}
+		wrapped, err := wrapAEAD(shared, ephPub, pub)
+		if err != nil {
+			return "", err
+		}
+		env.Recipients = append(env.Recipients, Recipient{
+			PubKey:  b64,
+			Wrapped: base64.StdEncoding.EncodeToString(wrapped.Seal(nil, make([]byte, chacha20poly1305.NonceSize), contentKey, nil)),
+		})
+	}
+
+	data, err := json.Marshal(payload{Salt: salt, Text: text})
+	if err != nil {
+		return "", err
+	}
+	aead, err := chacha20poly1305.NewX(contentKey)
+	if err != nil {
+		return "", err
+	}
+	// Хеш входит в AAD: подменить его, не ломая шифротекст, нельзя.
+	env.Ciphertext = base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, data, hash))
+
+	raw, err := json.Marshal(env)
+	if err != nil {
+		return "", err
+	}
+	return Prefix + base64.StdEncoding.EncodeToString(raw), nil
+}
+
+// Parse разбирает зашифрованный текст и проверяет форму конверта: длины
+// полей, ключи получателей и отсутствие повторов. Расшифровать его узел не
+// может, поэтому больше ничего проверить нельзя.
+func Parse(text string) (*Envelope, error) {
+	if !IsSealed(text) {
+		return nil, errors.New("sealed: text is not sealed")
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(text, Prefix))
+	if err != nil {
+		return nil, errors.New("sealed: invalid base64")
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	var env Envelope
+	if err := dec.Decode(&env); err != nil {
+		return nil, fmt.Errorf("sealed: invalid envelope: %w", err)
+	}
+
+	if h, err := hex.DecodeString(env.Hash); err != nil || len(h) != sha256.Size {
+		return nil, errors.New("sealed: hash must be hex sha256")
+	}
+	if err := wantLen("ephemeral", env.Ephemeral, curve25519.PointSize); err != nil {
+		return nil, err
+	}
+	if err := wantLen("nonce", env.Nonce, chacha20poly1305.NonceSizeX); err != nil {
+		return nil, err
+	}
+	ct, err := base64.StdEncoding.DecodeString(env.Ciphertext)
+	if err != nil || len(ct) < chacha20poly1305.Overhead {
+		return nil, errors.New("sealed: invalid ciphertext")
+	}
+	if len(env.Recipients) == 0 || len(env.Recipients) > MaxRecipients {
+		return nil, fmt.Errorf("sealed: need 1 to %d recipients", MaxRecipients)
+	}
+	seen := map[string]bool{}
+	for _, r := range env.Recipients {
+		pub, err := base64.StdEncoding.DecodeString(r.PubKey)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			return nil, errors.New("sealed: invalid recipient pub_key")
+		}
+		if _, err := x25519Public(pub); err != nil {
+			return nil, err
+		}
+		if seen[r.PubKey] {
+			return nil, fmt.Errorf("sealed: duplicate recipient %s", r.PubKey)
+		}
+		seen[r.PubKey] = true
+		if err := wantLen("wrapped key", r.Wrapped, keySize+chacha20poly1305.Overhead); err != nil {
+			return nil, err
+		}
+	}
+	return &env, nil
+}
+
+// HasRecipient сообщает, может ли владелец ключа pub расшифровать текст.
+func (env *Envelope) HasRecipient(pub string) bool {
+	for _, r := range env.Recipients {
+		if r.PubKey == pub {
+			return true
+		}
+	}
+	return false
+}
+
+// Open расшифровывает текст ключом получателя и возвращает раскрытие.
+func Open(text string, priv ed25519.PrivateKey) (*Disclosure, error) {
+	env, err := Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	pub := priv.Public().(ed25519.PublicKey)
+	b64 := base64.StdEncoding.EncodeToString(pub)
+	var wrappedB64 string
+	for _, r := range env.Recipients {
+		if r.PubKey == b64 {
+			wrappedB64 = r.Wrapped
+		}
+	}
+	if wrappedB64 == "" {
+		return nil, fmt.Errorf("sealed: %s is not a recipient", b64)
+	}
+
+	ephPub, _ := base64.StdEncoding.DecodeString(env.Ephemeral)
+	shared, err := curve25519.X25519(x25519Private(priv), ephPub)
+	if err != nil {
+		return nil, err
+	}
+	wrap, err := wrapAEAD(shared, ephPub, pub)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, _ := base64.StdEncoding.DecodeString(wrappedB64)
+	contentKey, err := wrap.Open(nil, make([]byte, chacha20poly1305.NonceSize), wrapped, nil)
+	if err != nil {
+		return nil, errors.New("sealed: cannot unwrap content key")
+	}
+
+	aead, err := chacha20poly1305.NewX(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	hash, _ := hex.DecodeString(env.Hash)
+	nonce, _ := base64.StdEncoding.DecodeString(env.Nonce)
+	ct, _ := base64.StdEncoding.DecodeString(env.Ciphertext)
+	data, err := aead.Open(nil, nonce, ct, hash)
+	if err != nil {
+		return nil, errors.New("sealed: cannot decrypt text")
+	}
+	var p payload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("sealed: invalid payload: %w", err)
+	}
+	d := &Disclosure{Text: p.Text, Salt: base64.StdEncoding.EncodeToString(p.Salt)}
+	if err := d.Verify(text); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Verify сверяет раскрытие с хешем зашифрованного текста с цепочки.
+func (d *Disclosure) Verify(text string) error {
+	env, err := Parse(text)
+	if err != nil {
+		return err
+	}
+	salt, err := base64.StdEncoding.DecodeString(d.Salt)
+	if err != nil {
+		return errors.New("sealed: invalid salt")
+	}
+	if hex.EncodeToString(contentHash(salt, d.Text)) != env.Hash {
+		return errors.New("sealed: disclosed text does not match the hash")
+	}
+	return nil
+}
+
+func contentHash(salt []byte, text string) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(text))
+	return h.Sum(nil)
+}
+
+func wantLen(name, b64 string, n int) error {
+	b, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil || len(b) != n {
+		return fmt.Errorf("sealed: %s must be %d bytes", name, n)
+	}
+	return nil
+}
+
+// wrapAEAD — шифр ключа содержимого для одного получателя; ключ выводится
+// из общего секрета X25519 и обоих открытых ключей.
+func wrapAEAD(shared, ephPub []byte, recipient ed25519.PublicKey) (cipher.AEAD, error) {
+	h := sha256.New()
+	h.Write([]byte(Prefix))
+	h.Write(shared)
+	h.Write(ephPub)
+	h.Write(recipient)
+	return chacha20poly1305.New(h.Sum(nil))
+}
+
+// p25519 = 2^255 - 19.
+var p25519 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+
+// x25519Public переводит ed25519-ключ в X25519: u = (1 + y) / (1 - y) mod p
+// (как crypto_sign_ed25519_pk_to_curve25519 в libsodium). Ключ открытый,
+// поэтому арифметика на math/big допустима.
+func x25519Public(pub ed25519.PublicKey) ([]byte, error) {
+	if len(pub) != ed25519.PublicKeySize {
+		return nil, errors.New("sealed: invalid ed25519 key")
+	}
+	le := make([]byte, len(pub))
+	copy(le, pub)
+	le[31] &= 0x7f // бит знака x
+	y := new(big.Int).SetBytes(reverse(le))
+	if y.Cmp(p25519) >= 0 {
+		return nil, errors.New("sealed: invalid ed25519 key")
+	}
+	one := big.NewInt(1)
+	den := new(big.Int).Sub(one, y)
+	den.Mod(den, p25519)
+	if den.Sign() == 0 {
+		return nil, errors.New("sealed: invalid ed25519 key")
+	}
+	u := new(big.Int).Add(one, y)
+	u.Mul(u, new(big.Int).ModInverse(den, p25519))
+	u.Mod(u, p25519)
+
+	out := make([]byte, curve25519.PointSize)
+	u.FillBytes(out)
+	return reverse(out), nil
+}
+
+// x25519Private — скаляр X25519 того же ключа: первая половина sha512(seed),
+// как в самом ed25519 (ограничение битов делает X25519).
+func x25519Private(priv ed25519.PrivateKey) []byte {
+	h := sha512.Sum512(priv.Seed())
+	return h[:curve25519.ScalarSize]
+}
+
+func reverse(b []byte) []byte {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return b
+}
@@ -0,0 +1,189 @@
+package stream
+
+import (
+	"encoding/json"
+
+	"github.com/gregorybednov/lbc/blockchain"
+	"github.com/gregorybednov/lbc/codec"
+	types "github.com/gregorybednov/lbc_sdk"
+)
+
+// Event — доменное событие: запись, созданная в зафиксированном блоке.
+type Event struct {
+	Type   string          `json:"type"`
+	Height int64           `json:"height"`
+	ID     string          `json:"id"`
+	Record json.RawMessage `json:"record"`
+}
+
+var eventTypes = map[string]string{
+	"commiter":    "commiter_registered",
+	"beneficiary": "beneficiary_registered",
+	"promise":     "promise_created",
+	"commitment":  "commitment_created",
+	"fulfillment": "fulfillment_recorded",
+}
+
+// maxPromiseDepth ограничивает подъём по parent_promise_id при проверке
+// поддерева.
+const maxPromiseDepth = 1024
+
+// Filter отбирает события; пустое поле не ограничивает, заданные
+// объединяются по И.
+//
+//   - Commiter: регистрация коммитера, его обязательства и обещания, данные
+//     вместе с его обязательством;
+//   - Beneficiary: регистрация бенефициара, его обещания и обязательства по ним;
+//   - Promise: обещание, все его потомки и обязательства по ним.
+//
+// Отметка о выполнении проходит фильтр вместе со своим обязательством.
+type Filter struct {
+	Commiter    string
+	Beneficiary string
+	Promise     string
+}
+
+// blockView — записи блока и снимок базы для поиска связанных записей.
+type blockView struct {
+	snap      *blockchain.Snapshot
+	promises  map[string]*types.PromiseTxBody
+	byPromise map[string][]*types.CommitmentTxBody
+}
+
+func (v *blockView) promise(id string) *types.PromiseTxBody {
+	if p, ok := v.promises[id]; ok {
+		return p
+	}
+	raw, ok, err := v.snap.Get(id)
+	if err != nil || !ok {
+		return nil
+	}
+	var p types.PromiseTxBody
+	if json.Unmarshal(raw, &p) != nil {
+		return nil
+	}
+	v.promises[id] = &p
+	return &p
+}
+
+// inSubtree сообщает, является ли promiseID корнем root или его потомком.
+func (v *blockView) inSubtree(promiseID, root string) bool {
+	id := promiseID
+	for i := 0; i < maxPromiseDepth && id != ""; i++ {
+		if id == root {
+			return true
+		}
+		p := v.promise(id)
+		if p == nil || p.ParentPromiseID == nil {
+			return false
+		}
+		id = *p.ParentPromiseID
+	}
+	return false
+}
+
+func (f Filter) empty() bool {
+	return f.Commiter == "" && f.Beneficiary == "" && f.Promise == ""
+}
+
+// Apply переводит событие блока в доменные события, прошедшие фильтр.
+func (f Filter) Apply(snap *blockchain.Snapshot, ev blockchain.BlockEvent) []Event {
+	v := &blockView{
+		snap:      snap,
+		promises:  map[string]*types.PromiseTxBody{},
+		byPromise: map[string][]*types.CommitmentTxBody{},
+	}
+	for _, r := range ev.Records {
+		switch r.Kind {
+		case "promise":
+			var p types.PromiseTxBody
+			if json.Unmarshal(r.Value, &p) == nil {
+				v.promises[p.ID] = &p
+			}
+		case "commitment":
+			var c types.CommitmentTxBody
+			if json.Unmarshal(r.Value, &c) == nil {
+				v.byPromise[c.PromiseID] = append(v.byPromise[c.PromiseID], &c)
+			}
+		}
+	}
+
+	var out []Event
+	for _, r := range ev.Records {
+		typ, ok := eventTypes[r.Kind]
+		if !ok {
+			continue
+		}
+		if !f.empty() && !f.match(v, r) {
+			continue
+		}
+		out = append(out, Event{Type: typ, Height: ev.Height, ID: r.ID, Record: r.Value})
+	}
+	return out
+}
+
+func (f Filter) match(v *blockView, r blockchain.Record) bool {
+	switch r.Kind {
+	case "commiter":
+		return f.Beneficiary == "" && f.Promise == "" && r.ID == f.Commiter
+	case "beneficiary":
+		return f.Commiter == "" && f.Promise == "" && r.ID == f.Beneficiary
+	case "promise":
+		p := v.promises[r.ID]
+		if p == nil {
+			return false
+		}
+		if f.Beneficiary != "" && p.BeneficiaryID != f.Beneficiary {
+			return false
+		}
+		if f.Promise != "" && !v.inSubtree(p.ID, f.Promise) {
+			return false
+		}
+		if f.Commiter != "" {
+			for _, c := range v.byPromise[p.ID] {
+				if c.CommiterID == f.Commiter {
+					return true
+				}
+			}
+			return false
+		}
+		return true
+	case "commitment":
+		var c types.CommitmentTxBody
+		if json.Unmarshal(r.Value, &c) != nil {
+			return false
+		}
+		return f.matchCommitment(v, &c)
+	case "fulfillment":
+		var ff codec.Fulfillment
+		if json.Unmarshal(r.Value, &ff) != nil {
+			return false
+		}
+		raw, ok, err := v.snap.Get(ff.CommitmentID)
+		if err != nil || !ok {
+			return false
+		}
+		var c types.CommitmentTxBody
+		if json.Unmarshal(raw, &c) != nil {
+			return false
+		}
+		return f.matchCommitment(v, &c)
+	}
+	return false
+}
+
+func (f Filter) matchCommitment(v *blockView, c *types.CommitmentTxBody) bool {
+	if f.Commiter != "" && c.CommiterID != f.Commiter {
+		return false
+	}
+	if f.Beneficiary != "" {
+		p := v.promise(c.PromiseID)
+		if p == nil || p.BeneficiaryID != f.Beneficiary {
+			return false
+		}
+	}
+	if f.Promise != "" && !v.inSubtree(c.PromiseID, f.Promise) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,263 @@
+// Package stream — поток доменных событий реестра (регистрации, новые
+// обещания и обязательства) по мере фиксации блоков, через Server-Sent
+// Events или WebSocket. Курсор — высота блока: поток можно продолжить с
+// места обрыва.
+//
+//	GET /events?beneficiary=<id>&commiter=<id>&promise=<id>&since=<height>
+//
+// SSE: каждое событие — event: <type>, data: <Event>; последнее событие
+// блока несёт id: <height>, поэтому браузерный EventSource сам продолжает
+// с Last-Event-ID. WebSocket (тот же путь): по сообщению
+// {"height": h, "events": [...]} на блок.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gregorybednov/lbc/blockchain"
+
+	"github.com/gorilla/websocket"
+)
+
+// keepAlive — период пустых сообщений, чтобы прокси не рвали тихие потоки.
+const keepAlive = 30 * time.Second
+
+// errLagged — подписчик отстал от блоков; клиент переподключается с курсором.
+var errLagged = errors.New("subscriber lagged behind, reconnect with the last height")
+
+type Server struct {
+	app      *blockchain.PromiseApp
+	upgrader websocket.Upgrader
+}
+
+// New — сервер потока; WebSocket принимается с того же хоста и с источников
+// origins (см. CheckOrigin).
+func New(app *blockchain.PromiseApp, origins []string) *Server {
+	return &Server{app: app, upgrader: websocket.Upgrader{CheckOrigin: CheckOrigin(origins)}}
+}
+
+// Service — blockchain.Service, обслуживающий /events на addr.
+func Service(addr string, origins []string) blockchain.Service {
+	return func(ctx context.Context, app *blockchain.PromiseApp) error {
+		mux := http.NewServeMux()
+		mux.Handle("/events", New(app, origins))
+		srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			srv.Shutdown(shutdownCtx)
+		}()
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
+
+// fullID допускает как полный ID, так и часть после префикса вида.
+func fullID(kind, id string) string {
+	if id == "" || strings.HasPrefix(id, kind+":") {
+		return id
+	}
+	return kind + ":" + id
+}
+
+// parseRequest читает фильтр и курсор. since < 0 — только новые блоки.
+func parseRequest(r *http.Request) (Filter, int64, error) {
+	q := r.URL.Query()
+	f := Filter{
+		Commiter:    fullID("commiter", q.Get("commiter")),
+		Beneficiary: fullID("beneficiary", q.Get("beneficiary")),
+		Promise:     fullID("promise", q.Get("promise")),
+	}
+	cursor := q.Get("since")
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		cursor = id
+	}
+	if cursor == "" {
+		return f, -1, nil
+	}
+	since, err := strconv.ParseInt(cursor, 10, 64)
+	if err != nil || since < 0 {
+		return f, 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return f, since, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	f, since, err := parseRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if websocket.IsWebSocketUpgrade(r) {
+		s.serveWS(w, r, f, since)
+		return
+	}
+	s.serveSSE(w, r, f, since)
+}
+
+// run отдаёт emit события блоков выше since (сначала сохранённые, затем
+// новые) до отмены ctx. Пустые после фильтра блоки пропускаются; tick
+// вызывается при простое для keep-alive.
+func (s *Server) run(ctx context.Context, f Filter, since int64, emit func(height int64, events []Event) error, tick func() error) error {
+	// Подписка до чтения истории, чтобы не потерять блоки между ними.
+	live, cancel := s.app.Subscribe()
+	defer cancel()
+
+	deliver := func(ev blockchain.BlockEvent) error {
+		var events []Event
+		if err := s.app.View(func(snap *blockchain.Snapshot) error {
+			events = f.Apply(snap, ev)
+			return nil
+		}); err != nil {
+			return err
+		}
+		since = ev.Height
+		if len(events) == 0 {
+			return nil
+		}
+		return emit(ev.Height, events)
+	}
+
+	if since >= 0 {
+		if err := s.app.EventsSince(since, deliver); err != nil {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := tick(); err != nil {
+				return err
+			}
+		case ev, ok := <-live:
+			if !ok {
+				return errLagged
+			}
+			if since >= 0 && ev.Height <= since {
+				continue
+			}
+			if err := deliver(ev); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) serveSSE(w http.ResponseWriter, r *http.Request, f Filter, since int64) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	emit := func(height int64, events []Event) error {
+		for i, ev := range events {
+			data, err := json.Marshal(ev)
+			if err != nil {
+				return err
+			}
+			if i == len(events)-1 {
+				fmt.Fprintf(w, "id: %d\n", height)
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data); err != nil {
+				return err
+			}
+		}
+		flusher.Flush()
+		return nil
+	}
+	tick := func() error {
+		if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+	if err := s.run(r.Context(), f, since, emit, tick); err != nil {
+		fmt.Fprintf(w, "event: error\ndata: %q\n\n", err.Error())
+		flusher.Flush()
+	}
+}
+
+// CheckOrigin разрешает WebSocket без заголовка Origin (не из браузера), с
+// того же хоста и с источников из origins (вида https://app.example); "*" в
+// origins разрешает любой источник. Иначе чужая страница могла бы читать
+// поток от имени браузера пользователя.
+func CheckOrigin(origins []string) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, o := range origins {
+			if o == "*" || strings.EqualFold(o, origin) {
+				return true
+			}
+		}
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+}
+
+type wsBlock struct {
+	Height int64   `json:"height"`
+	Events []Event `json:"events"`
+}
+
+func (s *Server) serveWS(w http.ResponseWriter, r *http.Request, f Filter, since int64) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	// Входящие сообщения не ожидаются; чтение нужно, чтобы заметить закрытие.
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	emit := func(height int64, events []Event) error {
+		return conn.WriteJSON(wsBlock{Height: height, Events: events})
+	}
+	tick := func() error {
+		return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second))
+	}
+	err = s.run(ctx, f, since, emit, tick)
+	code, reason := websocket.CloseNormalClosure, ""
+	if err != nil {
+		code, reason = websocket.CloseTryAgainLater, err.Error()
+	}
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+}
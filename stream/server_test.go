@@ -0,0 +1,36 @@
+package stream
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		origin  string
+		origins []string
+		want    bool
+	}{
+		{"no origin", "", nil, true},
+		{"same host", "http://node.example:8082", nil, true},
+		{"same host, other case", "http://NODE.example:8082", nil, true},
+		{"other host", "https://evil.example", nil, false},
+		{"other port", "http://node.example:9000", nil, false},
+		{"allowed", "https://app.example", []string{"https://app.example"}, true},
+		{"not in list", "https://evil.example", []string{"https://app.example"}, false},
+		{"wildcard", "https://evil.example", []string{"*"}, true},
+		{"malformed", "://", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://node.example:8082/events", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if got := CheckOrigin(tt.origins)(r); got != tt.want {
+				t.Errorf("CheckOrigin(%v) for %q = %v, want %v", tt.origins, tt.origin, got, tt.want)
+			}
+		})
+	}
+}
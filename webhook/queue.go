@@ -0,0 +1,164 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gregorybednov/lbc/blockchain"
+	"github.com/gregorybednov/lbc/kv"
+)
+
+// Статусы доставки.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// doneTTL — сколько хранятся завершённые доставки (для status); более
+// старые удаляет dispatch.
+const doneTTL = 7 * 24 * time.Hour
+
+var (
+	prefix        = blockchain.LocalPrefix("webhook")
+	queuePrefix   = prefix + "q:"
+	cursorKey     = []byte(prefix + "cursor")
+	overduePrefix = prefix + "overdue:"
+)
+
+// Delivery — одна доставка события одному получателю; хранится в базе
+// ноды под local:webhook:q:<id>, id упорядочены по времени создания.
+type Delivery struct {
+	ID          string          `json:"id"`
+	URL         string          `json:"url"`
+	Event       string          `json:"event"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt int64           `json:"next_attempt"` // unix
+	LastError   string          `json:"last_error,omitempty"`
+	CreatedAt   int64           `json:"created_at"`
+	UpdatedAt   int64           `json:"updated_at"`
+}
+
+func newDeliveryID(now time.Time) string {
+	var b [4]byte
+	rand.Read(b[:])
+	return fmt.Sprintf("%020d-%s", now.UnixNano(), hex.EncodeToString(b[:]))
+}
+
+func queueKey(id string) []byte { return []byte(queuePrefix + id) }
+
+func putDelivery(txn kv.Writer, d *Delivery) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return txn.Set(queueKey(d.ID), data)
+}
+
+// expired сообщает, что завершённая доставка старше doneTTL.
+func (d *Delivery) expired(now int64) bool {
+	return d.Status != StatusPending && d.UpdatedAt+int64(doneTTL/time.Second) <= now
+}
+
+// scanDeliveries вызывает fn для всех доставок в порядке создания.
+func scanDeliveries(r kv.Reader, fn func(d *Delivery) error) error {
+	return r.Iterate([]byte(queuePrefix), nil, func(_, v []byte) error {
+		var d Delivery
+		if err := json.Unmarshal(v, &d); err != nil {
+			return err
+		}
+		return fn(&d)
+	})
+}
+
+func loadCursor(r kv.Reader) (int64, error) {
+	v, err := r.Get(cursorKey)
+	if err == kv.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(v), 10, 64)
+}
+
+func saveCursor(txn kv.Writer, height int64) error {
+	return txn.Set(cursorKey, []byte(strconv.FormatInt(height, 10)))
+}
+
+// Status — сводка очереди для `lbc webhooks status`.
+type Status struct {
+	Pending    int         `json:"pending"`
+	Delivered  int         `json:"delivered"`
+	Failed     int         `json:"failed"`
+	Height     int64       `json:"height"` // последний обработанный блок
+	Deliveries []*Delivery `json:"deliveries"`
+}
+
+// readStatus собирает сводку; all=false оставляет в списке только
+// недоставленные (pending и failed).
+func readStatus(r kv.Reader, all bool) (*Status, error) {
+	st := &Status{Deliveries: []*Delivery{}}
+	var err error
+	if st.Height, err = loadCursor(r); err != nil {
+		return nil, err
+	}
+	err = scanDeliveries(r, func(d *Delivery) error {
+		switch d.Status {
+		case StatusPending:
+			st.Pending++
+		case StatusDelivered:
+			st.Delivered++
+		case StatusFailed:
+			st.Failed++
+		}
+		if all || d.Status != StatusDelivered {
+			st.Deliveries = append(st.Deliveries, d)
+		}
+		return nil
+	})
+	return st, err
+}
+
+// StatusFileName — имя файла сводки очереди в каталоге данных ноды.
+const StatusFileName = "webhooks.json"
+
+// writeStatusFile атомарно заменяет файл сводки: читатель видит либо
+// старую сводку, либо новую. Файл доступен только владельцу.
+func writeStatusFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ReadStatusFile читает сводку, записанную работающей нодой; all=false
+// оставляет в списке только недоставленные, как readStatus.
+func ReadStatusFile(path string, all bool) (*Status, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var st Status
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if !all {
+		undelivered := []*Delivery{}
+		for _, d := range st.Deliveries {
+			if d.Status != StatusDelivered {
+				undelivered = append(undelivered, d)
+			}
+		}
+		st.Deliveries = undelivered
+	}
+	return &st, nil
+}
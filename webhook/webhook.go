@@ -0,0 +1,405 @@
+// Package webhook отправляет POST-запросы на адреса из секции [webhooks]
+// конфигурации при событиях реестра: создании обязательства, его
+// выполнении и наступлении его срока. Доставки лежат в базе ноды (local:webhook:...) и переживают
+// перезапуск; неудачные повторяются с экспоненциальной задержкой. Сводку
+// очереди нода пишет в локальный файл (см. StatusFile): адреса получателей и
+// ошибки доставки — данные ноды, через abci_query они не отдаются.
+//
+// Каждый запрос подписан, если у получателя задан secret:
+//
+//	X-LBC-Signature: t=<unix>,v1=<hex HMAC-SHA256(secret, "<unix>." + body)>
+//
+// Получатель должен пересчитать HMAC и отбросить запросы со старым t.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gregorybednov/lbc/blockchain"
+	"github.com/gregorybednov/lbc/cfg"
+	"github.com/gregorybednov/lbc/kv"
+)
+
+// События, на которые можно подписать получателя (events в конфигурации).
+const (
+	EventCommitmentCreated = "commitment.created"
+	EventCommitmentOverdue = "commitment.overdue"
+	// EventFulfillmentRecorded — отметка о выполнении обязательства
+	// (частичном или полном).
+	EventFulfillmentRecorded = "fulfillment.recorded"
+	// EventCommitmentFulfilled — обязательство выполнено полностью.
+	EventCommitmentFulfilled = "commitment.fulfilled"
+)
+
+const (
+	requestTimeout   = 10 * time.Second
+	dispatchInterval = time.Second
+	minBackoff       = 10 * time.Second
+	maxBackoff       = time.Hour
+)
+
+type service struct {
+	app        *blockchain.PromiseApp
+	conf       cfg.WebhooksConfig
+	client     *http.Client
+	statusFile string
+	lastStatus []byte // последнее записанное в statusFile
+}
+
+// Service — blockchain.Service доставки вебхуков по конфигурации conf;
+// сводка очереди пишется в statusFile.
+func Service(conf cfg.WebhooksConfig, statusFile string) blockchain.Service {
+	return func(ctx context.Context, app *blockchain.PromiseApp) error {
+		s := &service{app: app, conf: conf, client: &http.Client{Timeout: requestTimeout}, statusFile: statusFile}
+		go s.dispatchLoop(ctx)
+		return s.run(ctx)
+	}
+}
+
+// payload — тело запроса.
+type payload struct {
+	ID        string          `json:"id"`
+	Event     string          `json:"event"`
+	Height    int64           `json:"height,omitempty"`
+	CreatedAt int64           `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+func wants(e cfg.WebhookEndpoint, event string) bool {
+	return len(e.Events) == 0 || slices.Contains(e.Events, event)
+}
+
+// enqueue ставит событие в очередь для всех получателей, подписанных на него.
+func (s *service) enqueue(txn kv.Writer, event string, height int64, data json.RawMessage) error {
+	now := time.Now()
+	for _, e := range s.conf.Endpoints {
+		if !wants(e, event) {
+			continue
+		}
+		id := newDeliveryID(now)
+		body, err := json.Marshal(payload{ID: id, Event: event, Height: height, CreatedAt: now.Unix(), Data: data})
+		if err != nil {
+			return err
+		}
+		d := &Delivery{
+			ID:          id,
+			URL:         e.URL,
+			Event:       event,
+			Payload:     body,
+			Status:      StatusPending,
+			NextAttempt: now.Unix(),
+			CreatedAt:   now.Unix(),
+			UpdatedAt:   now.Unix(),
+		}
+		if err := putDelivery(txn, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run переводит события блоков в доставки. Курсор по высоте хранится вместе
+// с доставками, поэтому после перезапуска блоки не теряются и не дублируются.
+func (s *service) run(ctx context.Context) error {
+	live, cancel := s.app.Subscribe()
+	defer func() { cancel() }()
+	if err := s.catchUp(); err != nil {
+		return err
+	}
+
+	overdue := time.NewTicker(s.conf.OverdueCheckInterval)
+	defer overdue.Stop()
+	if err := s.checkOverdue(); err != nil {
+		fmt.Printf("webhooks: overdue check: %v\n", err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-live:
+			if !ok {
+				// Отстали от блоков: переподписаться и дочитать по курсору.
+				live, cancel = s.app.Subscribe()
+				if err := s.catchUp(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := s.handleBlock(ev); err != nil {
+				return err
+			}
+		case <-overdue.C:
+			if err := s.checkOverdue(); err != nil {
+				fmt.Printf("webhooks: overdue check: %v\n", err)
+			}
+		}
+	}
+}
+
+func (s *service) catchUp() error {
+	var cursor int64
+	if err := s.app.LocalView(func(r kv.Reader) error {
+		var err error
+		cursor, err = loadCursor(r)
+		return err
+	}); err != nil {
+		return err
+	}
+	return s.app.EventsSince(cursor, s.handleBlock)
+}
+
+func (s *service) handleBlock(ev blockchain.BlockEvent) error {
+	// Обязательства, выполненные в блоке, неизменны: их можно прочитать из
+	// текущего состояния и при догоне старых блоков.
+	var fulfilled []json.RawMessage
+	if len(ev.Completed) > 0 {
+		if err := s.app.View(func(snap *blockchain.Snapshot) error {
+			for _, id := range ev.Completed {
+				v, ok, err := snap.Get(id)
+				if err != nil {
+					return err
+				}
+				if ok {
+					fulfilled = append(fulfilled, v)
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return s.app.LocalUpdate(func(txn kv.Txn) error {
+		cursor, err := loadCursor(txn)
+		if err != nil || ev.Height <= cursor {
+			return err
+		}
+		for _, r := range ev.Records {
+			var event string
+			switch r.Kind {
+			case "commitment":
+				event = EventCommitmentCreated
+			case "fulfillment":
+				event = EventFulfillmentRecorded
+			default:
+				continue
+			}
+			if err := s.enqueue(txn, event, ev.Height, r.Value); err != nil {
+				return err
+			}
+		}
+		for _, v := range fulfilled {
+			if err := s.enqueue(txn, EventCommitmentFulfilled, ev.Height, v); err != nil {
+				return err
+			}
+		}
+		return saveCursor(txn, ev.Height)
+	})
+}
+
+// checkOverdue ставит commitment.overdue для обязательств, срок которых
+// прошёл, а выполнение не завершено; каждое обязательство — один раз.
+func (s *service) checkOverdue() error {
+	now := time.Now().Unix()
+	var due []json.RawMessage
+	var ids []string
+	err := s.app.View(func(snap *blockchain.Snapshot) error {
+		return snap.Scan("commitment:", func(v json.RawMessage) error {
+			var c struct {
+				ID  string `json:"id"`
+				Due int64  `json:"due"`
+			}
+			if json.Unmarshal(v, &c) != nil || c.Due <= 0 || c.Due > now {
+				return nil
+			}
+			if p, ok, err := snap.Progress(c.ID); err != nil {
+				return err
+			} else if !ok || !p.Complete {
+				due = append(due, v)
+				ids = append(ids, c.ID)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return s.app.LocalUpdate(func(txn kv.Txn) error {
+		for i, id := range ids {
+			key := []byte(overduePrefix + id)
+			if _, err := txn.Get(key); err == nil {
+				continue
+			} else if err != kv.ErrNotFound {
+				return err
+			}
+			if err := s.enqueue(txn, EventCommitmentOverdue, 0, due[i]); err != nil {
+				return err
+			}
+			if err := txn.Set(key, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *service) dispatchLoop(ctx context.Context) {
+	ticker := time.NewTicker(dispatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.dispatch(ctx); err != nil {
+				fmt.Printf("webhooks: dispatch: %v\n", err)
+			}
+			if err := s.writeStatus(); err != nil {
+				fmt.Printf("webhooks: status: %v\n", err)
+			}
+		}
+	}
+}
+
+// dispatch отправляет доставки, время которых подошло, и удаляет
+// устаревшие завершённые.
+func (s *service) dispatch(ctx context.Context) error {
+	now := time.Now().Unix()
+	var ready []*Delivery
+	var expired [][]byte
+	if err := s.app.LocalView(func(r kv.Reader) error {
+		return scanDeliveries(r, func(d *Delivery) error {
+			switch {
+			case d.Status == StatusPending && d.NextAttempt <= now:
+				ready = append(ready, d)
+			case d.expired(now):
+				expired = append(expired, queueKey(d.ID))
+			}
+			return nil
+		})
+	}); err != nil {
+		return err
+	}
+	if len(expired) > 0 {
+		if err := s.app.LocalUpdate(func(txn kv.Txn) error {
+			for _, key := range expired {
+				if err := txn.Delete(key); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	for _, d := range ready {
+		if ctx.Err() != nil {
+			return nil
+		}
+		err := s.send(ctx, d)
+		d.Attempts++
+		d.UpdatedAt = time.Now().Unix()
+		switch {
+		case err == nil:
+			d.Status, d.LastError = StatusDelivered, ""
+		case d.Attempts >= s.conf.MaxAttempts:
+			d.Status, d.LastError = StatusFailed, err.Error()
+		default:
+			d.LastError = err.Error()
+			d.NextAttempt = d.UpdatedAt + int64(backoff(d.Attempts)/time.Second)
+		}
+		if err := s.app.LocalUpdate(func(txn kv.Txn) error {
+			return putDelivery(txn, d)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backoff — задержка перед попыткой attempts+1: 10s, 20s, 40s... до часа.
+func backoff(attempts int) time.Duration {
+	d := minBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
+
+func (s *service) endpoint(url string) (cfg.WebhookEndpoint, bool) {
+	for _, e := range s.conf.Endpoints {
+		if e.URL == url {
+			return e, true
+		}
+	}
+	return cfg.WebhookEndpoint{}, false
+}
+
+// Sign возвращает значение X-LBC-Signature для тела body в момент ts.
+func Sign(secret string, ts int64, body []byte) string {
+	t := strconv.FormatInt(ts, 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t + "."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *service) send(ctx context.Context, d *Delivery) error {
+	e, ok := s.endpoint(d.URL)
+	if !ok {
+		return fmt.Errorf("endpoint %s is no longer configured", d.URL)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "lbc-webhook/1")
+	req.Header.Set("X-LBC-Event", d.Event)
+	req.Header.Set("X-LBC-Delivery", d.ID)
+	if e.Secret != "" {
+		req.Header.Set("X-LBC-Signature", Sign(e.Secret, time.Now().Unix(), d.Payload))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// writeStatus обновляет файл сводки, если она изменилась.
+func (s *service) writeStatus() error {
+	var st *Status
+	if err := s.app.LocalView(func(r kv.Reader) error {
+		var err error
+		st, err = readStatus(r, true)
+		return err
+	}); err != nil {
+		return err
+	}
+	data, err := json.Marshal(st)
+	if err != nil || bytes.Equal(data, s.lastStatus) {
+		return err
+	}
+	if err := writeStatusFile(s.statusFile, data); err != nil {
+		return err
+	}
+	s.lastStatus = data
+	return nil
+}
@@ -199,6 +199,7 @@ func Yggdrasil(config *viper.Viper, ch chan string) {
 	if err != nil {
 		panic(err)
 	}
+	publishNetwork(s, parsed)
 
 	{
 		counter := 0
@@ -0,0 +1,46 @@
+package yggdrasil
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/yggdrasil-network/yggstack/src/netstack"
+)
+
+// Network — сеть Yggdrasil, поднятая нодой: стек для собственных TCP-служб
+// (например, обмена вложениями) и адреса пиров из persistent_peers.
+type Network struct {
+	Stack *netstack.YggdrasilNetstack
+	Peers []net.IP
+}
+
+var (
+	networkReady = make(chan struct{})
+	network      *Network
+)
+
+// publishNetwork вызывается один раз, когда стек создан.
+func publishNetwork(s *netstack.YggdrasilNetstack, parsed []ParsedEntry) {
+	n := &Network{Stack: s}
+	for _, p := range parsed {
+		if p.Proto != "ygg" {
+			continue
+		}
+		if ip := net.ParseIP(strings.Trim(p.Address, "[]")); ip != nil {
+			n.Peers = append(n.Peers, ip)
+		}
+	}
+	network = n
+	close(networkReady)
+}
+
+// WaitNetwork ждёт, пока Yggdrasil поднимет сеть.
+func WaitNetwork(ctx context.Context) (*Network, error) {
+	select {
+	case <-networkReady:
+		return network, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}